@@ -1,16 +1,13 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
-	"os/exec"
-	"regexp"
-	"runtime"
-	"strings"
 
 	"github.com/wailsapp/wails/v3/pkg/application"
 
+	"goprint-bridge/auth"
 	"goprint-bridge/autostart"
+	"goprint-bridge/certs"
 	"goprint-bridge/config"
 	"goprint-bridge/logger"
 	"goprint-bridge/printer"
@@ -54,164 +51,22 @@ func (a *AppService) Shutdown() {
 
 // GetPrinters returns a list of available printers
 func (a *AppService) GetPrinters() []Printer {
-	var printers []Printer
-
-	switch runtime.GOOS {
-	case "windows":
-		printers = a.getWindowsPrinters()
-	case "darwin":
-		printers = a.getMacPrinters()
-	case "linux":
-		printers = a.getLinuxPrinters()
-	default:
-		printers = []Printer{}
-	}
-
-	return printers
-}
-
-// WindowsPrinter matches PowerShell JSON output
-type WindowsPrinter struct {
-	Name          string      `json:"Name"`
-	PrinterStatus interface{} `json:"PrinterStatus"` // Can be string or int
-}
-
-// getWindowsPrinters gets printers using PowerShell
-func (a *AppService) getWindowsPrinters() []Printer {
-	// Use ConvertTo-Json to get structured data
-	cmd := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", "Get-Printer | Select-Object Name, PrinterStatus | ConvertTo-Json")
-	hideConsoleWindow(cmd)
-	output, err := cmd.Output()
+	list, err := printer.List()
 	if err != nil {
-		logger.Error("Failed to get Windows printers", err)
+		logger.Error("Failed to get printers", err)
 		return []Printer{}
 	}
 
-	var winPrinters []WindowsPrinter
-	// If only one printer, PowerShell returns an object, not array. We might need to handle that,
-	// but ConvertTo-Json usually handles arrays. However, for a single item it might return just object.
-	// A trick is to wrap in @() in PowerShell: @(Get-Printer ...)
-	cmd = exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", "@(Get-Printer | Select-Object Name, PrinterStatus) | ConvertTo-Json")
-	hideConsoleWindow(cmd)
-	output, err = cmd.Output()
-	if err != nil {
-		logger.Error("Failed to get Windows printers", err)
-		return []Printer{}
-	}
-
-	if err := json.Unmarshal(output, &winPrinters); err != nil {
-		logger.Error("Failed to parse Windows printers JSON", err)
-		return []Printer{}
-	}
-
-	var result []Printer
-	for _, p := range winPrinters {
-		status := "Unknown"
-		switch v := p.PrinterStatus.(type) {
-		case string:
-			status = v
-		case float64:
-			// Map standard Windows printer status codes
-			// Ref: https://learn.microsoft.com/en-us/windows/win32/cimwin32prov/win32-printer
-			code := int(v)
-			switch code {
-			case 1:
-				status = "Other"
-			case 2:
-				status = "Error"
-			case 3:
-				status = "Ready" // Idle
-			case 4:
-				status = "Printing"
-			case 5:
-				status = "Warmup"
-			case 6:
-				status = "Stopped"
-			case 7:
-				status = "Offline"
-			default:
-				status = fmt.Sprintf("Status %d", code)
-			}
-		default:
-			status = fmt.Sprintf("%v", v)
-		}
-
-		result = append(result, Printer{
+	printers := make([]Printer, 0, len(list))
+	for _, p := range list {
+		printers = append(printers, Printer{
 			Name:   p.Name,
-			Status: status,
+			Status: p.Status,
 		})
 	}
-	return result
-}
-
-// getMacPrinters gets printers using lpstat
-func (a *AppService) getMacPrinters() []Printer {
-	// Use -l for long output to get alerts/status
-	cmd := exec.Command("lpstat", "-l", "-p")
-	output, err := cmd.Output()
-	if err != nil {
-		logger.Error("Failed to get Mac printers", err)
-		return []Printer{}
-	}
-
-	lines := strings.Split(string(output), "\n")
-	var printers []Printer
-	var currentPrinter *Printer
-
-	// Regex to match the start of a printer block: "printer <name> is <status>."
-	reStart := regexp.MustCompile(`^printer\s+(\S+)\s+is\s+(\w+)`)
-	// Regex to match alert line: "Alerts: <alert>"
-	reAlert := regexp.MustCompile(`^\s+Alerts:\s+(.*)`)
-
-	for _, line := range lines {
-		// Check for start of new printer block
-		if matches := reStart.FindStringSubmatch(line); len(matches) >= 3 {
-			// If we were processing a printer, save it
-			if currentPrinter != nil {
-				printers = append(printers, *currentPrinter)
-			}
-			status := matches[2]
-			if status == "idle" {
-				status = "Ready"
-			} else {
-				// Capitalize first letter
-				if len(status) > 0 {
-					status = strings.ToUpper(status[:1]) + status[1:]
-				}
-			}
-
-			// Start new printer
-			currentPrinter = &Printer{
-				Name:   matches[1],
-				Status: status,
-			}
-		} else if currentPrinter != nil {
-			// Check for alerts in the current block
-			if matches := reAlert.FindStringSubmatch(line); len(matches) >= 2 {
-				alert := matches[1]
-				if strings.Contains(alert, "offline") {
-					currentPrinter.Status = "Offline"
-				}
-			}
-		}
-	}
-
-	// Append the last printer
-	if currentPrinter != nil {
-		printers = append(printers, *currentPrinter)
-	}
-
 	return printers
 }
 
-// getLinuxPrinters gets printers using lpstat
-func (a *AppService) getLinuxPrinters() []Printer {
-	return a.getMacPrinters() // Same command works for Linux
-}
-
-// parseOutput is no longer used but kept for reference if needed, or can be removed.
-// Removed to avoid unused code warning as getWindowsPrinters uses JSON now.
-
 // GetConfig returns the current configuration
 func (a *AppService) GetConfig() config.Config {
 	cfg := config.GetConfig()
@@ -270,6 +125,101 @@ func (a *AppService) PrintTestPage() error {
 	return printer.PrintTestPage(cfg.SelectedPrinter)
 }
 
+// CreateAPIKey creates a client key with the given scopes and returns it.
+// Only its hash is saved, so the key can't be shown again.
+func (a *AppService) CreateAPIKey(name string, printers []string, types []string, maxPayload int) (string, error) {
+	key, err := auth.GenerateKey()
+	if err != nil {
+		return "", err
+	}
+	err = config.AddAPIKey(config.APIKeyConfig{
+		Name:       name,
+		Hash:       auth.HashKey(key),
+		Printers:   printers,
+		Types:      types,
+		MaxPayload: maxPayload,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	logger.Info("Created API key: " + name)
+	return key, nil
+}
+
+// DeleteAPIKey revokes a client key
+func (a *AppService) DeleteAPIKey(name string) error {
+	if err := config.RemoveAPIKey(name); err != nil {
+		return err
+	}
+	logger.Info("Deleted API key: " + name)
+	return nil
+}
+
+// RespondOrigin answers an origin-approval event: once, always or never
+func (a *AppService) RespondOrigin(origin string, decision string) error {
+	if a.server == nil {
+		return fmt.Errorf("server not created")
+	}
+	return a.server.RespondOrigin(origin, decision)
+}
+
+// ForgetOrigin removes a browser origin from the allowlist
+func (a *AppService) ForgetOrigin(origin string) error {
+	if a.server == nil {
+		return fmt.Errorf("server not created")
+	}
+	return a.server.ForgetOrigin(origin)
+}
+
+// PendingOrigins returns the browser origins waiting for approval
+func (a *AppService) PendingOrigins() []string {
+	if a.server == nil {
+		return []string{}
+	}
+	return a.server.PendingOrigins()
+}
+
+// ExportCA saves the local CA certificate to dest, to be trusted by hand
+// on Windows, macOS or other machines
+func (a *AppService) ExportCA(dest string) error {
+	dir, err := caDir()
+	if err != nil {
+		return err
+	}
+	return certs.ExportCA(dir, dest)
+}
+
+// InstallCA trusts the local CA in the system store and in the browsers'
+// NSS databases (Linux)
+func (a *AppService) InstallCA() error {
+	dir, err := caDir()
+	if err != nil {
+		return err
+	}
+	if err := certs.InstallSystemTrust(dir); err != nil {
+		return err
+	}
+	if err := certs.InstallNSSTrust(dir); err != nil {
+		return err
+	}
+	logger.Info("Installed the local CA")
+	return nil
+}
+
+// caDir returns the certificate directory, creating the CA if HTTPS
+// hasn't been started yet
+func caDir() (string, error) {
+	dir, err := certs.Dir()
+	if err != nil {
+		return "", err
+	}
+	if _, err := certs.Ensure(dir, certs.DefaultHosts()); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
 // GetAutoStartStatus returns whether autostart is enabled
 func (a *AppService) GetAutoStartStatus() bool {
 	return autostart.IsEnabled()
@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// keyPrefix marks generated keys, so they are easy to spot in code and logs
+const keyPrefix = "gpb_"
+
+// hashPrefix names the hash algorithm stored in config.yaml
+const hashPrefix = "sha256:"
+
+// GenerateKey returns a new random API key
+func GenerateKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	return keyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashKey returns the form an API key is stored in. Keys are long and
+// random, so a single SHA-256 is enough to keep them safe at rest.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hashPrefix + hex.EncodeToString(sum[:])
+}
+
+// IsHash reports whether s is a stored key hash
+func IsHash(s string) bool {
+	return strings.HasPrefix(s, hashPrefix) && len(s) == len(hashPrefix)+2*sha256.Size
+}
+
+// VerifyKey reports whether key matches a stored hash, in constant time
+func VerifyKey(key, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashKey(key)), []byte(hash)) == 1
+}
@@ -0,0 +1,365 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Job ticket signature algorithms
+const (
+	AlgHS256 = "HS256"
+	AlgEdDSA = "EdDSA"
+)
+
+const (
+	// ticketLeeway allows for clocks that are slightly apart
+	ticketLeeway = 30 * time.Second
+	// MaxTicketLifetime is how far in the future a ticket may expire, which
+	// also bounds how long its nonce is remembered
+	MaxTicketLifetime = time.Hour
+)
+
+// ErrTicketExpired is returned for tickets past their expiry
+var ErrTicketExpired = errors.New("ticket expired")
+
+// TicketKey verifies the signature of job tickets
+type TicketKey struct {
+	ID        string            // Matched against the ticket's kid, if it has one
+	Algorithm string            // HS256 or EdDSA
+	Secret    []byte            // HS256 shared secret
+	PublicKey ed25519.PublicKey // EdDSA public key
+}
+
+// Ticket holds the claims of a job ticket: a JWT signed by the client's
+// backend that allows one print of one payload
+type Ticket struct {
+	Printer   string `json:"printer"`       // Printer the job must go to
+	Hash      string `json:"hash"`          // sha256:<hex> of the request body
+	MaxCopies int    `json:"max_copies"`    // Copies allowed, 1 when unset
+	Expires   int64  `json:"exp"`           // Unix seconds
+	NotBefore int64  `json:"nbf,omitempty"` // Unix seconds
+	Nonce     string `json:"nonce"`         // Used once
+}
+
+// ticketHeader is the JOSE header of a ticket
+type ticketHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// ParseTicketKey builds a verification key. HS256 keys use the secret as
+// is; EdDSA keys are a PEM public key or the base64 of its 32 bytes.
+func ParseTicketKey(id, algorithm, secret, publicKey string) (TicketKey, error) {
+	key := TicketKey{ID: id, Algorithm: algorithm}
+	switch algorithm {
+	case AlgHS256:
+		if len(secret) < 32 {
+			return key, fmt.Errorf("ticket key %q: HS256 secret must be at least 32 characters", id)
+		}
+		key.Secret = []byte(secret)
+	case AlgEdDSA:
+		pub, err := parseEd25519(publicKey)
+		if err != nil {
+			return key, fmt.Errorf("ticket key %q: %w", id, err)
+		}
+		key.PublicKey = pub
+	default:
+		return key, fmt.Errorf("ticket key %q: unsupported algorithm %q, must be HS256 or EdDSA", id, algorithm)
+	}
+	return key, nil
+}
+
+// parseEd25519 reads a PEM or base64 Ed25519 public key
+func parseEd25519(s string) (ed25519.PublicKey, error) {
+	s = strings.TrimSpace(s)
+	if block, _ := pem.Decode([]byte(s)); block != nil {
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key: %w", err)
+		}
+		key, ok := pub.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("public key is not Ed25519")
+		}
+		return key, nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		raw, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	}
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key must be PEM or the base64 of %d bytes", ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// HashContent returns the hash a ticket binds a request body with
+func HashContent(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hashPrefix + hex.EncodeToString(sum[:])
+}
+
+// ParseTicket checks a ticket's signature and validity period and returns
+// its claims. The key is chosen by the ticket's kid, or else by its
+// algorithm; a ticket signed with another algorithm than its key's is
+// rejected.
+func ParseTicket(token string, keys []TicketKey, now time.Time) (*Ticket, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed ticket")
+	}
+
+	var header ticketHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed ticket header: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed ticket signature")
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, key := range keys {
+		if key.Algorithm != header.Algorithm || (header.KeyID != "" && key.ID != header.KeyID) {
+			continue
+		}
+		if key.verify(signed, signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, fmt.Errorf("invalid ticket signature")
+	}
+
+	var t Ticket
+	if err := decodeSegment(parts[1], &t); err != nil {
+		return nil, fmt.Errorf("malformed ticket claims: %w", err)
+	}
+	if err := t.check(now); err != nil {
+		return nil, err
+	}
+	if t.MaxCopies == 0 {
+		t.MaxCopies = 1
+	}
+	return &t, nil
+}
+
+// verify checks a signature made with the key
+func (k TicketKey) verify(signed, signature []byte) bool {
+	switch k.Algorithm {
+	case AlgHS256:
+		mac := hmac.New(sha256.New, k.Secret)
+		mac.Write(signed)
+		return hmac.Equal(mac.Sum(nil), signature)
+	case AlgEdDSA:
+		return len(k.PublicKey) == ed25519.PublicKeySize && ed25519.Verify(k.PublicKey, signed, signature)
+	default:
+		return false
+	}
+}
+
+// check validates the claims at the given time
+func (t *Ticket) check(now time.Time) error {
+	switch {
+	case t.Printer == "":
+		return fmt.Errorf("ticket has no printer")
+	case !IsHash(t.Hash):
+		return fmt.Errorf("ticket has no valid hash")
+	case t.Nonce == "":
+		return fmt.Errorf("ticket has no nonce")
+	case t.MaxCopies < 0:
+		return fmt.Errorf("ticket has invalid max_copies")
+	case t.Expires == 0:
+		return fmt.Errorf("ticket has no expiry")
+	}
+
+	expires := time.Unix(t.Expires, 0)
+	if now.After(expires.Add(ticketLeeway)) {
+		return ErrTicketExpired
+	}
+	if expires.Sub(now) > MaxTicketLifetime+ticketLeeway {
+		return fmt.Errorf("ticket expires more than %d minutes ahead", int(MaxTicketLifetime.Minutes()))
+	}
+	if t.NotBefore != 0 && now.Add(ticketLeeway).Before(time.Unix(t.NotBefore, 0)) {
+		return fmt.Errorf("ticket not valid yet")
+	}
+	return nil
+}
+
+// decodeSegment decodes a base64url JSON part of a ticket
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// nonceCompactAfter is the number of appended nonces that triggers
+// rewriting the nonce file without the expired ones
+const nonceCompactAfter = 1000
+
+// NonceCache remembers the nonces of used tickets until they expire, so
+// a ticket can't be replayed. With a file, they also survive restarts.
+type NonceCache struct {
+	mu       sync.Mutex
+	seen     map[string]time.Time
+	path     string
+	file     *os.File
+	appended int
+}
+
+// nonceRecord is a line of the nonce file
+type nonceRecord struct {
+	Nonce   string `json:"nonce"`
+	Expires int64  `json:"exp"`
+}
+
+// NewNonceCache returns an empty cache kept in memory
+func NewNonceCache() *NonceCache {
+	return &NonceCache{seen: make(map[string]time.Time)}
+}
+
+// OpenNonceCache loads the nonces kept in path that haven't expired, and
+// appends the nonces used from now on
+func OpenNonceCache(path string, now time.Time) (*NonceCache, error) {
+	n := NewNonceCache()
+	n.path = path
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read nonces: %w", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		var r nonceRecord
+		// A line cut short by a crash is skipped
+		if json.Unmarshal([]byte(line), &r) != nil || r.Nonce == "" {
+			continue
+		}
+		// Kept through the last second a ticket is accepted, like Use
+		if expires := time.Unix(r.Expires, 0); !now.After(expires) {
+			n.seen[r.Nonce] = expires
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create nonce directory: %w", err)
+	}
+	if err := n.compactLocked(); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// Close closes the nonce file
+func (n *NonceCache) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.file == nil {
+		return nil
+	}
+	err := n.file.Close()
+	n.file = nil
+	return err
+}
+
+// Use records a ticket's nonce and reports false if it was used before.
+// The nonce is on disk before Use returns, when the cache has a file.
+func (n *NonceCache) Use(t *Ticket, now time.Time) (bool, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	// Forget nonces whose tickets can't be presented anymore
+	for nonce, expires := range n.seen {
+		if now.After(expires) {
+			delete(n.seen, nonce)
+		}
+	}
+
+	if _, used := n.seen[t.Nonce]; used {
+		return false, nil
+	}
+	expires := time.Unix(t.Expires, 0).Add(ticketLeeway)
+	if err := n.appendLocked(nonceRecord{Nonce: t.Nonce, Expires: expires.Unix()}); err != nil {
+		return false, err
+	}
+	n.seen[t.Nonce] = expires
+	return true, nil
+}
+
+// appendLocked writes a nonce to the file and syncs it
+func (n *NonceCache) appendLocked(r nonceRecord) error {
+	if n.path == "" {
+		return nil
+	}
+	if n.appended >= nonceCompactAfter && n.appended > len(n.seen) {
+		if err := n.compactLocked(); err != nil {
+			return err
+		}
+	}
+	if n.file == nil {
+		return fmt.Errorf("nonce file is closed")
+	}
+
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := n.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write nonce: %w", err)
+	}
+	if err := n.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync nonce: %w", err)
+	}
+	n.appended++
+	return nil
+}
+
+// compactLocked rewrites the file with the remembered nonces and reopens
+// it for appending
+func (n *NonceCache) compactLocked() error {
+	var buf []byte
+	for nonce, expires := range n.seen {
+		line, err := json.Marshal(nonceRecord{Nonce: nonce, Expires: expires.Unix()})
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+
+	tmp := n.path + ".tmp"
+	if err := os.WriteFile(tmp, buf, 0600); err != nil {
+		return fmt.Errorf("failed to write nonces: %w", err)
+	}
+	if n.file != nil {
+		n.file.Close()
+		n.file = nil
+	}
+	if err := os.Rename(tmp, n.path); err != nil {
+		return fmt.Errorf("failed to replace nonce file: %w", err)
+	}
+
+	f, err := os.OpenFile(n.path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open nonce file: %w", err)
+	}
+	n.file = f
+	n.appended = 0
+	return nil
+}
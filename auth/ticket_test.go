@@ -0,0 +1,285 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// now is the time tickets are checked at
+var now = time.Unix(1_800_000_000, 0)
+
+// hsSecret is the shared secret of the HS256 test key
+var hsSecret = []byte("0123456789abcdef0123456789abcdef")
+
+// segment encodes a ticket part as base64url JSON
+func segment(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// sign builds a ticket with the given header, signed with an HS256
+// secret ([]byte) or an Ed25519 private key
+func sign(t *testing.T, header ticketHeader, claims interface{}, key interface{}) string {
+	signed := segment(t, header) + "." + segment(t, claims)
+	var signature []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(k, []byte(signed))
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// claims returns valid ticket claims at now
+func claims() Ticket {
+	return Ticket{
+		Printer: "Front",
+		Hash:    HashContent([]byte(`{"type":"text"}`)),
+		Expires: now.Add(5 * time.Minute).Unix(),
+		Nonce:   "n-1",
+	}
+}
+
+// with returns valid claims changed by fn
+func with(fn func(c *Ticket)) Ticket {
+	c := claims()
+	fn(&c)
+	return c
+}
+
+func TestParseTicket(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []TicketKey{
+		{ID: "hs", Algorithm: AlgHS256, Secret: hsSecret},
+		{ID: "ed", Algorithm: AlgEdDSA, PublicKey: pub},
+	}
+	hs := ticketHeader{Algorithm: AlgHS256, KeyID: "hs"}
+	ed := ticketHeader{Algorithm: AlgEdDSA}
+
+	valid := sign(t, hs, claims(), hsSecret)
+	parts := strings.Split(valid, ".")
+
+	tests := []struct {
+		name  string
+		token string
+		err   string // Empty when the ticket is valid
+	}{
+		{name: "HS256 with kid", token: valid},
+		{name: "EdDSA without kid", token: sign(t, ed, claims(), priv)},
+
+		// Algorithm and key confusion
+		{name: "HS256 signed with the EdDSA public key", token: sign(t, ticketHeader{Algorithm: AlgHS256}, claims(), []byte(pub)), err: "invalid ticket signature"},
+		{name: "HS256 header pointing at the EdDSA key", token: sign(t, ticketHeader{Algorithm: AlgHS256, KeyID: "ed"}, claims(), hsSecret), err: "invalid ticket signature"},
+		{name: "EdDSA header pointing at the HS256 key", token: sign(t, ticketHeader{Algorithm: AlgEdDSA, KeyID: "hs"}, claims(), priv), err: "invalid ticket signature"},
+		{name: "unknown kid", token: sign(t, ticketHeader{Algorithm: AlgHS256, KeyID: "other"}, claims(), hsSecret), err: "invalid ticket signature"},
+		{name: "none", token: segment(t, ticketHeader{Algorithm: "none"}) + "." + segment(t, claims()) + ".", err: "invalid ticket signature"},
+		{name: "another secret", token: sign(t, hs, claims(), []byte(strings.Repeat("x", 32))), err: "invalid ticket signature"},
+
+		// Tampering
+		{name: "changed claims", token: parts[0] + "." + segment(t, with(func(c *Ticket) { c.Printer = "Kitchen" })) + "." + parts[2], err: "invalid ticket signature"},
+		{name: "changed signature", token: parts[0] + "." + parts[1] + "." + flip(parts[2]), err: "invalid ticket signature"},
+		{name: "changed header", token: segment(t, ticketHeader{Algorithm: AlgHS256}) + "." + parts[1] + "." + parts[2], err: "invalid ticket signature"},
+		{name: "two parts", token: parts[0] + "." + parts[1], err: "malformed ticket"},
+
+		// Validity period, with 30 seconds leeway
+		{name: "expired within leeway", token: sign(t, hs, with(func(c *Ticket) { c.Expires = now.Add(-ticketLeeway).Unix() }), hsSecret)},
+		{name: "expired", token: sign(t, hs, with(func(c *Ticket) { c.Expires = now.Add(-ticketLeeway - time.Second).Unix() }), hsSecret), err: "ticket expired"},
+		{name: "longest lifetime", token: sign(t, hs, with(func(c *Ticket) { c.Expires = now.Add(MaxTicketLifetime + ticketLeeway).Unix() }), hsSecret)},
+		{name: "past the longest lifetime", token: sign(t, hs, with(func(c *Ticket) { c.Expires = now.Add(MaxTicketLifetime + ticketLeeway + time.Second).Unix() }), hsSecret), err: "more than 60 minutes ahead"},
+		{name: "not before within leeway", token: sign(t, hs, with(func(c *Ticket) { c.NotBefore = now.Add(ticketLeeway).Unix() }), hsSecret)},
+		{name: "not valid yet", token: sign(t, hs, with(func(c *Ticket) { c.NotBefore = now.Add(ticketLeeway + time.Second).Unix() }), hsSecret), err: "not valid yet"},
+
+		// Required claims
+		{name: "no expiry", token: sign(t, hs, with(func(c *Ticket) { c.Expires = 0 }), hsSecret), err: "no expiry"},
+		{name: "no nonce", token: sign(t, hs, with(func(c *Ticket) { c.Nonce = "" }), hsSecret), err: "no nonce"},
+		{name: "no hash", token: sign(t, hs, with(func(c *Ticket) { c.Hash = "abc" }), hsSecret), err: "no valid hash"},
+		{name: "negative copies", token: sign(t, hs, with(func(c *Ticket) { c.MaxCopies = -1 }), hsSecret), err: "invalid max_copies"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ticket, err := ParseTicket(tt.token, keys, now)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("ParseTicket error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTicket failed: %v", err)
+			}
+			if ticket.Printer != "Front" || ticket.MaxCopies != 1 {
+				t.Errorf("ParseTicket = %+v, want the printer and one copy", ticket)
+			}
+		})
+	}
+
+	// Expiry can be told apart from other errors
+	expired := sign(t, hs, with(func(c *Ticket) { c.Expires = now.Add(-time.Hour).Unix() }), hsSecret)
+	if _, err := ParseTicket(expired, keys, now); !errors.Is(err, ErrTicketExpired) {
+		t.Errorf("ParseTicket error = %v, want ErrTicketExpired", err)
+	}
+}
+
+// flip changes the first character of a base64url segment
+func flip(s string) string {
+	if s[0] == 'A' {
+		return "B" + s[1:]
+	}
+	return "A" + s[1:]
+}
+
+func TestParseTicketKey(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		algorithm, secret, publicKey string
+		err                          string
+	}{
+		{algorithm: AlgHS256, secret: string(hsSecret)},
+		{algorithm: AlgHS256, secret: "short", err: "at least 32 characters"},
+		{algorithm: AlgEdDSA, publicKey: base64.StdEncoding.EncodeToString(pub)},
+		{algorithm: AlgEdDSA, publicKey: base64.RawURLEncoding.EncodeToString(pub)},
+		{algorithm: AlgEdDSA, publicKey: "AAAA", err: "base64 of 32 bytes"},
+		{algorithm: "RS256", err: "unsupported algorithm"},
+	}
+	for _, tt := range tests {
+		_, err := ParseTicketKey("k", tt.algorithm, tt.secret, tt.publicKey)
+		if tt.err == "" && err != nil {
+			t.Errorf("ParseTicketKey(%s) failed: %v", tt.algorithm, err)
+		}
+		if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("ParseTicketKey(%s) error = %v, want %q", tt.algorithm, err, tt.err)
+		}
+	}
+}
+
+// nonceLines returns the records in a nonce file
+func nonceLines(t *testing.T, path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Count(string(data), "\n")
+}
+
+func TestNonceCacheReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage", "nonces.log")
+	ticket := claims()
+
+	nonces, err := OpenNonceCache(path, now)
+	if err != nil {
+		t.Fatalf("OpenNonceCache failed: %v", err)
+	}
+	for i, want := range []bool{true, false} {
+		if fresh, err := nonces.Use(&ticket, now); err != nil || fresh != want {
+			t.Errorf("Use %d = %v, %v, want %v", i+1, fresh, err, want)
+		}
+	}
+	nonces.Close()
+
+	// The nonce survives a restart until the ticket can't be presented
+	// anymore
+	tests := []struct {
+		at    time.Time
+		fresh bool
+	}{
+		{at: now.Add(time.Minute), fresh: false},
+		{at: time.Unix(ticket.Expires, 0).Add(ticketLeeway), fresh: false},
+		{at: time.Unix(ticket.Expires, 0).Add(ticketLeeway + time.Second), fresh: true},
+	}
+	for _, tt := range tests {
+		nonces, err := OpenNonceCache(path, tt.at)
+		if err != nil {
+			t.Fatalf("OpenNonceCache failed: %v", err)
+		}
+		fresh, err := nonces.Use(&ticket, tt.at)
+		nonces.Close()
+		if err != nil || fresh != tt.fresh {
+			t.Errorf("Use after reopening at %v = %v, %v, want %v", tt.at.Sub(now), fresh, err, tt.fresh)
+		}
+		if tt.fresh {
+			break
+		}
+	}
+
+	// A line cut short by a crash is skipped
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"nonce":"n-2","ex`)
+	f.Close()
+	if _, err := OpenNonceCache(path, now); err != nil {
+		t.Errorf("OpenNonceCache with a torn line failed: %v", err)
+	}
+}
+
+func TestNonceCacheCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nonces.log")
+	nonces, err := OpenNonceCache(path, now)
+	if err != nil {
+		t.Fatalf("OpenNonceCache failed: %v", err)
+	}
+	defer nonces.Close()
+
+	use := func(nonce string, at time.Time, expires time.Duration) {
+		t.Helper()
+		ticket := with(func(c *Ticket) {
+			c.Nonce = nonce
+			c.Expires = at.Add(expires).Unix()
+		})
+		if fresh, err := nonces.Use(&ticket, at); err != nil || !fresh {
+			t.Fatalf("Use(%s) = %v, %v", nonce, fresh, err)
+		}
+	}
+
+	for i := 0; i < nonceCompactAfter; i++ {
+		use(fmt.Sprintf("short-%d", i), now, time.Second)
+	}
+	if got := nonceLines(t, path); got != nonceCompactAfter {
+		t.Fatalf("nonce file has %d lines, want %d", got, nonceCompactAfter)
+	}
+
+	// Once they have expired, the next nonce rewrites the file without them
+	later := now.Add(time.Second + ticketLeeway + time.Second)
+	use("live", later, time.Minute)
+	if got := nonceLines(t, path); got != 1 {
+		t.Errorf("nonce file has %d lines after compaction, want 1", got)
+	}
+
+	// Nonces that haven't expired are never dropped
+	reopened, err := OpenNonceCache(path, later)
+	if err != nil {
+		t.Fatalf("OpenNonceCache failed: %v", err)
+	}
+	defer reopened.Close()
+	ticket := with(func(c *Ticket) {
+		c.Nonce = "live"
+		c.Expires = later.Add(time.Minute).Unix()
+	})
+	if fresh, err := reopened.Use(&ticket, later); err != nil || fresh {
+		t.Errorf("Use(live) after compaction = %v, %v, want a replay", fresh, err)
+	}
+}
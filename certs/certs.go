@@ -0,0 +1,298 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Files in the certificate directory
+const (
+	CAFile     = "ca.pem"
+	caKeyFile  = "ca-key.pem"
+	certFile   = "cert.pem"
+	keyFile    = "key.pem"
+	caValidity = 10 * 365 * 24 * time.Hour
+	// Browsers refuse server certificates valid for longer than 825 days
+	certValidity = 825 * 24 * time.Hour
+	// renewBefore is how long before expiry the server certificate is renewed
+	renewBefore = 30 * 24 * time.Hour
+)
+
+// localRanges are the loopback and private networks the CA may issue
+// certificates for
+var localRanges = []string{"127.0.0.0/8", "::1/128", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"}
+
+// Dir returns the directory the certificates are kept in, under the
+// user's config directory
+func Dir() (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find config directory: %w", err)
+	}
+	return filepath.Join(base, "GoPrintBridge", "certs"), nil
+}
+
+// DefaultHosts returns the names the server certificate covers: localhost,
+// the loopback addresses, the machine's hostname and its LAN addresses
+func DefaultHosts() []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if name, err := os.Hostname(); err == nil && name != "" {
+		name = strings.ToLower(name)
+		hosts = append(hosts, name)
+		if !strings.Contains(name, ".") {
+			hosts = append(hosts, name+".local")
+		}
+	}
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.IsPrivate() {
+				hosts = append(hosts, ipnet.IP.String())
+			}
+		}
+	}
+	return hosts
+}
+
+// Ensure returns the server certificate in dir, generating the CA and the
+// certificate on first run. The certificate is issued again when it nears
+// expiry or doesn't cover all hosts; the CA is kept, so browsers that
+// trust it keep trusting the bridge.
+func Ensure(dir string, hosts []string) (tls.Certificate, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to create certificate directory: %w", err)
+	}
+
+	ca, caKey, err := loadPair(filepath.Join(dir, CAFile), filepath.Join(dir, caKeyFile))
+	if err != nil {
+		if ca, caKey, err = createCA(dir, hosts); err != nil {
+			return tls.Certificate{}, err
+		}
+	}
+	for _, host := range hosts {
+		if !permits(ca, host) {
+			return tls.Certificate{}, fmt.Errorf("the local CA can't issue certificates for %s, delete %s to create a new CA", host, dir)
+		}
+	}
+
+	cert, key, err := loadPair(filepath.Join(dir, certFile), filepath.Join(dir, keyFile))
+	if err != nil || !current(cert, ca, hosts) {
+		if cert, key, err = createCert(dir, ca, caKey, hosts); err != nil {
+			return tls.Certificate{}, err
+		}
+	}
+
+	return tls.Certificate{
+		Certificate: [][]byte{cert.Raw, ca.Raw},
+		PrivateKey:  key,
+		Leaf:        cert,
+	}, nil
+}
+
+// current reports whether a server certificate is signed by the CA, isn't
+// about to expire and covers all hosts
+func current(cert *x509.Certificate, ca *x509.Certificate, hosts []string) bool {
+	if cert.CheckSignatureFrom(ca) != nil || time.Until(cert.NotAfter) < renewBefore {
+		return false
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			if !slices.ContainsFunc(cert.IPAddresses, ip.Equal) {
+				return false
+			}
+		} else if !slices.Contains(cert.DNSNames, host) {
+			return false
+		}
+	}
+	return true
+}
+
+// createCA generates the local certificate authority. Name constraints
+// limit it to localhost, .local names, the hosts and the loopback and
+// private networks, so a leaked CA key can't impersonate other sites.
+func createCA(dir string, hosts []string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	name := "GoPrint Bridge Local CA"
+	if host, err := os.Hostname(); err == nil && host != "" {
+		name += " (" + host + ")"
+	}
+	template := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: name, Organization: []string{"GoPrint Bridge"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+
+		PermittedDNSDomainsCritical: true,
+		PermittedDNSDomains:         []string{"localhost", "local"},
+	}
+	for _, cidr := range localRanges {
+		_, ipnet, _ := net.ParseCIDR(cidr)
+		template.PermittedIPRanges = append(template.PermittedIPRanges, ipnet)
+	}
+	for _, host := range hosts {
+		if permits(template, host) {
+			continue
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+			}
+			template.PermittedIPRanges = append(template.PermittedIPRanges, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+		} else {
+			template.PermittedDNSDomains = append(template.PermittedDNSDomains, host)
+		}
+	}
+	cert, key, err := issue(template, nil, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create CA: %w", err)
+	}
+	if err := savePair(dir, CAFile, caKeyFile, cert, key); err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+// permits reports whether the CA's name constraints allow a host
+func permits(ca *x509.Certificate, host string) bool {
+	if ip := net.ParseIP(host); ip != nil {
+		return slices.ContainsFunc(ca.PermittedIPRanges, func(n *net.IPNet) bool { return n.Contains(ip) })
+	}
+	return slices.ContainsFunc(ca.PermittedDNSDomains, func(domain string) bool {
+		return host == domain || strings.HasSuffix(host, "."+domain)
+	})
+}
+
+// createCert issues a server certificate for the hosts
+func createCert(dir string, ca *x509.Certificate, caKey *ecdsa.PrivateKey, hosts []string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "GoPrint Bridge", Organization: []string{"GoPrint Bridge"}},
+		NotBefore:   time.Now().Add(-time.Hour),
+		NotAfter:    time.Now().Add(certValidity),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	cert, key, err := issue(template, ca, caKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	if err := savePair(dir, certFile, keyFile, cert, key); err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+// issue generates a key and signs the certificate with the parent, or
+// self-signs it when parent is nil
+func issue(template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	template.SerialNumber = serial
+
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+// savePair writes a certificate and its key as PEM. The key is only
+// readable by the user.
+func savePair(dir, certName, keyName string, cert *x509.Certificate, key *ecdsa.PrivateKey) error {
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to encode key: %w", err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(filepath.Join(dir, keyName), keyPEM, 0600); err != nil {
+		return fmt.Errorf("failed to save key: %w", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	if err := os.WriteFile(filepath.Join(dir, certName), certPEM, 0644); err != nil {
+		return fmt.Errorf("failed to save certificate: %w", err)
+	}
+	return nil
+}
+
+// loadPair reads a PEM certificate and its EC key
+func loadPair(certPath, keyPath string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	cert, err := LoadCertificate(certPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, nil, fmt.Errorf("%s: no PEM key", keyPath)
+	}
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", keyPath, err)
+	}
+	if !key.PublicKey.Equal(cert.PublicKey) {
+		return nil, nil, fmt.Errorf("%s doesn't match %s", keyPath, certPath)
+	}
+	return cert, key, nil
+}
+
+// LoadCertificate reads the first certificate of a PEM file
+func LoadCertificate(path string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("%s: no PEM certificate", path)
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// ExportCA copies the CA certificate to dest, to be trusted on other
+// machines or in browsers by hand
+func ExportCA(dir, dest string) error {
+	data, err := os.ReadFile(filepath.Join(dir, CAFile))
+	if err != nil {
+		return fmt.Errorf("failed to read CA certificate: %w", err)
+	}
+	if err := os.WriteFile(dest, data, 0644); err != nil {
+		return fmt.Errorf("failed to export CA certificate: %w", err)
+	}
+	return nil
+}
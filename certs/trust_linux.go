@@ -0,0 +1,112 @@
+//go:build linux
+// +build linux
+
+package certs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// nssNickname names the CA in NSS databases
+const nssNickname = "GoPrint Bridge Local CA"
+
+// systemStore is a distribution's trust anchor directory and the command
+// that rebuilds its bundle
+type systemStore struct {
+	dir    string
+	file   string
+	update []string
+}
+
+// systemStores lists the trust stores of Debian/Ubuntu, Fedora/RHEL and Arch
+var systemStores = []systemStore{
+	{"/usr/local/share/ca-certificates", "goprint-bridge.crt", []string{"update-ca-certificates"}},
+	{"/etc/pki/ca-trust/source/anchors", "goprint-bridge.pem", []string{"update-ca-trust", "extract"}},
+	{"/etc/ca-certificates/trust-source/anchors", "goprint-bridge.crt", []string{"trust", "extract-compat"}},
+}
+
+// InstallSystemTrust adds the CA to the system trust store. Without root
+// the copy runs through pkexec, which asks for the admin password.
+func InstallSystemTrust(dir string) error {
+	ca := filepath.Join(dir, CAFile)
+	if _, err := os.Stat(ca); err != nil {
+		return fmt.Errorf("failed to read CA certificate: %w", err)
+	}
+
+	for _, store := range systemStores {
+		if _, err := os.Stat(store.dir); err != nil {
+			continue
+		}
+		if _, err := exec.LookPath(store.update[0]); err != nil {
+			continue
+		}
+
+		// Copy, then rebuild the bundle, with one password prompt
+		args := append([]string{"sh", "-c", `install -m 0644 "$1" "$2" && shift 2 && exec "$@"`, "sh",
+			ca, filepath.Join(store.dir, store.file)}, store.update...)
+		if os.Geteuid() != 0 {
+			args = append([]string{"pkexec"}, args...)
+		}
+		if output, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to install CA: %w: %s", err, strings.TrimSpace(string(output)))
+		}
+		return nil
+	}
+	return fmt.Errorf("no supported system trust store found")
+}
+
+// InstallNSSTrust adds the CA to the NSS databases Chrome, Chromium and
+// Firefox use for the current user. It needs certutil (libnss3-tools on
+// Debian/Ubuntu, nss-tools on Fedora).
+func InstallNSSTrust(dir string) error {
+	ca := filepath.Join(dir, CAFile)
+	if _, err := os.Stat(ca); err != nil {
+		return fmt.Errorf("failed to read CA certificate: %w", err)
+	}
+	if _, err := exec.LookPath("certutil"); err != nil {
+		return fmt.Errorf("certutil not found, install libnss3-tools or nss-tools")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("failed to find home directory: %w", err)
+	}
+
+	// Chrome and Chromium share one database, created when missing
+	shared := filepath.Join(home, ".pki", "nssdb")
+	if _, err := os.Stat(filepath.Join(shared, "cert9.db")); err != nil {
+		if err := os.MkdirAll(shared, 0700); err != nil {
+			return fmt.Errorf("failed to create NSS database: %w", err)
+		}
+		if output, err := exec.Command("certutil", "-d", "sql:"+shared, "-N", "--empty-password").CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to create NSS database: %w: %s", err, strings.TrimSpace(string(output)))
+		}
+	}
+	dbs := []string{shared}
+
+	// Firefox keeps one database per profile
+	for _, pattern := range []string{
+		filepath.Join(home, ".mozilla", "firefox", "*", "cert9.db"),
+		filepath.Join(home, "snap", "firefox", "common", ".mozilla", "firefox", "*", "cert9.db"),
+	} {
+		matches, _ := filepath.Glob(pattern)
+		for _, match := range matches {
+			dbs = append(dbs, filepath.Dir(match))
+		}
+	}
+
+	var errs []error
+	for _, db := range dbs {
+		// Replace a CA installed by an earlier run
+		exec.Command("certutil", "-d", "sql:"+db, "-D", "-n", nssNickname).Run()
+		output, err := exec.Command("certutil", "-d", "sql:"+db, "-A", "-t", "C,,", "-n", nssNickname, "-i", ca).CombinedOutput()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w: %s", db, err, strings.TrimSpace(string(output))))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+// +build !linux
+
+package certs
+
+import "fmt"
+
+// InstallSystemTrust is only available on Linux. On Windows and macOS the
+// exported CA is trusted by opening it.
+func InstallSystemTrust(dir string) error {
+	return fmt.Errorf("installing the CA is only supported on Linux, export it and trust it by hand")
+}
+
+// InstallNSSTrust is only available on Linux
+func InstallNSSTrust(dir string) error {
+	return fmt.Errorf("installing the CA is only supported on Linux, export it and trust it by hand")
+}
@@ -1,21 +1,171 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"sync"
 
 	"github.com/spf13/viper"
+
+	"goprint-bridge/auth"
 )
 
 // Config holds the application configuration
 type Config struct {
-	SelectedPrinter string `mapstructure:"selected_printer" json:"selected_printer"`
-	Port            int    `mapstructure:"port" json:"port"`
-	AutoStart       bool   `mapstructure:"auto_start" json:"auto_start"`
+	SelectedPrinter string          `mapstructure:"selected_printer" json:"selected_printer"`
+	Port            int             `mapstructure:"port" json:"port"`
+	AutoStart       bool            `mapstructure:"auto_start" json:"auto_start"`
+	Printers        []PrinterConfig `mapstructure:"printers" json:"printers"`
+	APIKeys         []APIKeyConfig  `mapstructure:"api_keys" json:"api_keys"`
+	Origins         []OriginConfig  `mapstructure:"origins" json:"origins"`
+	TLS             *TLSConfig      `mapstructure:"tls" json:"tls,omitempty"`
+	Tickets         *TicketConfig   `mapstructure:"tickets" json:"tickets,omitempty"`
+}
+
+// TicketConfig holds the keys that verify job tickets, JWTs signed by a
+// client's backend to authorize one print
+type TicketConfig struct {
+	Required bool              `mapstructure:"required" yaml:"required" json:"required"` // reject prints without a ticket
+	Keys     []TicketKeyConfig `mapstructure:"keys" yaml:"keys" json:"keys"`
+
+	verifiers []auth.TicketKey // Keys parsed at load
+}
+
+// TicketKeyConfig is a key job tickets can be signed with
+type TicketKeyConfig struct {
+	ID        string `mapstructure:"id" yaml:"id,omitempty" json:"id,omitempty"`                         // matched against the ticket's kid
+	Algorithm string `mapstructure:"algorithm" yaml:"algorithm" json:"algorithm"`                        // HS256 or EdDSA
+	Secret    string `mapstructure:"secret" yaml:"secret,omitempty" json:"-"`                            // HS256 shared secret, at least 32 characters
+	PublicKey string `mapstructure:"public_key" yaml:"public_key,omitempty" json:"public_key,omitempty"` // EdDSA: PEM, or base64 of the 32 byte key
+}
+
+// DefaultTLSPort is the HTTPS port when TLS is enabled without one
+const DefaultTLSPort = 9443
+
+// TLSConfig enables an HTTPS listener next to the HTTP one, with a
+// certificate issued by a CA generated on first run
+type TLSConfig struct {
+	Enabled bool     `mapstructure:"enabled" yaml:"enabled" json:"enabled"`
+	Port    int      `mapstructure:"port" yaml:"port,omitempty" json:"port,omitempty"`    // defaults to 9443
+	Hosts   []string `mapstructure:"hosts" yaml:"hosts,omitempty" json:"hosts,omitempty"` // extra names and IPs the certificate covers
+
+	// Client certificates (mutual TLS)
+	ClientCA   string            `mapstructure:"client_ca" yaml:"client_ca,omitempty" json:"client_ca,omitempty"`       // PEM bundle of the CAs client certificates must chain to
+	ClientAuth string            `mapstructure:"client_auth" yaml:"client_auth,omitempty" json:"client_auth,omitempty"` // require (default) or optional
+	Clients    []TLSClientConfig `mapstructure:"clients" yaml:"clients,omitempty" json:"clients,omitempty"`
+}
+
+// Client certificate modes
+const (
+	ClientAuthRequire  = "require"  // Every HTTPS client needs a certificate
+	ClientAuthOptional = "optional" // Certificates are checked when given
+)
+
+// TLSClientConfig maps client certificates to an identity and what it
+// may print. A certificate matches when its subject or one of its
+// alternative names matches.
+type TLSClientConfig struct {
+	Name     string   `mapstructure:"name" yaml:"name" json:"name"`
+	Subject  string   `mapstructure:"subject" yaml:"subject,omitempty" json:"subject,omitempty"`    // common name, or the full subject such as CN=erp,O=Store
+	SAN      string   `mapstructure:"san" yaml:"san,omitempty" json:"san,omitempty"`                // DNS name, email, IP or URI
+	Printers []string `mapstructure:"printers" yaml:"printers,omitempty" json:"printers,omitempty"` // allowed printers, all when empty
+	Types    []string `mapstructure:"types" yaml:"types,omitempty" json:"types,omitempty"`          // allowed print types, all when empty
+}
+
+// MutualTLS reports whether HTTPS clients are checked for certificates
+func (t *TLSConfig) MutualTLS() bool {
+	return t != nil && t.Enabled && t.ClientCA != ""
+}
+
+// RequiresClientCert reports whether every HTTPS client needs a certificate
+func (t *TLSConfig) RequiresClientCert() bool {
+	return t.MutualTLS() && t.ClientAuth != ClientAuthOptional
+}
+
+// OriginConfig is the operator's decision on a browser origin, e.g.
+// https://shop.example.com
+type OriginConfig struct {
+	Origin string `mapstructure:"origin" yaml:"origin" json:"origin"`
+	Allow  bool   `mapstructure:"allow" yaml:"allow" json:"allow"`
+}
+
+// APIKeyConfig is a client allowed to use the HTTP API and what it may
+// print. A plaintext key is replaced by its hash when the config loads.
+type APIKeyConfig struct {
+	Name       string   `mapstructure:"name" yaml:"name" json:"name"`
+	Key        string   `mapstructure:"key" yaml:"key,omitempty" json:"-"`
+	Hash       string   `mapstructure:"hash" yaml:"hash,omitempty" json:"-"`                                   // sha256:<hex>
+	Printers   []string `mapstructure:"printers" yaml:"printers,omitempty" json:"printers,omitempty"`          // allowed printers, all when empty
+	Types      []string `mapstructure:"types" yaml:"types,omitempty" json:"types,omitempty"`                   // allowed print types, all when empty
+	MaxPayload int      `mapstructure:"max_payload" yaml:"max_payload,omitempty" json:"max_payload,omitempty"` // request body limit in bytes
+}
+
+// PrinterConfig declares a named printer and the backend that drives it.
+// Printers that are not listed use the operating system's print backend.
+type PrinterConfig struct {
+	Name    string `mapstructure:"name" yaml:"name" json:"name"`
+	Backend string `mapstructure:"backend" yaml:"backend" json:"backend"`
+
+	// File sink backend
+	SpoolDir string `mapstructure:"spool_dir" yaml:"spool_dir,omitempty" json:"spool_dir,omitempty"`
+
+	// Raw TCP (JetDirect) backend
+	Address        string `mapstructure:"address" yaml:"address,omitempty" json:"address,omitempty"`                         // host or host:port, port defaults to 9100
+	ConnectTimeout int    `mapstructure:"connect_timeout" yaml:"connect_timeout,omitempty" json:"connect_timeout,omitempty"` // seconds
+	WriteTimeout   int    `mapstructure:"write_timeout" yaml:"write_timeout,omitempty" json:"write_timeout,omitempty"`       // seconds
+	Retries        *int   `mapstructure:"retries" yaml:"retries,omitempty" json:"retries,omitempty"`                         // defaults to 2, 0 disables retries
+
+	// Device file backend (USB / serial)
+	Device   string `mapstructure:"device" yaml:"device,omitempty" json:"device,omitempty"` // e.g. /dev/usb/lp0, /dev/ttyUSB0
+	BaudRate int    `mapstructure:"baud_rate" yaml:"baud_rate,omitempty" json:"baud_rate,omitempty"`
+	Parity   string `mapstructure:"parity" yaml:"parity,omitempty" json:"parity,omitempty"` // none, even, odd
+	DataBits int    `mapstructure:"data_bits" yaml:"data_bits,omitempty" json:"data_bits,omitempty"`
+	StopBits int    `mapstructure:"stop_bits" yaml:"stop_bits,omitempty" json:"stop_bits,omitempty"`
+	ReadBack bool   `mapstructure:"read_back" yaml:"read_back,omitempty" json:"read_back,omitempty"` // query ESC/POS status bytes
+
+	// Thermal receipt and label printers
+	Language   string `mapstructure:"language" yaml:"language,omitempty" json:"language,omitempty"`          // escpos (default), zpl, or pdf for document printers; used for image jobs
+	DotWidth   int    `mapstructure:"dot_width" yaml:"dot_width,omitempty" json:"dot_width,omitempty"`       // printable width in dots, 384 for 58mm, 576 for 80mm
+	BufferSize int    `mapstructure:"buffer_size" yaml:"buffer_size,omitempty" json:"buffer_size,omitempty"` // largest raster band in bytes
+	Columns    int    `mapstructure:"columns" yaml:"columns,omitempty" json:"columns,omitempty"`             // characters per line, derived from dot_width when unset
+
+	// Text encoding for raw and ESC/POS text
+	Encoding   string  `mapstructure:"encoding" yaml:"encoding,omitempty" json:"encoding,omitempty"`       // cp437, cp850, cp858, windows-1252; UTF-8 when unset
+	Substitute *string `mapstructure:"substitute" yaml:"substitute,omitempty" json:"substitute,omitempty"` // replaces unmappable characters, defaults to "?"
+
+	// Text rendered as images, for scripts no code page covers
+	RasterText string   `mapstructure:"raster_text" yaml:"raster_text,omitempty" json:"raster_text,omitempty"` // auto or always
+	Fonts      []string `mapstructure:"fonts" yaml:"fonts,omitempty" json:"fonts,omitempty"`                   // TrueType/OpenType files tried in order
+	FontSize   int      `mapstructure:"font_size" yaml:"font_size,omitempty" json:"font_size,omitempty"`       // in dots, defaults to 24
+
+	// IPP backend
+	URI string `mapstructure:"uri" yaml:"uri,omitempty" json:"uri,omitempty"` // e.g. ipp://localhost:631/printers/Office
+
+	// Office printers: text jobs laid out as PDF pages
+	TextPDF *TextPDFConfig `mapstructure:"text_pdf" yaml:"text_pdf,omitempty" json:"text_pdf,omitempty"`
+}
+
+// TextPDFConfig controls how text jobs are laid out as PDF pages
+type TextPDFConfig struct {
+	PageSize    string  `mapstructure:"page_size" yaml:"page_size,omitempty" json:"page_size,omitempty"` // A4 (default), Letter, Legal, ...
+	Landscape   bool    `mapstructure:"landscape" yaml:"landscape,omitempty" json:"landscape,omitempty"`
+	Margin      float64 `mapstructure:"margin" yaml:"margin,omitempty" json:"margin,omitempty"`          // millimetres, defaults to 15
+	Font        string  `mapstructure:"font" yaml:"font,omitempty" json:"font,omitempty"`                // courier (default) or helvetica
+	FontSize    float64 `mapstructure:"font_size" yaml:"font_size,omitempty" json:"font_size,omitempty"` // points, defaults to 10
+	LineNumbers bool    `mapstructure:"line_numbers" yaml:"line_numbers,omitempty" json:"line_numbers,omitempty"`
+	Header      string  `mapstructure:"header" yaml:"header,omitempty" json:"header,omitempty"` // left|center|right, with {page}, {pages}, {title}, {date}
+	Footer      string  `mapstructure:"footer" yaml:"footer,omitempty" json:"footer,omitempty"`
 }
 
 var cfg *Config
 
+// updateMu serializes changes to the configuration. Changes copy it and
+// swap the copy in, so readers never see a slice being modified.
+var updateMu sync.Mutex
+
 // LoadConfig loads configuration from config.yaml
 func LoadConfig() (*Config, error) {
 	// Get executable path for config location
@@ -55,6 +205,20 @@ func LoadConfig() (*Config, error) {
 		return nil, err
 	}
 
+	// Keep API keys hashed at rest, even when the rest of the file is invalid
+	if hashAPIKeys(cfg) {
+		if err := SaveConfig(cfg); err != nil {
+			return cfg, err
+		}
+	}
+
+	// Check ticket keys once, rather than on every print
+	if cfg.Tickets != nil {
+		if err := cfg.Tickets.parseKeys(); err != nil {
+			return cfg, err
+		}
+	}
+
 	return cfg, nil
 }
 
@@ -63,6 +227,21 @@ func SaveConfig(c *Config) error {
 	viper.Set("selected_printer", c.SelectedPrinter)
 	viper.Set("port", c.Port)
 	viper.Set("auto_start", c.AutoStart)
+	if len(c.Printers) > 0 {
+		viper.Set("printers", c.Printers)
+	}
+	if len(c.APIKeys) > 0 || viper.IsSet("api_keys") {
+		viper.Set("api_keys", c.APIKeys)
+	}
+	if len(c.Origins) > 0 || viper.IsSet("origins") {
+		viper.Set("origins", c.Origins)
+	}
+	if c.TLS != nil {
+		viper.Set("tls", c.TLS)
+	}
+	if c.Tickets != nil {
+		viper.Set("tickets", c.Tickets)
+	}
 
 	// Ensure config file exists
 	configFile := viper.ConfigFileUsed()
@@ -73,6 +252,14 @@ func SaveConfig(c *Config) error {
 	return viper.WriteConfigAs(configFile)
 }
 
+// Dir returns the directory config.yaml is read from
+func Dir() string {
+	if file := viper.ConfigFileUsed(); file != "" {
+		return filepath.Dir(file)
+	}
+	return "."
+}
+
 // GetConfig returns the current configuration
 func GetConfig() *Config {
 	if cfg == nil {
@@ -87,10 +274,182 @@ func GetConfig() *Config {
 
 // UpdateConfig updates and saves the configuration
 func UpdateConfig(printer string, port int, autoStart bool) error {
-	cfg = &Config{
-		SelectedPrinter: printer,
-		Port:            port,
-		AutoStart:       autoStart,
+	updateMu.Lock()
+	defer updateMu.Unlock()
+
+	updated := *GetConfig()
+	updated.SelectedPrinter = printer
+	updated.Port = port
+	updated.AutoStart = autoStart
+	cfg = &updated
+	return SaveConfig(cfg)
+}
+
+// PrintersWithBackend returns the configured printers that use the given backend
+func (c *Config) PrintersWithBackend(backend string) []PrinterConfig {
+	var printers []PrinterConfig
+	for _, p := range c.Printers {
+		if p.Backend == backend {
+			printers = append(printers, p)
+		}
+	}
+	return printers
+}
+
+// FindPrinter returns the configured printer with the given name, or nil
+func (c *Config) FindPrinter(name string) *PrinterConfig {
+	for i := range c.Printers {
+		if c.Printers[i].Name == name {
+			return &c.Printers[i]
+		}
+	}
+	return nil
+}
+
+// hashAPIKeys replaces plaintext keys with their hashes and reports
+// whether any were replaced
+func hashAPIKeys(c *Config) bool {
+	changed := false
+	for i := range c.APIKeys {
+		k := &c.APIKeys[i]
+		if k.Key != "" {
+			k.Hash = auth.HashKey(k.Key)
+			k.Key = ""
+			changed = true
+		}
+	}
+	return changed
+}
+
+// AddAPIKey adds a client key and saves the configuration
+func AddAPIKey(k APIKeyConfig) error {
+	updateMu.Lock()
+	defer updateMu.Unlock()
+
+	if k.Name == "" {
+		return fmt.Errorf("API key name is required")
+	}
+	if !auth.IsHash(k.Hash) {
+		return fmt.Errorf("API key %q has no valid hash", k.Name)
 	}
+	updated := *GetConfig()
+	if updated.FindAPIKey(k.Name) != nil {
+		return fmt.Errorf("API key %q already exists", k.Name)
+	}
+	updated.APIKeys = append(slices.Clone(updated.APIKeys), k)
+	cfg = &updated
 	return SaveConfig(cfg)
 }
+
+// RemoveAPIKey removes a client key and saves the configuration
+func RemoveAPIKey(name string) error {
+	updateMu.Lock()
+	defer updateMu.Unlock()
+
+	updated := *GetConfig()
+	for i := range updated.APIKeys {
+		if updated.APIKeys[i].Name == name {
+			updated.APIKeys = slices.Delete(slices.Clone(updated.APIKeys), i, i+1)
+			cfg = &updated
+			return SaveConfig(cfg)
+		}
+	}
+	return fmt.Errorf("API key %q not found", name)
+}
+
+// FindAPIKey returns the client key with the given name, or nil
+func (c *Config) FindAPIKey(name string) *APIKeyConfig {
+	for i := range c.APIKeys {
+		if c.APIKeys[i].Name == name {
+			return &c.APIKeys[i]
+		}
+	}
+	return nil
+}
+
+// AllowsPrinter reports whether the key may print to the printer
+func (k *APIKeyConfig) AllowsPrinter(name string) bool {
+	return len(k.Printers) == 0 || slices.Contains(k.Printers, name)
+}
+
+// AllowsType reports whether the key may submit the print type
+func (k *APIKeyConfig) AllowsType(printType string) bool {
+	return len(k.Types) == 0 || slices.Contains(k.Types, printType)
+}
+
+// SetOrigin allows or denies a browser origin and saves the configuration
+func SetOrigin(origin string, allow bool) error {
+	updateMu.Lock()
+	defer updateMu.Unlock()
+
+	if origin == "" {
+		return fmt.Errorf("origin is required")
+	}
+	updated := *GetConfig()
+	updated.Origins = slices.Clone(updated.Origins)
+	if o := updated.FindOrigin(origin); o != nil {
+		o.Allow = allow
+	} else {
+		updated.Origins = append(updated.Origins, OriginConfig{Origin: origin, Allow: allow})
+	}
+	cfg = &updated
+	return SaveConfig(cfg)
+}
+
+// RemoveOrigin forgets the decision on a browser origin and saves the
+// configuration
+func RemoveOrigin(origin string) error {
+	updateMu.Lock()
+	defer updateMu.Unlock()
+
+	updated := *GetConfig()
+	for i := range updated.Origins {
+		if updated.Origins[i].Origin == origin {
+			updated.Origins = slices.Delete(slices.Clone(updated.Origins), i, i+1)
+			cfg = &updated
+			return SaveConfig(cfg)
+		}
+	}
+	return fmt.Errorf("origin %q not found", origin)
+}
+
+// FindOrigin returns the decision on a browser origin, or nil
+func (c *Config) FindOrigin(origin string) *OriginConfig {
+	for i := range c.Origins {
+		if c.Origins[i].Origin == origin {
+			return &c.Origins[i]
+		}
+	}
+	return nil
+}
+
+// parseKeys parses the ticket keys for verification. Invalid keys are
+// left out and reported together.
+func (t *TicketConfig) parseKeys() error {
+	var errs []error
+	t.verifiers = nil
+	for _, k := range t.Keys {
+		key, err := auth.ParseTicketKey(k.ID, k.Algorithm, k.Secret, k.PublicKey)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		t.verifiers = append(t.verifiers, key)
+	}
+	return errors.Join(errs...)
+}
+
+// Verifiers returns the ticket keys that parsed at load
+func (t *TicketConfig) Verifiers() []auth.TicketKey {
+	return t.verifiers
+}
+
+// AllowsPrinter reports whether the client may print to the printer
+func (t *TLSClientConfig) AllowsPrinter(name string) bool {
+	return len(t.Printers) == 0 || slices.Contains(t.Printers, name)
+}
+
+// AllowsType reports whether the client may submit the print type
+func (t *TLSClientConfig) AllowsType(printType string) bool {
+	return len(t.Types) == 0 || slices.Contains(t.Types, printType)
+}
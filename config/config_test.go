@@ -0,0 +1,41 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestLoadConfigHashesKeysWithBadTicketKeys(t *testing.T) {
+	dir := t.TempDir()
+	data := `
+api_keys:
+  - name: erp
+    key: erp-secret
+tickets:
+  keys:
+    - algorithm: HS256
+      secret: short
+`
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	if _, err := LoadConfig(); err == nil || !strings.Contains(err.Error(), "at least 32 characters") {
+		t.Errorf("LoadConfig error = %v, want the ticket key rejected", err)
+	}
+
+	saved, err := os.ReadFile(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(saved), "erp-secret") || !strings.Contains(string(saved), "sha256:") {
+		t.Errorf("config.yaml =\n%s\nwant the API key hashed", saved)
+	}
+}
@@ -0,0 +1,97 @@
+package escpos
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/unicode/norm"
+)
+
+// DefaultSubstitute replaces characters the code page can't represent
+const DefaultSubstitute = "?"
+
+// CodePage is a single-byte character set a printer can select with ESC t
+type CodePage struct {
+	Name  string
+	Table byte // ESC t argument
+
+	charmap *charmap.Charmap
+}
+
+// codePages lists the supported encodings by config name. Table numbers
+// follow the Epson ESC/POS character code tables.
+var codePages = map[string]*CodePage{
+	"cp437":        {Name: "cp437", Table: 0, charmap: charmap.CodePage437},
+	"cp850":        {Name: "cp850", Table: 2, charmap: charmap.CodePage850},
+	"cp858":        {Name: "cp858", Table: 19, charmap: charmap.CodePage858},
+	"windows-1252": {Name: "windows-1252", Table: 16, charmap: charmap.Windows1252},
+}
+
+// LookupCodePage returns the code page with the given name
+func LookupCodePage(name string) (*CodePage, error) {
+	cp, ok := codePages[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported encoding %q (supported: %s)", name, strings.Join(CodePageNames(), ", "))
+	}
+	return cp, nil
+}
+
+// CodePageNames returns the supported encoding names
+func CodePageNames() []string {
+	names := make([]string, 0, len(codePages))
+	for name := range codePages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Select returns the ESC t command that selects the code page
+func (cp *CodePage) Select() []byte {
+	return []byte{0x1B, 0x74, cp.Table}
+}
+
+// Has reports whether the code page has the character
+func (cp *CodePage) Has(r rune) bool {
+	_, ok := cp.charmap.EncodeRune(r)
+	return ok
+}
+
+// Encode transcodes UTF-8 text to the code page. A character that can't
+// be mapped falls back to its unaccented letter, then to substitute.
+func (cp *CodePage) Encode(s string, substitute string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		if b, ok := cp.charmap.EncodeRune(r); ok {
+			out = append(out, b)
+			continue
+		}
+		if b, ok := cp.encodeBase(r); ok {
+			out = append(out, b)
+			continue
+		}
+		for _, sr := range substitute {
+			if b, ok := cp.charmap.EncodeRune(sr); ok {
+				out = append(out, b)
+			}
+		}
+	}
+	return out
+}
+
+// encodeBase encodes the base letter of an accented character, e.g. ő as o
+func (cp *CodePage) encodeBase(r rune) (byte, bool) {
+	decomposed := []rune(norm.NFD.String(string(r)))
+	if len(decomposed) < 2 {
+		return 0, false
+	}
+	for _, mark := range decomposed[1:] {
+		if !unicode.Is(unicode.Mn, mark) {
+			return 0, false
+		}
+	}
+	return cp.charmap.EncodeRune(decomposed[0])
+}
@@ -0,0 +1,70 @@
+package escpos
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		encoding   string
+		text       string
+		substitute string
+		want       []byte
+	}{
+		{encoding: "cp437", text: "Café ½", substitute: "?", want: []byte{'C', 'a', 'f', 0x82, ' ', 0xAB}},
+		{encoding: "cp437", text: "┌─┐", substitute: "?", want: []byte{0xDA, 0xC4, 0xBF}},
+		{encoding: "cp858", text: "5 €", substitute: "?", want: []byte{'5', ' ', 0xD5}},
+		{encoding: "windows-1252", text: "5 €", substitute: "?", want: []byte{'5', ' ', 0x80}},
+		// Accents the code page lacks are dropped
+		{encoding: "cp850", text: "Győr", substitute: "?", want: []byte{'G', 'y', 'o', 'r'}},
+		// Anything else is substituted, or dropped with an empty substitute
+		{encoding: "cp437", text: "5 €", substitute: "?", want: []byte{'5', ' ', '?'}},
+		{encoding: "cp437", text: "a→b", substitute: "", want: []byte{'a', 'b'}},
+		{encoding: "cp437", text: "日", substitute: "EUR", want: []byte("EUR")},
+	}
+	for _, tt := range tests {
+		cp, err := LookupCodePage(tt.encoding)
+		if err != nil {
+			t.Fatalf("LookupCodePage(%q) failed: %v", tt.encoding, err)
+		}
+		if got := cp.Encode(tt.text, tt.substitute); !bytes.Equal(got, tt.want) {
+			t.Errorf("%s Encode(%q, %q) = % X, want % X", tt.encoding, tt.text, tt.substitute, got, tt.want)
+		}
+	}
+}
+
+func TestLookupCodePage(t *testing.T) {
+	tests := map[string][]byte{
+		"cp437":        {0x1B, 0x74, 0},
+		"CP850":        {0x1B, 0x74, 2},
+		"cp858":        {0x1B, 0x74, 19},
+		"Windows-1252": {0x1B, 0x74, 16},
+	}
+	for name, want := range tests {
+		cp, err := LookupCodePage(name)
+		if err != nil {
+			t.Errorf("LookupCodePage(%q) failed: %v", name, err)
+			continue
+		}
+		if got := cp.Select(); !bytes.Equal(got, want) {
+			t.Errorf("%s Select = % X, want % X", name, got, want)
+		}
+	}
+
+	if _, err := LookupCodePage("utf-8"); err == nil {
+		t.Error("LookupCodePage accepted utf-8")
+	}
+}
+
+func TestHas(t *testing.T) {
+	cp, err := LookupCodePage("cp858")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for r, want := range map[rune]bool{'a': true, '€': true, 'ß': true, 'ő': false, '日': false} {
+		if got := cp.Has(r); got != want {
+			t.Errorf("Has(%q) = %v, want %v", r, got, want)
+		}
+	}
+}
@@ -0,0 +1,534 @@
+package escpos
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"goprint-bridge/raster"
+)
+
+// Defaults for 58mm printers
+const (
+	DefaultDotWidth   = 384
+	DefaultBufferSize = 4096 // Bytes of raster data sent per GS v 0 band
+
+	// fontWidth is the width in dots of the normal font (font A)
+	fontWidth = 12
+)
+
+// Command is one step of an ESC/POS document. Which fields apply depends
+// on Type: text, feed, rule, barcode, qr, image, cut or drawer.
+type Command struct {
+	Type string `json:"type"`
+
+	// text
+	Text      string `json:"text,omitempty"`
+	Bold      bool   `json:"bold,omitempty"`
+	Underline bool   `json:"underline,omitempty"`
+	Width     int    `json:"width,omitempty"`  // Character width multiplier 1-8, dots for image
+	Height    int    `json:"height,omitempty"` // Character height multiplier 1-8, dots for barcode
+	Align     string `json:"align,omitempty"`  // left, center, right
+	Inline    bool   `json:"inline,omitempty"` // Don't end the text with a line feed
+	Raster    bool   `json:"raster,omitempty"` // Render as an image with a TrueType font
+
+	// feed, cut
+	Lines int `json:"lines,omitempty"`
+
+	// rule
+	Char string `json:"char,omitempty"`
+
+	// barcode, qr
+	Data      string `json:"data,omitempty"`
+	Symbology string `json:"symbology,omitempty"` // code128, ean13, ean8, upca, upce, code39, code93, itf, codabar
+	HRI       string `json:"hri,omitempty"`       // none, above, below, both
+	Size      int    `json:"size,omitempty"`      // Barcode module width 2-6, QR module size 1-16
+	ECC       string `json:"ecc,omitempty"`       // QR error correction: L, M, Q, H
+
+	// image
+	Content string `json:"content,omitempty"` // Base64 PNG, JPEG, GIF or TIFF
+	Dither  string `json:"dither,omitempty"`  // threshold, floyd-steinberg, atkinson
+
+	// cut
+	Partial bool `json:"partial,omitempty"`
+
+	// drawer
+	Pin int `json:"pin,omitempty"` // 2 or 5
+}
+
+// Options describes the target printer
+type Options struct {
+	Columns    int // Characters per line in the normal font, derived from DotWidth when unset
+	DotWidth   int // Printable width in dots
+	BufferSize int // Largest raster band in bytes
+
+	CodePage   *CodePage // Character set for text, UTF-8 is sent as is when nil
+	Substitute string    // Replaces characters the code page can't represent
+
+	RasterText string   // Render text as images: "" (only when asked), auto (when not encodable) or always
+	Fonts      []string // Font files for rendered text, the built-in font is the fallback
+	FontSize   int      // Rendered font size in dots
+}
+
+// LineColumns returns the characters per line in the normal font
+func (o Options) LineColumns() int {
+	if o.Columns > 0 {
+		return o.Columns
+	}
+	if o.DotWidth > 0 {
+		return o.DotWidth / fontWidth
+	}
+	return DefaultDotWidth / fontWidth
+}
+
+// Text rendering modes
+const (
+	RasterTextAuto   = "auto"
+	RasterTextAlways = "always"
+)
+
+// rasterize reports whether text should be rendered as an image
+func (o Options) rasterize(cmd Command) bool {
+	switch {
+	case cmd.Raster || o.RasterText == RasterTextAlways:
+		return true
+	case o.RasterText == RasterTextAuto:
+		return !o.encodable(cmd.Text)
+	default:
+		return false
+	}
+}
+
+// encodable reports whether the printer's character set has every
+// character of s. Without a code page only ASCII is safe.
+func (o Options) encodable(s string) bool {
+	for _, r := range s {
+		if o.CodePage != nil {
+			if !o.CodePage.Has(r) {
+				return false
+			}
+		} else if r > unicode.MaxASCII {
+			return false
+		}
+	}
+	return true
+}
+
+// encode converts text to the configured code page
+func (o Options) encode(s string) []byte {
+	if o.CodePage == nil {
+		return []byte(s)
+	}
+	return o.CodePage.Encode(s, o.Substitute)
+}
+
+// Parse decodes a JSON list of commands
+func Parse(content string) ([]Command, error) {
+	var cmds []Command
+	if err := json.Unmarshal([]byte(content), &cmds); err != nil {
+		return nil, fmt.Errorf("invalid ESC/POS commands: %w", err)
+	}
+	if len(cmds) == 0 {
+		return nil, fmt.Errorf("no ESC/POS commands")
+	}
+	return cmds, nil
+}
+
+// Compile converts commands to ESC/POS bytes
+func Compile(cmds []Command, opts Options) ([]byte, error) {
+	if opts.DotWidth <= 0 {
+		opts.DotWidth = DefaultDotWidth
+	}
+	if opts.Columns <= 0 {
+		opts.Columns = opts.DotWidth / fontWidth
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = DefaultBufferSize
+	}
+	switch opts.RasterText {
+	case "", RasterTextAuto, RasterTextAlways:
+	default:
+		return nil, fmt.Errorf("invalid raster_text %q", opts.RasterText)
+	}
+
+	var buf bytes.Buffer
+	buf.Write(initialize)
+	if opts.CodePage != nil {
+		buf.Write(opts.CodePage.Select())
+	}
+
+	for i, cmd := range cmds {
+		var err error
+		switch cmd.Type {
+		case "text":
+			err = writeText(&buf, cmd, opts)
+		case "feed":
+			writeFeed(&buf, cmd.Lines)
+		case "rule":
+			err = writeRule(&buf, cmd, opts)
+		case "barcode":
+			err = writeBarcode(&buf, cmd)
+		case "qr":
+			err = writeQR(&buf, cmd)
+		case "image":
+			err = writeImage(&buf, cmd, opts)
+		case "cut":
+			writeCut(&buf, cmd)
+		case "drawer":
+			err = writeDrawer(&buf, cmd)
+		default:
+			err = fmt.Errorf("unknown command type")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("command %d (%s): %w", i+1, cmd.Type, err)
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ESC/POS command sequences
+var (
+	initialize   = []byte{0x1B, 0x40}       // ESC @
+	boldOn       = []byte{0x1B, 0x45, 0x01} // ESC E 1
+	boldOff      = []byte{0x1B, 0x45, 0x00} // ESC E 0
+	underlineOn  = []byte{0x1B, 0x2D, 0x01} // ESC - 1
+	underlineOff = []byte{0x1B, 0x2D, 0x00} // ESC - 0
+	sizeNormal   = []byte{0x1D, 0x21, 0x00} // GS ! 0
+)
+
+// alignments maps names to ESC a arguments
+var alignments = map[string]byte{
+	"":       0,
+	"left":   0,
+	"center": 1,
+	"right":  2,
+}
+
+// writeAlign selects the justification for the following lines
+func writeAlign(buf *bytes.Buffer, align string) error {
+	n, ok := alignments[align]
+	if !ok {
+		return fmt.Errorf("invalid align %q", align)
+	}
+	buf.Write([]byte{0x1B, 0x61, n}) // ESC a n
+	return nil
+}
+
+// writeText prints styled text. Styles are reset afterwards so they don't
+// leak into the next command.
+func writeText(buf *bytes.Buffer, cmd Command, opts Options) error {
+	if opts.rasterize(cmd) {
+		return writeTextRaster(buf, cmd, opts)
+	}
+	if err := writeAlign(buf, cmd.Align); err != nil {
+		return err
+	}
+
+	width, height := cmd.Width, cmd.Height
+	if width == 0 {
+		width = 1
+	}
+	if height == 0 {
+		height = 1
+	}
+	if width < 1 || width > 8 || height < 1 || height > 8 {
+		return fmt.Errorf("width and height must be between 1 and 8")
+	}
+
+	if cmd.Bold {
+		buf.Write(boldOn)
+	}
+	if cmd.Underline {
+		buf.Write(underlineOn)
+	}
+	if width > 1 || height > 1 {
+		buf.Write([]byte{0x1D, 0x21, byte((width-1)<<4 | (height - 1))}) // GS ! n
+	}
+
+	buf.Write(opts.encode(cmd.Text))
+	if !cmd.Inline && !strings.HasSuffix(cmd.Text, "\n") {
+		buf.WriteByte('\n')
+	}
+
+	buf.Write(boldOff)
+	buf.Write(underlineOff)
+	buf.Write(sizeNormal)
+	return nil
+}
+
+// writeTextRaster renders text with a TrueType font and prints it as an
+// image, for scripts no code page covers
+func writeTextRaster(buf *bytes.Buffer, cmd Command, opts Options) error {
+	if _, ok := alignments[cmd.Align]; !ok {
+		return fmt.Errorf("invalid align %q", cmd.Align)
+	}
+	if cmd.Height < 0 || cmd.Height > 8 {
+		return fmt.Errorf("width and height must be between 1 and 8")
+	}
+
+	font, err := raster.LoadFont(opts.Fonts...)
+	if err != nil {
+		return fmt.Errorf("failed to load fonts for rendered text: %w", err)
+	}
+
+	size := opts.FontSize
+	if size <= 0 {
+		size = raster.DefaultFontSize
+	}
+	if cmd.Height > 1 {
+		size *= cmd.Height
+	}
+
+	// The bitmap spans the paper and is aligned when it's drawn
+	bmp, err := raster.RenderText(font, strings.TrimSuffix(cmd.Text, "\n"), opts.DotWidth, raster.TextStyle{
+		Size:  size,
+		Bold:  cmd.Bold,
+		Align: cmd.Align,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render text: %w", err)
+	}
+	if err := writeAlign(buf, "left"); err != nil {
+		return err
+	}
+	WriteRaster(buf, bmp, opts.BufferSize)
+	return nil
+}
+
+// writeFeed prints and feeds n lines
+func writeFeed(buf *bytes.Buffer, lines int) {
+	if lines <= 0 {
+		lines = 1
+	}
+	for lines > 0 {
+		n := lines
+		if n > 255 {
+			n = 255
+		}
+		buf.Write([]byte{0x1B, 0x64, byte(n)}) // ESC d n
+		lines -= n
+	}
+}
+
+// writeRule prints a full-width line of a repeated character
+func writeRule(buf *bytes.Buffer, cmd Command, opts Options) error {
+	char := cmd.Char
+	if char == "" {
+		char = "-"
+	}
+	if len(char) != 1 {
+		return fmt.Errorf("rule char must be a single ASCII character")
+	}
+	if err := writeAlign(buf, "left"); err != nil {
+		return err
+	}
+	buf.WriteString(strings.Repeat(char, opts.Columns))
+	buf.WriteByte('\n')
+	return nil
+}
+
+// symbologies maps barcode names to GS k function B types
+var symbologies = map[string]byte{
+	"upca":    65,
+	"upce":    66,
+	"ean13":   67,
+	"ean8":    68,
+	"code39":  69,
+	"itf":     70,
+	"codabar": 71,
+	"code93":  72,
+	"code128": 73,
+}
+
+// hriPositions maps names to GS H arguments
+var hriPositions = map[string]byte{
+	"none":  0,
+	"above": 1,
+	"":      2,
+	"below": 2,
+	"both":  3,
+}
+
+// writeBarcode prints a 1D barcode
+func writeBarcode(buf *bytes.Buffer, cmd Command) error {
+	symbology := cmd.Symbology
+	if symbology == "" {
+		symbology = "code128"
+	}
+	m, ok := symbologies[symbology]
+	if !ok {
+		return fmt.Errorf("unsupported symbology %q", cmd.Symbology)
+	}
+	hri, ok := hriPositions[cmd.HRI]
+	if !ok {
+		return fmt.Errorf("invalid hri %q", cmd.HRI)
+	}
+
+	data := cmd.Data
+	if data == "" {
+		return fmt.Errorf("missing data")
+	}
+	if symbology == "code128" && !strings.HasPrefix(data, "{") {
+		// Select code set B unless the caller picked one
+		data = "{B" + data
+	}
+	if len(data) > 255 {
+		return fmt.Errorf("data is too long")
+	}
+
+	height := cmd.Height
+	if height == 0 {
+		height = 80
+	}
+	if height < 1 || height > 255 {
+		return fmt.Errorf("height must be between 1 and 255 dots")
+	}
+	size := cmd.Size
+	if size == 0 {
+		size = 3
+	}
+	if size < 2 || size > 6 {
+		return fmt.Errorf("size must be between 2 and 6")
+	}
+
+	if err := writeAlign(buf, cmd.Align); err != nil {
+		return err
+	}
+	buf.Write([]byte{0x1D, 0x68, byte(height)}) // GS h n
+	buf.Write([]byte{0x1D, 0x77, byte(size)})   // GS w n
+	buf.Write([]byte{0x1D, 0x48, hri})          // GS H n
+	buf.Write([]byte{0x1D, 0x6B, m, byte(len(data))})
+	buf.WriteString(data)
+	buf.WriteByte('\n')
+	return nil
+}
+
+// qrECC maps error correction levels to GS ( k arguments
+var qrECC = map[string]byte{
+	"L": 48,
+	"":  49,
+	"M": 49,
+	"Q": 50,
+	"H": 51,
+}
+
+// writeQR prints a QR code (model 2)
+func writeQR(buf *bytes.Buffer, cmd Command) error {
+	if cmd.Data == "" {
+		return fmt.Errorf("missing data")
+	}
+	ecc, ok := qrECC[strings.ToUpper(cmd.ECC)]
+	if !ok {
+		return fmt.Errorf("invalid ecc %q", cmd.ECC)
+	}
+	size := cmd.Size
+	if size == 0 {
+		size = 6
+	}
+	if size < 1 || size > 16 {
+		return fmt.Errorf("size must be between 1 and 16")
+	}
+	if len(cmd.Data) > 7089 {
+		return fmt.Errorf("data is too long")
+	}
+
+	if err := writeAlign(buf, cmd.Align); err != nil {
+		return err
+	}
+
+	// GS ( k: select model 2, module size, error correction
+	buf.Write([]byte{0x1D, 0x28, 0x6B, 0x04, 0x00, 0x31, 0x41, 0x32, 0x00})
+	buf.Write([]byte{0x1D, 0x28, 0x6B, 0x03, 0x00, 0x31, 0x43, byte(size)})
+	buf.Write([]byte{0x1D, 0x28, 0x6B, 0x03, 0x00, 0x31, 0x45, ecc})
+
+	// Store the data, then print it
+	n := len(cmd.Data) + 3
+	buf.Write([]byte{0x1D, 0x28, 0x6B, byte(n % 256), byte(n / 256), 0x31, 0x50, 0x30})
+	buf.WriteString(cmd.Data)
+	buf.Write([]byte{0x1D, 0x28, 0x6B, 0x03, 0x00, 0x31, 0x51, 0x30})
+	buf.WriteByte('\n')
+	return nil
+}
+
+// writeImage prints a raster image scaled to fit the paper
+func writeImage(buf *bytes.Buffer, cmd Command, opts Options) error {
+	img, err := raster.DecodeBase64(cmd.Content)
+	if err != nil {
+		return err
+	}
+
+	width := opts.DotWidth
+	if cmd.Width > 0 && cmd.Width < width {
+		width = cmd.Width
+	}
+	bmp, err := raster.Monochrome(raster.FitWidth(img, width), cmd.Dither)
+	if err != nil {
+		return err
+	}
+
+	if err := writeAlign(buf, cmd.Align); err != nil {
+		return err
+	}
+	WriteRaster(buf, bmp, opts.BufferSize)
+	return nil
+}
+
+// WriteRaster emits a bitmap as GS v 0 bands of at most bufferSize bytes,
+// so printers with a small receive buffer don't drop data
+func WriteRaster(buf *bytes.Buffer, bmp *raster.Bitmap, bufferSize int) {
+	if bmp.Stride == 0 {
+		return
+	}
+	rows := bufferSize / bmp.Stride
+	if rows < 1 {
+		rows = 1
+	}
+
+	for y := 0; y < bmp.Height; y += rows {
+		band := rows
+		if y+band > bmp.Height {
+			band = bmp.Height - y
+		}
+		buf.Write([]byte{
+			0x1D, 0x76, 0x30, 0x00, // GS v 0, normal density
+			byte(bmp.Stride % 256), byte(bmp.Stride / 256),
+			byte(band % 256), byte(band / 256),
+		})
+		buf.Write(bmp.Pix[y*bmp.Stride : (y+band)*bmp.Stride])
+	}
+}
+
+// writeCut feeds the paper past the cutter and cuts it
+func writeCut(buf *bytes.Buffer, cmd Command) {
+	lines := cmd.Lines
+	if lines <= 0 {
+		lines = 3
+	}
+	if lines > 255 {
+		lines = 255
+	}
+	m := byte(65) // Full cut after feeding
+	if cmd.Partial {
+		m = 66
+	}
+	buf.Write([]byte{0x1D, 0x56, m, byte(lines)}) // GS V m n
+}
+
+// writeDrawer pulses the cash drawer kick-out connector
+func writeDrawer(buf *bytes.Buffer, cmd Command) error {
+	var m byte
+	switch cmd.Pin {
+	case 0, 2:
+		m = 0
+	case 5:
+		m = 1
+	default:
+		return fmt.Errorf("pin must be 2 or 5")
+	}
+	// On 50ms, off 500ms (units of 2ms)
+	buf.Write([]byte{0x1B, 0x70, m, 25, 250}) // ESC p m t1 t2
+	return nil
+}
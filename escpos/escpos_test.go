@@ -0,0 +1,152 @@
+package escpos
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// join concatenates command sequences
+func join(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func TestCompile(t *testing.T) {
+	reset := join(boldOff, underlineOff, sizeNormal)
+
+	tests := []struct {
+		name string
+		cmds []Command
+		opts Options
+		want []byte
+	}{
+		{
+			name: "styled text",
+			cmds: []Command{{Type: "text", Text: "Total", Bold: true, Width: 2, Height: 2, Align: "right"}},
+			want: join(
+				[]byte{0x1B, 0x61, 2},
+				boldOn,
+				[]byte{0x1D, 0x21, 0x11},
+				[]byte("Total\n"),
+				reset,
+			),
+		},
+		{
+			name: "inline text",
+			cmds: []Command{{Type: "text", Text: "Qty ", Inline: true}, {Type: "text", Text: "2", Underline: true}},
+			want: join(
+				[]byte{0x1B, 0x61, 0}, []byte("Qty "), reset,
+				[]byte{0x1B, 0x61, 0}, underlineOn, []byte("2\n"), reset,
+			),
+		},
+		{
+			name: "rule in the configured columns",
+			cmds: []Command{{Type: "rule", Char: "="}},
+			opts: Options{Columns: 8},
+			want: join([]byte{0x1B, 0x61, 0}, []byte("========\n")),
+		},
+		{
+			name: "rule in the columns of the paper",
+			cmds: []Command{{Type: "rule"}},
+			opts: Options{DotWidth: 96},
+			want: join([]byte{0x1B, 0x61, 0}, []byte("--------\n")),
+		},
+		{
+			name: "long feed",
+			cmds: []Command{{Type: "feed", Lines: 300}},
+			want: []byte{0x1B, 0x64, 255, 0x1B, 0x64, 45},
+		},
+		{
+			name: "code128 barcode",
+			cmds: []Command{{Type: "barcode", Data: "A-1", Align: "center"}},
+			want: join(
+				[]byte{0x1B, 0x61, 1},
+				[]byte{0x1D, 0x68, 80, 0x1D, 0x77, 3, 0x1D, 0x48, 2},
+				[]byte{0x1D, 0x6B, 73, 5},
+				[]byte("{BA-1\n"),
+			),
+		},
+		{
+			name: "QR code",
+			cmds: []Command{{Type: "qr", Data: "hi", ECC: "h", Size: 4}},
+			want: join(
+				[]byte{0x1B, 0x61, 0},
+				[]byte{0x1D, 0x28, 0x6B, 0x04, 0x00, 0x31, 0x41, 0x32, 0x00},
+				[]byte{0x1D, 0x28, 0x6B, 0x03, 0x00, 0x31, 0x43, 4},
+				[]byte{0x1D, 0x28, 0x6B, 0x03, 0x00, 0x31, 0x45, 51},
+				[]byte{0x1D, 0x28, 0x6B, 5, 0, 0x31, 0x50, 0x30},
+				[]byte("hi"),
+				[]byte{0x1D, 0x28, 0x6B, 0x03, 0x00, 0x31, 0x51, 0x30, '\n'},
+			),
+		},
+		{
+			name: "partial cut and drawer",
+			cmds: []Command{{Type: "cut", Partial: true}, {Type: "drawer", Pin: 5}},
+			want: []byte{0x1D, 0x56, 66, 3, 0x1B, 0x70, 1, 25, 250},
+		},
+		{
+			name: "code page",
+			cmds: []Command{{Type: "text", Text: "5 €"}},
+			opts: Options{CodePage: codePages["cp858"], Substitute: "?"},
+			want: join(
+				[]byte{0x1B, 0x74, 19},
+				[]byte{0x1B, 0x61, 0}, []byte{'5', ' ', 0xD5, '\n'}, reset,
+			),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Compile(tt.cmds, tt.opts)
+			if err != nil {
+				t.Fatalf("Compile failed: %v", err)
+			}
+			want := join(initialize, tt.want)
+			if !bytes.Equal(got, want) {
+				t.Errorf("Compile = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  Command
+		want string
+	}{
+		{name: "unknown type", cmd: Command{Type: "beep"}, want: "unknown command type"},
+		{name: "text align", cmd: Command{Type: "text", Text: "x", Align: "justify"}, want: `invalid align "justify"`},
+		{name: "text size", cmd: Command{Type: "text", Text: "x", Width: 9}, want: "between 1 and 8"},
+		{name: "rule char", cmd: Command{Type: "rule", Char: "=="}, want: "single ASCII character"},
+		{name: "barcode symbology", cmd: Command{Type: "barcode", Data: "1", Symbology: "pdf417"}, want: "unsupported symbology"},
+		{name: "barcode data", cmd: Command{Type: "barcode"}, want: "missing data"},
+		{name: "barcode size", cmd: Command{Type: "barcode", Data: "1", Size: 7}, want: "between 2 and 6"},
+		{name: "qr ecc", cmd: Command{Type: "qr", Data: "1", ECC: "X"}, want: `invalid ecc "X"`},
+		{name: "drawer pin", cmd: Command{Type: "drawer", Pin: 3}, want: "pin must be 2 or 5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile([]Command{{Type: "feed"}, tt.cmd}, Options{})
+			if err == nil {
+				t.Fatal("Compile succeeded, want an error")
+			}
+			if !strings.HasPrefix(err.Error(), "command 2 ("+tt.cmd.Type+")") || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %q, want command 2 and %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	cmds, err := Parse(`[{"type":"text","text":"Hi","bold":true},{"type":"cut"}]`)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(cmds) != 2 || cmds[0].Text != "Hi" || !cmds[0].Bold || cmds[1].Type != "cut" {
+		t.Errorf("Parse = %+v, want bold text and a cut", cmds)
+	}
+
+	if _, err := Parse(`{"type":"text"}`); err == nil {
+		t.Error("Parse accepted an object instead of a list")
+	}
+}
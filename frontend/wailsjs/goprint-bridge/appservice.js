@@ -19,6 +19,47 @@ import * as config$0 from "./config/models.js";
 // @ts-ignore: Unused imports
 import * as $models from "./models.js";
 
+/**
+ * CreateAPIKey creates a client key with the given scopes and returns it.
+ * Only its hash is saved, so the key can't be shown again.
+ * @param {string} name
+ * @param {string[]} printers
+ * @param {string[]} types
+ * @param {number} maxPayload
+ * @returns {$CancellablePromise<string>}
+ */
+export function CreateAPIKey(name, printers, types, maxPayload) {
+    return $Call.ByID(3851151521, name, printers, types, maxPayload);
+}
+
+/**
+ * DeleteAPIKey revokes a client key
+ * @param {string} name
+ * @returns {$CancellablePromise<void>}
+ */
+export function DeleteAPIKey(name) {
+    return $Call.ByID(180300654, name);
+}
+
+/**
+ * ForgetOrigin removes a browser origin from the allowlist
+ * @param {string} origin
+ * @returns {$CancellablePromise<void>}
+ */
+export function ForgetOrigin(origin) {
+    return $Call.ByID(2663778501, origin);
+}
+
+/**
+ * ExportCA saves the local CA certificate to dest, to be trusted by hand
+ * on Windows, macOS or other machines
+ * @param {string} dest
+ * @returns {$CancellablePromise<void>}
+ */
+export function ExportCA(dest) {
+    return $Call.ByID(2630733360, dest);
+}
+
 /**
  * GetAutoStartStatus returns whether autostart is enabled
  * @returns {$CancellablePromise<boolean>}
@@ -47,6 +88,15 @@ export function GetPrinters() {
     }));
 }
 
+/**
+ * InstallCA trusts the local CA in the system store and in the browsers'
+ * NSS databases (Linux)
+ * @returns {$CancellablePromise<void>}
+ */
+export function InstallCA() {
+    return $Call.ByID(3516297613);
+}
+
 /**
  * IsServerRunning returns whether the server is running
  * @returns {$CancellablePromise<boolean>}
@@ -63,6 +113,16 @@ export function MinimizeToTray() {
     return $Call.ByID(1496580499);
 }
 
+/**
+ * PendingOrigins returns the browser origins waiting for approval
+ * @returns {$CancellablePromise<string[]>}
+ */
+export function PendingOrigins() {
+    return $Call.ByID(1203412908).then(/** @type {($result: any) => any} */(($result) => {
+        return $$createType3($result);
+    }));
+}
+
 /**
  * PrintTestPage prints a test page to verify printer is working
  * @returns {$CancellablePromise<void>}
@@ -79,6 +139,16 @@ export function QuitApp() {
     return $Call.ByID(3291959616);
 }
 
+/**
+ * RespondOrigin answers an origin-approval event: once, always or never
+ * @param {string} origin
+ * @param {string} decision
+ * @returns {$CancellablePromise<void>}
+ */
+export function RespondOrigin(origin, decision) {
+    return $Call.ByID(3890867301, origin, decision);
+}
+
 /**
  * SaveConfig saves the configuration
  * @param {string} printerName
@@ -136,3 +206,4 @@ export function StopServer() {
 const $$createType0 = config$0.Config.createFrom;
 const $$createType1 = $models.Printer.createFrom;
 const $$createType2 = $Create.Array($$createType1);
+const $$createType3 = $Create.Array($Create.Any);
@@ -0,0 +1,207 @@
+package ipp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Job states (RFC 8011 section 5.3.7)
+const (
+	JobStatePending           = 3
+	JobStatePendingHeld       = 4
+	JobStateProcessing        = 5
+	JobStateProcessingStopped = 6
+	JobStateCanceled          = 7
+	JobStateAborted           = 8
+	JobStateCompleted         = 9
+)
+
+// Printer states (RFC 8011 section 5.4.12)
+const (
+	PrinterStateIdle       = 3
+	PrinterStateProcessing = 4
+	PrinterStateStopped    = 5
+)
+
+// Client sends IPP requests over HTTP
+type Client struct {
+	HTTP *http.Client
+	User string
+
+	requestID uint32
+}
+
+// JobInfo is the subset of job attributes the bridge tracks
+type JobInfo struct {
+	ID                   int
+	State                int
+	StateReasons         []string
+	ImpressionsCompleted int
+}
+
+// NewClient creates a client with the given request timeout
+func NewClient(timeout time.Duration) *Client {
+	return &Client{
+		HTTP: &http.Client{Timeout: timeout},
+		User: "goprint-bridge",
+	}
+}
+
+// PrintJob sends a document with Print-Job and returns the job ID
+func (c *Client) PrintJob(printerURI string, document []byte, format string, jobName string, jobAttrs []Attribute) (int, error) {
+	req := c.newRequest(OpPrintJob, printerURI)
+	op := req.Group(TagOperationGroup)
+	op.Add(String("requesting-user-name", TagName, c.User))
+	if jobName != "" {
+		op.Add(String("job-name", TagName, jobName))
+	}
+	op.Add(String("document-format", TagMimeType, format))
+	if len(jobAttrs) > 0 {
+		req.Group(TagJobGroup).Add(jobAttrs...)
+	}
+
+	resp, err := c.Do(printerURI, req, document)
+	if err != nil {
+		return 0, err
+	}
+
+	id, ok := resp.Find("job-id")
+	if !ok {
+		return 0, fmt.Errorf("response has no job-id")
+	}
+	return id.Int(), nil
+}
+
+// GetJobAttributes reads the state of a job
+func (c *Client) GetJobAttributes(printerURI string, jobID int) (JobInfo, error) {
+	req := c.newRequest(OpGetJobAttributes, printerURI)
+	op := req.Group(TagOperationGroup)
+	op.Add(
+		Integer("job-id", TagInteger, jobID),
+		String("requesting-user-name", TagName, c.User),
+		String("requested-attributes", TagKeyword, "job-id", "job-state", "job-state-reasons", "job-impressions-completed"),
+	)
+
+	resp, err := c.Do(printerURI, req, nil)
+	if err != nil {
+		return JobInfo{}, err
+	}
+
+	info := JobInfo{ID: jobID}
+	if a, ok := resp.Find("job-state"); ok {
+		info.State = a.Int()
+	}
+	if a, ok := resp.Find("job-state-reasons"); ok {
+		info.StateReasons = a.Strings()
+	}
+	if a, ok := resp.Find("job-impressions-completed"); ok {
+		info.ImpressionsCompleted = a.Int()
+	}
+	return info, nil
+}
+
+// CancelJob cancels a job
+func (c *Client) CancelJob(printerURI string, jobID int) error {
+	req := c.newRequest(OpCancelJob, printerURI)
+	req.Group(TagOperationGroup).Add(
+		Integer("job-id", TagInteger, jobID),
+		String("requesting-user-name", TagName, c.User),
+	)
+
+	_, err := c.Do(printerURI, req, nil)
+	return err
+}
+
+// GetPrinterAttributes reads the requested printer attributes
+func (c *Client) GetPrinterAttributes(printerURI string, names ...string) (*Message, error) {
+	req := c.newRequest(OpGetPrinterAttributes, printerURI)
+	if len(names) > 0 {
+		req.Group(TagOperationGroup).Add(String("requested-attributes", TagKeyword, names...))
+	}
+	return c.Do(printerURI, req, nil)
+}
+
+// Do posts a request with an optional document and decodes the response.
+// Responses with an error status are returned as errors.
+func (c *Client) Do(printerURI string, req *Message, document []byte) (*Message, error) {
+	endpoint, err := HTTPURL(printerURI)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := req.Encode()
+	if err != nil {
+		return nil, err
+	}
+	body = append(body, document...)
+
+	httpReq, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/ipp")
+
+	httpResp, err := c.HTTP.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("IPP request failed: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, httpResp.Body)
+		return nil, fmt.Errorf("IPP request failed: HTTP %s", httpResp.Status)
+	}
+
+	resp, err := Decode(httpResp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// 0x0000-0x00FF are successful-ok statuses
+	if resp.Code > 0x00FF {
+		msg := fmt.Sprintf("status 0x%04x", resp.Code)
+		if a, ok := resp.Find("status-message"); ok && a.String() != "" {
+			msg = a.String()
+		}
+		return resp, fmt.Errorf("IPP request failed: %s", msg)
+	}
+	return resp, nil
+}
+
+// newRequest creates a request addressed to the printer
+func (c *Client) newRequest(op uint16, printerURI string) *Message {
+	req := NewRequest(op, atomic.AddUint32(&c.requestID, 1))
+	req.Group(TagOperationGroup).Add(String("printer-uri", TagURI, printerURI))
+	return req
+}
+
+// HTTPURL converts an ipp:// or ipps:// printer URI to the HTTP URL the
+// request is posted to. http:// and https:// URLs are used as they are.
+func HTTPURL(printerURI string) (string, error) {
+	u, err := url.Parse(printerURI)
+	if err != nil {
+		return "", fmt.Errorf("invalid printer URI: %w", err)
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "ipp":
+		u.Scheme = "http"
+	case "ipps":
+		u.Scheme = "https"
+	case "http", "https":
+		return u.String(), nil
+	default:
+		return "", fmt.Errorf("unsupported printer URI scheme %q", u.Scheme)
+	}
+
+	if u.Port() == "" {
+		u.Host += ":631"
+	}
+	return u.String(), nil
+}
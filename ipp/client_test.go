@@ -0,0 +1,326 @@
+package ipp
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Status codes the stand-in printer answers with
+const (
+	statusOK                  uint16 = 0x0000
+	statusNotFound            uint16 = 0x0406
+	statusDocumentFormatError uint16 = 0x040A
+	statusServerErrorBusy     uint16 = 0x0507
+)
+
+// standInPrinter is a minimal IPP printer: it accepts Print-Job, reports
+// jobs as pending, processing and then completed on each
+// Get-Job-Attributes, and fails requests on some paths
+type standInPrinter struct {
+	t *testing.T
+
+	mu       sync.Mutex
+	requests []*Message
+	document []byte
+	polls    int
+}
+
+func newStandInPrinter(t *testing.T) (*standInPrinter, *httptest.Server) {
+	p := &standInPrinter{t: t}
+	srv := httptest.NewServer(p)
+	t.Cleanup(srv.Close)
+	return p, srv
+}
+
+func (p *standInPrinter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/ipp" {
+		http.Error(w, "not IPP", http.StatusBadRequest)
+		return
+	}
+	if r.URL.Path == "/broken" {
+		http.Error(w, "printer exploded", http.StatusInternalServerError)
+		return
+	}
+
+	req, err := Decode(r.Body)
+	if err != nil {
+		p.t.Errorf("failed to decode request: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	document, _ := io.ReadAll(r.Body)
+
+	p.mu.Lock()
+	p.requests = append(p.requests, req)
+	resp := p.respond(r.URL.Path, req, document)
+	p.mu.Unlock()
+
+	body, err := resp.Encode()
+	if err != nil {
+		p.t.Errorf("failed to encode response: %v", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/ipp")
+	w.Write(body)
+}
+
+// respond builds the response to a request, with the request's ID
+func (p *standInPrinter) respond(path string, req *Message, document []byte) *Message {
+	resp := NewRequest(statusOK, req.RequestID)
+
+	switch path {
+	case "/busy":
+		resp.Code = statusServerErrorBusy
+		resp.Group(TagOperationGroup).Add(String("status-message", TagText, "printer is busy"))
+		return resp
+	case "/missing":
+		resp.Code = statusNotFound
+		return resp
+	case "/no-job-id":
+		return resp
+	}
+
+	switch req.Code {
+	case OpPrintJob:
+		if format, _ := req.Find("document-format"); format.String() != "application/pdf" {
+			resp.Code = statusDocumentFormatError
+			return resp
+		}
+		p.document = document
+		resp.Group(TagJobGroup).Add(
+			Integer("job-id", TagInteger, 42),
+			Integer("job-state", TagEnum, JobStatePending),
+		)
+	case OpGetJobAttributes:
+		p.polls++
+		state, impressions := JobStatePending, 0
+		switch {
+		case p.polls == 2:
+			state, impressions = JobStateProcessing, 1
+		case p.polls >= 3:
+			state, impressions = JobStateCompleted, 2
+		}
+		id, _ := req.Find("job-id")
+		resp.Group(TagJobGroup).Add(
+			Integer("job-id", TagInteger, id.Int()),
+			Integer("job-state", TagEnum, state),
+			String("job-state-reasons", TagKeyword, "none", "job-printing"),
+			Integer("job-impressions-completed", TagInteger, impressions),
+		)
+	case OpCancelJob:
+	default:
+		resp.Code = 0x0501 // server-error-operation-not-supported
+	}
+	return resp
+}
+
+func TestPrintJob(t *testing.T) {
+	p, srv := newStandInPrinter(t)
+	c := NewClient(5 * time.Second)
+
+	document := []byte("%PDF-1.4 test")
+	jobAttrs := []Attribute{Integer("copies", TagInteger, 2)}
+	id, err := c.PrintJob(srv.URL+"/ipp/print", document, "application/pdf", "Invoice", jobAttrs)
+	if err != nil {
+		t.Fatalf("PrintJob failed: %v", err)
+	}
+	if id != 42 {
+		t.Errorf("job id = %d, want 42", id)
+	}
+	if !bytes.Equal(p.document, document) {
+		t.Errorf("document = %q, want %q", p.document, document)
+	}
+
+	req := p.requests[0]
+	if req.Code != OpPrintJob {
+		t.Errorf("operation = 0x%04x, want Print-Job", req.Code)
+	}
+	for name, want := range map[string]string{
+		"attributes-charset":   "utf-8",
+		"printer-uri":          srv.URL + "/ipp/print",
+		"requesting-user-name": "goprint-bridge",
+		"job-name":             "Invoice",
+	} {
+		if a, _ := req.Find(name); a.String() != want {
+			t.Errorf("%s = %q, want %q", name, a.String(), want)
+		}
+	}
+	if copies, _ := req.Group(TagJobGroup).Get("copies"); copies.Int() != 2 {
+		t.Errorf("copies = %d, want 2", copies.Int())
+	}
+}
+
+func TestPrintJobWithoutJobID(t *testing.T) {
+	_, srv := newStandInPrinter(t)
+	c := NewClient(5 * time.Second)
+
+	if _, err := c.PrintJob(srv.URL+"/no-job-id", []byte("x"), "application/pdf", "", nil); err == nil {
+		t.Fatal("PrintJob succeeded without a job-id in the response")
+	}
+}
+
+func TestGetJobAttributesPolling(t *testing.T) {
+	_, srv := newStandInPrinter(t)
+	c := NewClient(5 * time.Second)
+
+	var states []int
+	var info JobInfo
+	for len(states) < 5 {
+		var err error
+		info, err = c.GetJobAttributes(srv.URL, 42)
+		if err != nil {
+			t.Fatalf("GetJobAttributes failed: %v", err)
+		}
+		states = append(states, info.State)
+		if info.State == JobStateCompleted {
+			break
+		}
+	}
+
+	want := []int{JobStatePending, JobStateProcessing, JobStateCompleted}
+	if len(states) != len(want) {
+		t.Fatalf("states = %v, want %v", states, want)
+	}
+	for i := range want {
+		if states[i] != want[i] {
+			t.Fatalf("states = %v, want %v", states, want)
+		}
+	}
+	if info.ID != 42 || info.ImpressionsCompleted != 2 {
+		t.Errorf("info = %+v, want job 42 with 2 impressions", info)
+	}
+	if strings.Join(info.StateReasons, ",") != "none,job-printing" {
+		t.Errorf("state reasons = %v, want [none job-printing]", info.StateReasons)
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	_, srv := newStandInPrinter(t)
+	c := NewClient(5 * time.Second)
+
+	tests := []struct {
+		name string
+		call func() error
+		want string
+	}{
+		{
+			name: "status message",
+			call: func() error { return c.CancelJob(srv.URL+"/busy", 42) },
+			want: "printer is busy",
+		},
+		{
+			name: "status code",
+			call: func() error {
+				_, err := c.GetJobAttributes(srv.URL+"/missing", 42)
+				return err
+			},
+			want: "status 0x0406",
+		},
+		{
+			name: "unsupported format",
+			call: func() error {
+				_, err := c.PrintJob(srv.URL, []byte("x"), "text/plain", "", nil)
+				return err
+			},
+			want: "status 0x040a",
+		},
+		{
+			name: "HTTP error",
+			call: func() error { return c.CancelJob(srv.URL+"/broken", 42) },
+			want: "HTTP 500",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			if err == nil {
+				t.Fatal("request succeeded, want an error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	m := NewRequest(OpGetPrinterAttributes, 7)
+	m.Group(TagOperationGroup).Add(String("requested-attributes", TagKeyword, "printer-state", "printer-name"))
+	m.Group(TagPrinterGroup).Add(
+		Integer("printer-state", TagEnum, PrinterStateIdle),
+		Boolean("color-supported", true),
+		RangeOfInteger("copies-supported", Range{Lower: 1, Upper: 99}),
+	)
+
+	data, err := m.Encode()
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	// Document data after the attributes is left unread
+	r := bytes.NewReader(append(data, "document"...))
+	decoded, err := Decode(r)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if rest, _ := io.ReadAll(r); string(rest) != "document" {
+		t.Errorf("remaining data = %q, want the document", rest)
+	}
+
+	if decoded.Major != 1 || decoded.Minor != 1 || decoded.Code != OpGetPrinterAttributes || decoded.RequestID != 7 {
+		t.Errorf("header = %d.%d 0x%04x #%d, want 1.1 0x000b #7", decoded.Major, decoded.Minor, decoded.Code, decoded.RequestID)
+	}
+	if a, _ := decoded.Find("requested-attributes"); strings.Join(a.Strings(), ",") != "printer-state,printer-name" {
+		t.Errorf("requested-attributes = %v", a.Strings())
+	}
+	if a, _ := decoded.Find("printer-state"); a.Int() != PrinterStateIdle {
+		t.Errorf("printer-state = %d, want %d", a.Int(), PrinterStateIdle)
+	}
+	if a, _ := decoded.Find("color-supported"); len(a.Values) != 1 || a.Values[0].Data[0] != 1 {
+		t.Errorf("color-supported = %v, want true", a.Values)
+	}
+	if a, _ := decoded.Find("copies-supported"); len(a.Values) != 1 || !bytes.Equal(a.Values[0].Data, []byte{0, 0, 0, 1, 0, 0, 0, 99}) {
+		t.Errorf("copies-supported = %v, want 1-99", a.Values)
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	data, err := NewRequest(OpPrintJob, 1).Encode()
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	for _, n := range []int{4, len(data) - 1} {
+		if _, err := Decode(bytes.NewReader(data[:n])); err == nil {
+			t.Errorf("Decode of %d of %d bytes succeeded", n, len(data))
+		}
+	}
+}
+
+func TestHTTPURL(t *testing.T) {
+	tests := map[string]string{
+		"ipp://printer.local/ipp/print":       "http://printer.local:631/ipp/print",
+		"ipps://printer.local/ipp/print":      "https://printer.local:631/ipp/print",
+		"ipp://192.168.1.20:8631/printers/a":  "http://192.168.1.20:8631/printers/a",
+		"http://printer.local:631/ipp/print":  "http://printer.local:631/ipp/print",
+		"https://printer.local:443/ipp/print": "https://printer.local:443/ipp/print",
+	}
+	for uri, want := range tests {
+		got, err := HTTPURL(uri)
+		if err != nil {
+			t.Errorf("HTTPURL(%q) failed: %v", uri, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("HTTPURL(%q) = %q, want %q", uri, got, want)
+		}
+	}
+
+	if _, err := HTTPURL("lpd://printer.local/queue"); err == nil {
+		t.Error("HTTPURL accepted an lpd:// URI")
+	}
+}
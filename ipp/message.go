@@ -0,0 +1,317 @@
+package ipp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Operation IDs (RFC 8011 section 5.4.15)
+const (
+	OpPrintJob             uint16 = 0x0002
+	OpCancelJob            uint16 = 0x0008
+	OpGetJobAttributes     uint16 = 0x0009
+	OpGetPrinterAttributes uint16 = 0x000B
+)
+
+// Delimiter tags that start attribute groups
+const (
+	TagOperationGroup   byte = 0x01
+	TagJobGroup         byte = 0x02
+	TagEndOfAttributes  byte = 0x03
+	TagPrinterGroup     byte = 0x04
+	TagUnsupportedGroup byte = 0x05
+)
+
+// Value tags
+const (
+	TagUnsupportedValue byte = 0x10
+	TagUnknown          byte = 0x12
+	TagNoValue          byte = 0x13
+	TagInteger          byte = 0x21
+	TagBoolean          byte = 0x22
+	TagEnum             byte = 0x23
+	TagOctetString      byte = 0x30
+	TagDateTime         byte = 0x31
+	TagResolution       byte = 0x32
+	TagRangeOfInteger   byte = 0x33
+	TagBeginCollection  byte = 0x34
+	TagTextWithLanguage byte = 0x35
+	TagNameWithLanguage byte = 0x36
+	TagEndCollection    byte = 0x37
+	TagText             byte = 0x41
+	TagName             byte = 0x42
+	TagKeyword          byte = 0x44
+	TagURI              byte = 0x45
+	TagURIScheme        byte = 0x46
+	TagCharset          byte = 0x47
+	TagLanguage         byte = 0x48
+	TagMimeType         byte = 0x49
+	TagMemberName       byte = 0x4A
+)
+
+// Value is a single encoded attribute value
+type Value struct {
+	Tag  byte
+	Data []byte
+}
+
+// Attribute is a named attribute with one or more values
+type Attribute struct {
+	Name   string
+	Values []Value
+}
+
+// Group is a list of attributes under a delimiter tag
+type Group struct {
+	Tag        byte
+	Attributes []Attribute
+}
+
+// Message is an IPP request or response. Code holds the operation ID in
+// requests and the status code in responses.
+type Message struct {
+	Major     byte
+	Minor     byte
+	Code      uint16
+	RequestID uint32
+	Groups    []Group
+}
+
+// NewRequest creates an IPP/1.1 request with the mandatory charset and
+// language operation attributes
+func NewRequest(op uint16, requestID uint32) *Message {
+	return &Message{
+		Major:     1,
+		Minor:     1,
+		Code:      op,
+		RequestID: requestID,
+		Groups: []Group{{
+			Tag: TagOperationGroup,
+			Attributes: []Attribute{
+				String("attributes-charset", TagCharset, "utf-8"),
+				String("attributes-natural-language", TagLanguage, "en"),
+			},
+		}},
+	}
+}
+
+// String creates an attribute with string values of the given tag
+func String(name string, tag byte, values ...string) Attribute {
+	a := Attribute{Name: name}
+	for _, v := range values {
+		a.Values = append(a.Values, Value{Tag: tag, Data: []byte(v)})
+	}
+	return a
+}
+
+// Integer creates an integer (or enum, with TagEnum) attribute
+func Integer(name string, tag byte, values ...int) Attribute {
+	a := Attribute{Name: name}
+	for _, v := range values {
+		data := make([]byte, 4)
+		binary.BigEndian.PutUint32(data, uint32(int32(v)))
+		a.Values = append(a.Values, Value{Tag: tag, Data: data})
+	}
+	return a
+}
+
+// Boolean creates a boolean attribute
+func Boolean(name string, value bool) Attribute {
+	data := []byte{0}
+	if value {
+		data[0] = 1
+	}
+	return Attribute{Name: name, Values: []Value{{Tag: TagBoolean, Data: data}}}
+}
+
+// Range is a rangeOfInteger value
+type Range struct {
+	Lower int
+	Upper int
+}
+
+// RangeOfInteger creates a rangeOfInteger attribute
+func RangeOfInteger(name string, ranges ...Range) Attribute {
+	a := Attribute{Name: name}
+	for _, r := range ranges {
+		data := make([]byte, 8)
+		binary.BigEndian.PutUint32(data[:4], uint32(int32(r.Lower)))
+		binary.BigEndian.PutUint32(data[4:], uint32(int32(r.Upper)))
+		a.Values = append(a.Values, Value{Tag: TagRangeOfInteger, Data: data})
+	}
+	return a
+}
+
+// Group returns the first group with the given tag, adding one if missing
+func (m *Message) Group(tag byte) *Group {
+	for i := range m.Groups {
+		if m.Groups[i].Tag == tag {
+			return &m.Groups[i]
+		}
+	}
+	m.Groups = append(m.Groups, Group{Tag: tag})
+	return &m.Groups[len(m.Groups)-1]
+}
+
+// Find returns the first attribute with the given name in any group
+func (m *Message) Find(name string) (Attribute, bool) {
+	for _, g := range m.Groups {
+		if a, ok := g.Get(name); ok {
+			return a, true
+		}
+	}
+	return Attribute{}, false
+}
+
+// Add appends attributes to the group
+func (g *Group) Add(attrs ...Attribute) {
+	g.Attributes = append(g.Attributes, attrs...)
+}
+
+// Get returns the attribute with the given name
+func (g Group) Get(name string) (Attribute, bool) {
+	for _, a := range g.Attributes {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return Attribute{}, false
+}
+
+// Int returns the first value as an integer
+func (a Attribute) Int() int {
+	if len(a.Values) == 0 || len(a.Values[0].Data) != 4 {
+		return 0
+	}
+	return int(int32(binary.BigEndian.Uint32(a.Values[0].Data)))
+}
+
+// String returns the first value as a string
+func (a Attribute) String() string {
+	if len(a.Values) == 0 {
+		return ""
+	}
+	return string(a.Values[0].Data)
+}
+
+// Strings returns all values as strings
+func (a Attribute) Strings() []string {
+	values := make([]string, 0, len(a.Values))
+	for _, v := range a.Values {
+		values = append(values, string(v.Data))
+	}
+	return values
+}
+
+// Encode serializes the message header and attributes. Document data, if
+// any, follows the encoded bytes.
+func (m *Message) Encode() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write([]byte{m.Major, m.Minor})
+	binary.Write(&buf, binary.BigEndian, m.Code)
+	binary.Write(&buf, binary.BigEndian, m.RequestID)
+
+	for _, g := range m.Groups {
+		buf.WriteByte(g.Tag)
+		for _, a := range g.Attributes {
+			if len(a.Values) == 0 {
+				return nil, fmt.Errorf("attribute %q has no values", a.Name)
+			}
+			for i, v := range a.Values {
+				name := a.Name
+				if i > 0 {
+					// Additional values carry an empty name
+					name = ""
+				}
+				if len(name) > 0xFFFF || len(v.Data) > 0xFFFF {
+					return nil, fmt.Errorf("attribute %q is too long", a.Name)
+				}
+				buf.WriteByte(v.Tag)
+				binary.Write(&buf, binary.BigEndian, uint16(len(name)))
+				buf.WriteString(name)
+				binary.Write(&buf, binary.BigEndian, uint16(len(v.Data)))
+				buf.Write(v.Data)
+			}
+		}
+	}
+	buf.WriteByte(TagEndOfAttributes)
+
+	return buf.Bytes(), nil
+}
+
+// Decode reads a message header and attributes from r. Any document data
+// is left unread. Collection members are kept as extra values of the
+// collection attribute.
+func Decode(r io.Reader) (*Message, error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("failed to read IPP header: %w", err)
+	}
+
+	m := &Message{
+		Major:     header[0],
+		Minor:     header[1],
+		Code:      binary.BigEndian.Uint16(header[2:4]),
+		RequestID: binary.BigEndian.Uint32(header[4:8]),
+	}
+
+	var group *Group
+	tag := make([]byte, 1)
+	for {
+		if _, err := io.ReadFull(r, tag); err != nil {
+			return nil, fmt.Errorf("failed to read IPP tag: %w", err)
+		}
+
+		switch {
+		case tag[0] == TagEndOfAttributes:
+			return m, nil
+		case tag[0] < 0x10:
+			// Delimiter: start a new group
+			m.Groups = append(m.Groups, Group{Tag: tag[0]})
+			group = &m.Groups[len(m.Groups)-1]
+			continue
+		}
+
+		if group == nil {
+			return nil, fmt.Errorf("attribute outside of a group")
+		}
+
+		name, err := readField(r)
+		if err != nil {
+			return nil, err
+		}
+		data, err := readField(r)
+		if err != nil {
+			return nil, err
+		}
+
+		value := Value{Tag: tag[0], Data: data}
+		if len(name) == 0 {
+			if len(group.Attributes) == 0 {
+				return nil, fmt.Errorf("additional value without attribute")
+			}
+			last := &group.Attributes[len(group.Attributes)-1]
+			last.Values = append(last.Values, value)
+			continue
+		}
+		group.Attributes = append(group.Attributes, Attribute{
+			Name:   string(name),
+			Values: []Value{value},
+		})
+	}
+}
+
+// readField reads a two-byte length followed by that many bytes
+func readField(r io.Reader) ([]byte, error) {
+	var length uint16
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return nil, fmt.Errorf("failed to read IPP field length: %w", err)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("failed to read IPP field: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,297 @@
+package jobstore
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	journalFile = "journal.log"
+	payloadDir  = "payloads"
+
+	// compactAfter is the number of appended records that triggers a compaction
+	compactAfter = 1000
+)
+
+// Record is the persisted state of a print job
+type Record struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	Printer   string          `json:"printer"`
+	Options   json.RawMessage `json:"options,omitempty"`
+	State     string          `json:"state"`
+	BackendID string          `json:"backend_job_id,omitempty"`
+	Final     bool            `json:"final"`
+	Error     string          `json:"error,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// Store is a write-ahead journal of job records with payloads kept as files.
+// Every record is appended and synced before the call returns, so the latest
+// state of each job survives a crash.
+type Store struct {
+	mu       sync.Mutex
+	dir      string
+	journal  *os.File
+	records  map[string]Record
+	keep     int
+	appended int
+}
+
+// Open opens (or creates) the store in dir and replays its journal.
+// keep is the number of finished jobs retained across compactions.
+func Open(dir string, keep int) (*Store, error) {
+	if err := os.MkdirAll(filepath.Join(dir, payloadDir), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create job store directory: %w", err)
+	}
+
+	s := &Store{
+		dir:     dir,
+		records: make(map[string]Record),
+		keep:    keep,
+	}
+
+	if err := s.replay(); err != nil {
+		return nil, err
+	}
+	if err := s.compactLocked(); err != nil {
+		return nil, err
+	}
+	s.reconcilePayloads()
+
+	return s, nil
+}
+
+// Close closes the journal file
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.journal == nil {
+		return nil
+	}
+	err := s.journal.Close()
+	s.journal = nil
+	return err
+}
+
+// Records returns the latest record of every known job, oldest first
+func (s *Store) Records() []Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := make([]Record, 0, len(s.records))
+	for _, r := range s.records {
+		records = append(records, r)
+	}
+	sortRecords(records)
+	return records
+}
+
+// Save appends a record to the journal
+func (s *Store) Save(r Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.appendLocked(r); err != nil {
+		return err
+	}
+	s.records[r.ID] = r
+
+	if r.Final {
+		s.removePayloadLocked(r.ID)
+	}
+
+	s.appended++
+	if s.appended >= compactAfter {
+		return s.compactLocked()
+	}
+	return nil
+}
+
+// SavePayload writes the job content to disk and syncs it
+func (s *Store) SavePayload(id string, content string) error {
+	path := s.payloadPath(id)
+	tmp := path + ".tmp"
+
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create payload file: %w", err)
+	}
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write payload file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync payload file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close payload file: %w", err)
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// LoadPayload reads the job content from disk
+func (s *Store) LoadPayload(id string) (string, error) {
+	data, err := os.ReadFile(s.payloadPath(id))
+	if err != nil {
+		return "", fmt.Errorf("failed to read payload: %w", err)
+	}
+	return string(data), nil
+}
+
+// replay loads the journal into memory. A torn last line left by a crash
+// is ignored.
+func (s *Store) replay() error {
+	f, err := os.Open(filepath.Join(s.dir, journalFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open job journal: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil || r.ID == "" {
+			continue
+		}
+		s.records[r.ID] = r
+	}
+	return scanner.Err()
+}
+
+// compactLocked rewrites the journal with only the latest record of each
+// pending job and the most recent finished jobs. The caller must hold s.mu.
+func (s *Store) compactLocked() error {
+	records := make([]Record, 0, len(s.records))
+	for _, r := range s.records {
+		records = append(records, r)
+	}
+	sortRecords(records)
+
+	// Drop the oldest finished jobs beyond the retention limit
+	finished := 0
+	for i := len(records) - 1; i >= 0; i-- {
+		if !records[i].Final {
+			continue
+		}
+		finished++
+		if finished > s.keep {
+			delete(s.records, records[i].ID)
+		}
+	}
+
+	path := filepath.Join(s.dir, journalFile)
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create job journal: %w", err)
+	}
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, r := range records {
+		if _, ok := s.records[r.ID]; !ok {
+			continue
+		}
+		if err := enc.Encode(r); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to write job journal: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write job journal: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync job journal: %w", err)
+	}
+	f.Close()
+
+	if s.journal != nil {
+		s.journal.Close()
+		s.journal = nil
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace job journal: %w", err)
+	}
+
+	journal, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open job journal: %w", err)
+	}
+	s.journal = journal
+	s.appended = 0
+	return nil
+}
+
+// appendLocked writes one record to the journal and syncs it.
+// The caller must hold s.mu.
+func (s *Store) appendLocked(r Record) error {
+	if s.journal == nil {
+		return fmt.Errorf("job store is closed")
+	}
+
+	line, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to encode job record: %w", err)
+	}
+	if _, err := s.journal.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to append job record: %w", err)
+	}
+	if err := s.journal.Sync(); err != nil {
+		return fmt.Errorf("failed to sync job journal: %w", err)
+	}
+	return nil
+}
+
+// reconcilePayloads deletes payload files that no pending job refers to
+func (s *Store) reconcilePayloads() {
+	entries, err := os.ReadDir(filepath.Join(s.dir, payloadDir))
+	if err != nil {
+		return
+	}
+
+	for _, e := range entries {
+		id := e.Name()
+		if r, ok := s.records[id]; ok && !r.Final {
+			continue
+		}
+		os.Remove(filepath.Join(s.dir, payloadDir, id))
+	}
+}
+
+// removePayloadLocked deletes the payload file of a job.
+// The caller must hold s.mu.
+func (s *Store) removePayloadLocked(id string) {
+	os.Remove(s.payloadPath(id))
+}
+
+// payloadPath returns the file used to store the content of a job
+func (s *Store) payloadPath(id string) string {
+	return filepath.Join(s.dir, payloadDir, filepath.Base(id))
+}
+
+// sortRecords orders records by creation time
+func sortRecords(records []Record) {
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].CreatedAt.Equal(records[j].CreatedAt) {
+			return records[i].ID < records[j].ID
+		}
+		return records[i].CreatedAt.Before(records[j].CreatedAt)
+	})
+}
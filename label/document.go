@@ -0,0 +1,205 @@
+package label
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"goprint-bridge/raster"
+)
+
+// Document is a structured label description that can be compiled to the
+// command languages of budget label printers (TSPL, EPL2)
+type Document struct {
+	Width     float64   `json:"width"`               // Label width in millimetres
+	Height    float64   `json:"height"`              // Label height in millimetres
+	Gap       *float64  `json:"gap,omitempty"`       // Gap between labels in millimetres, defaults to 2
+	DPI       int       `json:"dpi,omitempty"`       // Printer resolution, defaults to 203
+	Direction int       `json:"direction,omitempty"` // Print direction, 0 or 1
+	Elements  []Element `json:"elements"`
+}
+
+// Element is one object on a label. Which fields apply depends on Type:
+// text, barcode, qrcode or bitmap. Coordinates and sizes are in dots.
+type Element struct {
+	Type     string `json:"type"`
+	X        int    `json:"x"`
+	Y        int    `json:"y"`
+	Rotation int    `json:"rotation,omitempty"` // 0, 90, 180, 270
+
+	// text
+	Text   string `json:"text,omitempty"`
+	Font   string `json:"font,omitempty"`    // Printer font name, defaults to "3"
+	XScale int    `json:"x_scale,omitempty"` // Horizontal multiplier, defaults to 1
+	YScale int    `json:"y_scale,omitempty"` // Vertical multiplier, defaults to 1
+
+	// barcode, qrcode
+	Data      string `json:"data,omitempty"`
+	Symbology string `json:"symbology,omitempty"` // code128, ean13, ean8, upca, upce, code39, code93, itf, codabar
+	Height    int    `json:"height,omitempty"`    // Barcode height, bitmap height
+	Narrow    int    `json:"narrow,omitempty"`    // Narrow bar width, defaults to 2
+	Wide      int    `json:"wide,omitempty"`      // Wide bar width, defaults to 4
+	Readable  bool   `json:"readable,omitempty"`  // Print the human readable text
+	ECC       string `json:"ecc,omitempty"`       // QR error correction: L, M, Q, H
+	CellSize  int    `json:"cell_size,omitempty"` // QR module size, defaults to 6
+
+	// bitmap
+	Content string `json:"content,omitempty"` // Base64 PNG, JPEG, GIF or TIFF
+	Width   int    `json:"width,omitempty"`
+	Dither  string `json:"dither,omitempty"` // threshold, floyd-steinberg, atkinson
+}
+
+// ParseDocument decodes and checks a JSON label description
+func ParseDocument(content string) (*Document, error) {
+	var doc Document
+	if err := json.Unmarshal([]byte(content), &doc); err != nil {
+		return nil, fmt.Errorf("invalid label description: %w", err)
+	}
+
+	if doc.Width <= 0 || doc.Height <= 0 {
+		return nil, fmt.Errorf("label width and height are required")
+	}
+	if doc.Gap == nil {
+		gap := 2.0
+		doc.Gap = &gap
+	}
+	if *doc.Gap < 0 {
+		return nil, fmt.Errorf("invalid gap %v", *doc.Gap)
+	}
+	if doc.DPI == 0 {
+		doc.DPI = DefaultDPI
+	}
+	if doc.DPI < 0 {
+		return nil, fmt.Errorf("invalid dpi %d", doc.DPI)
+	}
+	if doc.Direction != 0 && doc.Direction != 1 {
+		return nil, fmt.Errorf("direction must be 0 or 1")
+	}
+	if len(doc.Elements) == 0 {
+		return nil, fmt.Errorf("label has no elements")
+	}
+
+	for i := range doc.Elements {
+		if err := doc.Elements[i].normalize(); err != nil {
+			return nil, fmt.Errorf("element %d (%s): %w", i+1, doc.Elements[i].Type, err)
+		}
+	}
+	return &doc, nil
+}
+
+// normalize checks an element and fills in defaults
+func (e *Element) normalize() error {
+	if e.X < 0 || e.Y < 0 {
+		return fmt.Errorf("x and y must not be negative")
+	}
+	if _, ok := rotations[e.Rotation]; !ok {
+		return fmt.Errorf("rotation must be 0, 90, 180 or 270")
+	}
+
+	switch e.Type {
+	case "text":
+		if e.Font == "" {
+			e.Font = "3"
+		}
+		if !fontPattern.MatchString(e.Font) {
+			return fmt.Errorf("invalid font %q", e.Font)
+		}
+		if e.XScale == 0 {
+			e.XScale = 1
+		}
+		if e.YScale == 0 {
+			e.YScale = 1
+		}
+		if e.XScale < 1 || e.XScale > 10 || e.YScale < 1 || e.YScale > 10 {
+			return fmt.Errorf("x_scale and y_scale must be between 1 and 10")
+		}
+		return checkPrintable(e.Text)
+	case "barcode":
+		if e.Data == "" {
+			return fmt.Errorf("missing data")
+		}
+		if e.Symbology == "" {
+			e.Symbology = "code128"
+		}
+		if _, ok := tsplBarcodes[e.Symbology]; !ok {
+			return fmt.Errorf("unsupported symbology %q", e.Symbology)
+		}
+		if e.Height == 0 {
+			e.Height = 80
+		}
+		if e.Narrow == 0 {
+			e.Narrow = 2
+		}
+		if e.Wide == 0 {
+			e.Wide = 2 * e.Narrow
+		}
+		if e.Height < 1 || e.Narrow < 1 || e.Wide < e.Narrow {
+			return fmt.Errorf("invalid barcode size")
+		}
+		return checkPrintable(e.Data)
+	case "qrcode":
+		if e.Data == "" {
+			return fmt.Errorf("missing data")
+		}
+		e.ECC = strings.ToUpper(e.ECC)
+		if e.ECC == "" {
+			e.ECC = "M"
+		}
+		if !strings.Contains("LMQH", e.ECC) || len(e.ECC) != 1 {
+			return fmt.Errorf("invalid ecc %q", e.ECC)
+		}
+		if e.CellSize == 0 {
+			e.CellSize = 6
+		}
+		if e.CellSize < 1 || e.CellSize > 10 {
+			return fmt.Errorf("cell_size must be between 1 and 10")
+		}
+		return checkPrintable(e.Data)
+	case "bitmap":
+		if e.Content == "" {
+			return fmt.Errorf("missing content")
+		}
+		if e.Width < 0 || e.Height < 0 {
+			return fmt.Errorf("invalid bitmap size")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown element type")
+	}
+}
+
+// bitmap decodes a bitmap element at the document resolution
+func (e Element) bitmap(dpi int) (*raster.Bitmap, error) {
+	return Graphic{
+		Content: e.Content,
+		Width:   e.Width,
+		Height:  e.Height,
+		DPI:     dpi,
+		Dither:  e.Dither,
+	}.Bitmap()
+}
+
+// fontPattern matches printer font names such as 3 or TSS24.BF2
+var fontPattern = regexp.MustCompile(`^[A-Za-z0-9._]{1,32}$`)
+
+// rotations maps degrees to the EPL2 rotation argument
+var rotations = map[int]int{0: 0, 90: 1, 180: 2, 270: 3}
+
+// checkPrintable rejects line breaks, which would end the command early
+func checkPrintable(s string) error {
+	if strings.ContainsAny(s, "\r\n") {
+		return fmt.Errorf("line breaks are not allowed")
+	}
+	return nil
+}
+
+// invert returns the bitmap data with black as 0, which is how TSPL and
+// EPL2 bitmaps mark printed dots
+func invert(pix []byte) []byte {
+	out := make([]byte, len(pix))
+	for i, b := range pix {
+		out[i] = ^b
+	}
+	return out
+}
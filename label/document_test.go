@@ -0,0 +1,123 @@
+package label
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// testDocument returns a label with one element of each type. The bitmap
+// is 16x1 dots with its left half black.
+func testDocument(t *testing.T) *Document {
+	doc, err := ParseDocument(fmt.Sprintf(`{
+		"width": 50,
+		"height": 30,
+		"elements": [
+			{"type": "text", "x": 10, "y": 20, "text": "Say \"hi\" \\o/"},
+			{"type": "barcode", "x": 10, "y": 60, "rotation": 90, "data": "A-1", "readable": true},
+			{"type": "qrcode", "x": 200, "y": 20, "data": "https://example.com", "ecc": "q"},
+			{"type": "bitmap", "x": 300, "y": 10, "content": %q}
+		]
+	}`, encodePNG(t, 16, 1)))
+	if err != nil {
+		t.Fatalf("ParseDocument failed: %v", err)
+	}
+	return doc
+}
+
+func TestCompileTSPL(t *testing.T) {
+	got, err := CompileTSPL(testDocument(t))
+	if err != nil {
+		t.Fatalf("CompileTSPL failed: %v", err)
+	}
+	want := "SIZE 50 mm,30 mm\r\n" +
+		"GAP 2 mm,0 mm\r\n" +
+		"DIRECTION 0\r\n" +
+		"CLS\r\n" +
+		`TEXT 10,20,"3",0,1,1,"Say \["]hi\["] \o/"` + "\r\n" +
+		`BARCODE 10,60,"128",80,1,90,2,4,"A-1"` + "\r\n" +
+		`QRCODE 200,20,Q,6,A,0,"https://example.com"` + "\r\n" +
+		"BITMAP 300,10,2,1,0,\x00\xFF\r\n" +
+		"PRINT 1\r\n"
+	if string(got) != want {
+		t.Errorf("CompileTSPL =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestCompileEPL(t *testing.T) {
+	got, err := CompileEPL(testDocument(t))
+	if err != nil {
+		t.Fatalf("CompileEPL failed: %v", err)
+	}
+	// 50x30 mm with a 2 mm gap at 203 dpi
+	want := "\n" +
+		"N\n" +
+		"q400\n" +
+		"Q240,16\n" +
+		"ZT\n" +
+		`A10,20,0,3,1,1,N,"Say \"hi\" \\o/"` + "\n" +
+		`B10,60,1,1,2,4,80,B,"A-1"` + "\n" +
+		`b200,20,Q,m2,s6,eQ,"https://example.com"` + "\n" +
+		"GW300,10,2,1,\x00\xFF\n" +
+		"P1\n"
+	if string(got) != want {
+		t.Errorf("CompileEPL =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestCompileOptions(t *testing.T) {
+	doc, err := ParseDocument(`{"width": 57.5, "height": 25.25, "gap": 0, "dpi": 300, "direction": 1,
+		"elements": [{"type": "text", "x": 1, "y": 2, "text": "x", "font": "TSS24.BF2", "x_scale": 2, "y_scale": 3}]}`)
+	if err != nil {
+		t.Fatalf("ParseDocument failed: %v", err)
+	}
+
+	tspl, err := CompileTSPL(doc)
+	if err != nil {
+		t.Fatalf("CompileTSPL failed: %v", err)
+	}
+	for _, want := range []string{"SIZE 57.5 mm,25.25 mm\r\n", "GAP 0 mm,0 mm\r\n", "DIRECTION 1\r\n", `TEXT 1,2,"TSS24.BF2",0,2,3,"x"`} {
+		if !strings.Contains(string(tspl), want) {
+			t.Errorf("CompileTSPL = %q, want it to contain %q", tspl, want)
+		}
+	}
+
+	epl, err := CompileEPL(doc)
+	if err != nil {
+		t.Fatalf("CompileEPL failed: %v", err)
+	}
+	for _, want := range []string{"q679\n", "Q298,0\n", "ZB\n"} {
+		if !strings.Contains(string(epl), want) {
+			t.Errorf("CompileEPL = %q, want it to contain %q", epl, want)
+		}
+	}
+}
+
+func TestParseDocumentErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want string
+	}{
+		{name: "not JSON", json: `[`, want: "invalid label description"},
+		{name: "no size", json: `{"elements": [{"type": "text"}]}`, want: "width and height are required"},
+		{name: "negative gap", json: `{"width": 1, "height": 1, "gap": -1, "elements": [{"type": "text"}]}`, want: "invalid gap"},
+		{name: "direction", json: `{"width": 1, "height": 1, "direction": 2, "elements": [{"type": "text"}]}`, want: "direction must be 0 or 1"},
+		{name: "no elements", json: `{"width": 1, "height": 1}`, want: "no elements"},
+		{name: "unknown type", json: `{"width": 1, "height": 1, "elements": [{"type": "circle"}]}`, want: "element 1 (circle): unknown element type"},
+		{name: "rotation", json: `{"width": 1, "height": 1, "elements": [{"type": "text", "rotation": 45}]}`, want: "rotation must be"},
+		{name: "font", json: `{"width": 1, "height": 1, "elements": [{"type": "text", "font": "3\",0,0,0,\"x"}]}`, want: "invalid font"},
+		{name: "line break", json: `{"width": 1, "height": 1, "elements": [{"type": "text", "text": "a\r\nPRINT 99"}]}`, want: "line breaks"},
+		{name: "symbology", json: `{"width": 1, "height": 1, "elements": [{"type": "barcode", "data": "1", "symbology": "pdf417"}]}`, want: "unsupported symbology"},
+		{name: "ecc", json: `{"width": 1, "height": 1, "elements": [{"type": "qrcode", "data": "1", "ecc": "LM"}]}`, want: "invalid ecc"},
+		{name: "bitmap content", json: `{"width": 1, "height": 1, "elements": [{"type": "bitmap"}]}`, want: "missing content"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseDocument(tt.json)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseDocument error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
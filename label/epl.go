@@ -0,0 +1,73 @@
+package label
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// eplBarcodes maps symbologies to EPL2 B command types
+var eplBarcodes = map[string]string{
+	"code128": "1",
+	"ean13":   "E30",
+	"ean8":    "E80",
+	"upca":    "UA0",
+	"upce":    "UE0",
+	"code39":  "3",
+	"code93":  "9",
+	"itf":     "2",
+	"codabar": "K",
+}
+
+// CompileEPL converts a label description to EPL2 commands
+func CompileEPL(doc *Document) ([]byte, error) {
+	var buf bytes.Buffer
+	line := func(format string, args ...interface{}) {
+		fmt.Fprintf(&buf, format, args...)
+		buf.WriteString("\n")
+	}
+
+	// A leading blank line ends any command left over from a previous job
+	buf.WriteString("\n")
+	line("N")
+	line("q%d", mmToDots(doc.Width, doc.DPI))
+	line("Q%d,%d", mmToDots(doc.Height, doc.DPI), mmToDots(*doc.Gap, doc.DPI))
+	if doc.Direction == 1 {
+		line("ZB")
+	} else {
+		line("ZT")
+	}
+
+	for i, e := range doc.Elements {
+		rotation := rotations[e.Rotation]
+		switch e.Type {
+		case "text":
+			line("A%d,%d,%d,%s,%d,%d,N,%s", e.X, e.Y, rotation, e.Font, e.XScale, e.YScale, eplQuote(e.Text))
+		case "barcode":
+			readable := "N"
+			if e.Readable {
+				readable = "B"
+			}
+			line("B%d,%d,%d,%s,%d,%d,%d,%s,%s", e.X, e.Y, rotation, eplBarcodes[e.Symbology], e.Narrow, e.Wide, e.Height, readable, eplQuote(e.Data))
+		case "qrcode":
+			line("b%d,%d,Q,m2,s%d,e%s,%s", e.X, e.Y, e.CellSize, e.ECC, eplQuote(e.Data))
+		case "bitmap":
+			bmp, err := e.bitmap(doc.DPI)
+			if err != nil {
+				return nil, fmt.Errorf("element %d (bitmap): %w", i+1, err)
+			}
+			fmt.Fprintf(&buf, "GW%d,%d,%d,%d,", e.X, e.Y, bmp.Stride, bmp.Height)
+			buf.Write(invert(bmp.Pix))
+			buf.WriteString("\n")
+		}
+	}
+
+	line("P1")
+	return buf.Bytes(), nil
+}
+
+// eplQuote quotes a string argument, escaping backslashes and quotes
+func eplQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
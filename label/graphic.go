@@ -0,0 +1,197 @@
+package label
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"goprint-bridge/raster"
+)
+
+const (
+	// DefaultDPI is the resolution of most desktop label printers (8 dots/mm)
+	DefaultDPI = 203
+
+	// maxGraphicDots bounds either side of a graphic, 16 inches at 600 dpi
+	maxGraphicDots = 9600
+)
+
+// Graphic is an image placed on a label as a ^GF graphic field
+type Graphic struct {
+	Content  string  `json:"content"`             // Base64 PNG, JPEG, GIF or TIFF
+	Width    int     `json:"width,omitempty"`     // Target width in dots
+	Height   int     `json:"height,omitempty"`    // Target height in dots
+	WidthMM  float64 `json:"width_mm,omitempty"`  // Target width in millimetres, converted with DPI
+	HeightMM float64 `json:"height_mm,omitempty"` // Target height in millimetres, converted with DPI
+	DPI      int     `json:"dpi,omitempty"`       // Printer resolution, defaults to 203
+	X        int     `json:"x,omitempty"`         // Field origin in dots
+	Y        int     `json:"y,omitempty"`
+	Dither   string  `json:"dither,omitempty"` // threshold, floyd-steinberg, atkinson
+}
+
+// Bitmap decodes the image and scales it to the target size. With only
+// one dimension set the aspect ratio is kept; with none the image is used
+// at one dot per pixel.
+func (g Graphic) Bitmap() (*raster.Bitmap, error) {
+	img, err := raster.DecodeBase64(g.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	dpi := g.DPI
+	if dpi == 0 {
+		dpi = DefaultDPI
+	}
+	if dpi < 0 {
+		return nil, fmt.Errorf("invalid dpi %d", g.DPI)
+	}
+
+	width, height := g.Width, g.Height
+	if width == 0 && g.WidthMM > 0 {
+		width = mmToDots(g.WidthMM, dpi)
+	}
+	if height == 0 && g.HeightMM > 0 {
+		height = mmToDots(g.HeightMM, dpi)
+	}
+	if width < 0 || height < 0 {
+		return nil, fmt.Errorf("invalid graphic size %dx%d", width, height)
+	}
+
+	b := img.Bounds()
+	switch {
+	case width == 0 && height == 0:
+		width, height = b.Dx(), b.Dy()
+	case height == 0:
+		height = max(1, b.Dy()*width/b.Dx())
+	case width == 0:
+		width = max(1, b.Dx()*height/b.Dy())
+	}
+	if width > maxGraphicDots || height > maxGraphicDots {
+		return nil, fmt.Errorf("graphic is larger than %d dots", maxGraphicDots)
+	}
+
+	return raster.Monochrome(raster.Resize(img, width, height), g.Dither)
+}
+
+// Field renders the graphic as a field placed at its origin
+func (g Graphic) Field() (string, error) {
+	bmp, err := g.Bitmap()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("^FO%d,%d%s^FS", g.X, g.Y, GraphicField(bmp)), nil
+}
+
+// ImageLabel renders a base64 image as a complete label of its own,
+// scaled down to maxWidth dots when it's wider. Without a width the image
+// may be up to the largest graphic wide.
+func ImageLabel(content string, maxWidth int, dither string) (string, error) {
+	img, err := raster.DecodeBase64(content)
+	if err != nil {
+		return "", err
+	}
+	if maxWidth <= 0 || maxWidth > maxGraphicDots {
+		maxWidth = maxGraphicDots
+	}
+	fitted := raster.FitWidth(img, maxWidth)
+	if fitted.Bounds().Dy() > maxGraphicDots {
+		return "", fmt.Errorf("graphic is larger than %d dots", maxGraphicDots)
+	}
+	bmp, err := raster.Monochrome(fitted, dither)
+	if err != nil {
+		return "", err
+	}
+	return "^XA^FO0,0" + GraphicField(bmp) + "^FS^XZ\n", nil
+}
+
+// EmbedGraphics replaces {{name}} placeholders with graphic fields. It
+// runs before Render, which would reject the ^ in the generated fields.
+func EmbedGraphics(template string, graphics map[string]Graphic) (string, error) {
+	var err error
+	out := variablePattern.ReplaceAllStringFunc(template, func(match string) string {
+		name := variablePattern.FindStringSubmatch(match)[1]
+		g, ok := graphics[name]
+		if !ok || err != nil {
+			return match
+		}
+		field, ferr := g.Field()
+		if ferr != nil {
+			err = fmt.Errorf("graphic %s: %w", name, ferr)
+			return match
+		}
+		return field
+	})
+	if err != nil {
+		return "", err
+	}
+	return out, nil
+}
+
+// GraphicField encodes a bitmap as ^GFA using ZPL's ASCII compression
+func GraphicField(bmp *raster.Bitmap) string {
+	total := bmp.Stride * bmp.Height
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "^GFA,%d,%d,%d,", total, total, bmp.Stride)
+
+	var previous string
+	for y := 0; y < bmp.Height; y++ {
+		row := fmt.Sprintf("%X", bmp.Row(y))
+		if y > 0 && row == previous {
+			// Same as the row above
+			b.WriteByte(':')
+			continue
+		}
+		previous = row
+		b.WriteString(compressRow(row))
+	}
+	return b.String()
+}
+
+// compressRow applies ZPL run-length compression to one row of hex digits
+func compressRow(row string) string {
+	// Trailing zeros and ones are filled by ',' and '!'
+	fill := ""
+	if trimmed := strings.TrimRight(row, "0"); len(trimmed) < len(row) {
+		row, fill = trimmed, ","
+	} else if trimmed := strings.TrimRight(row, "F"); len(trimmed) < len(row)-1 {
+		row, fill = trimmed, "!"
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(row); {
+		j := i
+		for j < len(row) && row[j] == row[i] {
+			j++
+		}
+		writeRepeat(&b, j-i)
+		b.WriteByte(row[i])
+		i = j
+	}
+	b.WriteString(fill)
+	return b.String()
+}
+
+// writeRepeat writes the repeat count that prefixes a hex digit.
+// g-z count 20 to 400 in steps of 20, G-Y count 1 to 19.
+func writeRepeat(b *strings.Builder, n int) {
+	if n == 1 {
+		return
+	}
+	for n >= 400 {
+		b.WriteByte('z')
+		n -= 400
+	}
+	if n >= 20 {
+		b.WriteByte(byte('g' + n/20 - 1))
+		n %= 20
+	}
+	if n > 0 {
+		b.WriteByte(byte('G' + n - 1))
+	}
+}
+
+// mmToDots converts millimetres to dots at the given resolution
+func mmToDots(mm float64, dpi int) int {
+	return int(math.Round(mm * float64(dpi) / 25.4))
+}
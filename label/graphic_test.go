@@ -0,0 +1,159 @@
+package label
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"goprint-bridge/raster"
+)
+
+// encodePNG returns a base64 PNG of an image whose left half is black
+func encodePNG(t *testing.T, width, height int) string {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if x >= width/2 {
+				img.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+// bitmap builds a bitmap from packed rows
+func bitmap(width int, rows ...[]byte) *raster.Bitmap {
+	bmp := raster.NewBitmap(width, len(rows))
+	for y, row := range rows {
+		copy(bmp.Row(y), row)
+	}
+	return bmp
+}
+
+func TestGraphicField(t *testing.T) {
+	tests := []struct {
+		name string
+		bmp  *raster.Bitmap
+		want string
+	}{
+		{
+			name: "zero fill, repeated row and one fill",
+			bmp:  bitmap(16, []byte{0xFF, 0x00}, []byte{0xFF, 0x00}, []byte{0x0F, 0xFF}),
+			want: "^GFA,6,6,2,HF,:0!",
+		},
+		{
+			name: "blank rows",
+			bmp:  bitmap(8, []byte{0x00}, []byte{0x00}),
+			want: "^GFA,2,2,1,,:",
+		},
+		{
+			name: "single trailing F is kept",
+			bmp:  bitmap(8, []byte{0xAF}),
+			want: "^GFA,1,1,1,AF",
+		},
+		{
+			name: "runs of 20 and more",
+			bmp:  bitmap(480, bytes.Repeat([]byte{0xAA}, 60)),
+			want: "^GFA,60,60,60,lA",
+		},
+		{
+			name: "runs past 400",
+			bmp:  bitmap(1616, append(bytes.Repeat([]byte{0xAA}, 201), 0x5A)),
+			want: "^GFA,202,202,202,zHA5A",
+		},
+		{
+			name: "run of 21",
+			bmp:  bitmap(88, append(bytes.Repeat([]byte{0x33}, 10), 0x31)),
+			want: "^GFA,11,11,11,gG31",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GraphicField(tt.bmp); got != tt.want {
+				t.Errorf("GraphicField = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGraphicFieldOfImage(t *testing.T) {
+	g := Graphic{Content: encodePNG(t, 32, 4), Width: 16, X: 10, Y: 20}
+	field, err := g.Field()
+	if err != nil {
+		t.Fatalf("Field failed: %v", err)
+	}
+	// Scaled to 16x2 dots, the left half black
+	if want := "^FO10,20^GFA,4,4,2,HF,:^FS"; field != want {
+		t.Errorf("Field = %q, want %q", field, want)
+	}
+}
+
+func TestGraphicSize(t *testing.T) {
+	content := encodePNG(t, 100, 50)
+	tests := []struct {
+		name          string
+		g             Graphic
+		width, height int
+	}{
+		{name: "image size", g: Graphic{}, width: 100, height: 50},
+		{name: "width keeps the aspect ratio", g: Graphic{Width: 40}, width: 40, height: 20},
+		{name: "height keeps the aspect ratio", g: Graphic{Height: 10}, width: 20, height: 10},
+		{name: "millimetres at 203 dpi", g: Graphic{WidthMM: 25.4}, width: 203, height: 101},
+		{name: "millimetres at 300 dpi", g: Graphic{WidthMM: 10, HeightMM: 5, DPI: 300}, width: 118, height: 59},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.g.Content = content
+			bmp, err := tt.g.Bitmap()
+			if err != nil {
+				t.Fatalf("Bitmap failed: %v", err)
+			}
+			if bmp.Width != tt.width || bmp.Height != tt.height {
+				t.Errorf("size = %dx%d, want %dx%d", bmp.Width, bmp.Height, tt.width, tt.height)
+			}
+		})
+	}
+
+	if _, err := (Graphic{Content: content, Width: maxGraphicDots + 1}).Bitmap(); err == nil {
+		t.Error("Bitmap accepted a graphic larger than the limit")
+	}
+}
+
+func TestEmbedGraphics(t *testing.T) {
+	graphics := map[string]Graphic{"logo": {Content: encodePNG(t, 16, 1)}}
+
+	got, err := EmbedGraphics("^XA{{logo}}^FD{{name}}^FS^XZ", graphics)
+	if err != nil {
+		t.Fatalf("EmbedGraphics failed: %v", err)
+	}
+	// Other placeholders are left for Render
+	if want := "^XA^FO0,0^GFA,2,2,2,HF,^FS^FD{{name}}^FS^XZ"; got != want {
+		t.Errorf("EmbedGraphics = %q, want %q", got, want)
+	}
+
+	graphics["logo"] = Graphic{Content: "not base64!"}
+	if _, err := EmbedGraphics("^XA{{logo}}^XZ", graphics); err == nil || !strings.Contains(err.Error(), "graphic logo") {
+		t.Errorf("EmbedGraphics error = %v, want it to name the graphic", err)
+	}
+}
+
+func TestImageLabel(t *testing.T) {
+	got, err := ImageLabel(encodePNG(t, 32, 2), 16, "")
+	if err != nil {
+		t.Fatalf("ImageLabel failed: %v", err)
+	}
+	if want := "^XA^FO0,0^GFA,2,2,2,HF,^FS^XZ\n"; got != want {
+		t.Errorf("ImageLabel = %q, want %q", got, want)
+	}
+	if err := ValidateZPL(got); err != nil {
+		t.Errorf("ImageLabel output is not valid ZPL: %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package label
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// tsplBarcodes maps symbologies to TSPL BARCODE code types. Every
+// supported symbology has a TSPL and an EPL2 code.
+var tsplBarcodes = map[string]string{
+	"code128": "128",
+	"ean13":   "EAN13",
+	"ean8":    "EAN8",
+	"upca":    "UPCA",
+	"upce":    "UPCE",
+	"code39":  "39",
+	"code93":  "93",
+	"itf":     "25",
+	"codabar": "CODA",
+}
+
+// CompileTSPL converts a label description to TSPL commands
+func CompileTSPL(doc *Document) ([]byte, error) {
+	var buf bytes.Buffer
+	line := func(format string, args ...interface{}) {
+		fmt.Fprintf(&buf, format, args...)
+		buf.WriteString("\r\n")
+	}
+
+	line("SIZE %s mm,%s mm", mm(doc.Width), mm(doc.Height))
+	line("GAP %s mm,0 mm", mm(*doc.Gap))
+	line("DIRECTION %d", doc.Direction)
+	line("CLS")
+
+	for i, e := range doc.Elements {
+		switch e.Type {
+		case "text":
+			line(`TEXT %d,%d,%s,%d,%d,%d,%s`, e.X, e.Y, tsplQuote(e.Font), e.Rotation, e.XScale, e.YScale, tsplQuote(e.Text))
+		case "barcode":
+			readable := 0
+			if e.Readable {
+				readable = 1
+			}
+			line(`BARCODE %d,%d,"%s",%d,%d,%d,%d,%d,%s`, e.X, e.Y, tsplBarcodes[e.Symbology], e.Height, readable, e.Rotation, e.Narrow, e.Wide, tsplQuote(e.Data))
+		case "qrcode":
+			line(`QRCODE %d,%d,%s,%d,A,%d,%s`, e.X, e.Y, e.ECC, e.CellSize, e.Rotation, tsplQuote(e.Data))
+		case "bitmap":
+			bmp, err := e.bitmap(doc.DPI)
+			if err != nil {
+				return nil, fmt.Errorf("element %d (bitmap): %w", i+1, err)
+			}
+			// Mode 0 overwrites what's underneath
+			fmt.Fprintf(&buf, "BITMAP %d,%d,%d,%d,0,", e.X, e.Y, bmp.Stride, bmp.Height)
+			buf.Write(invert(bmp.Pix))
+			buf.WriteString("\r\n")
+		}
+	}
+
+	line("PRINT 1")
+	return buf.Bytes(), nil
+}
+
+// tsplQuote quotes a string argument. TSPL escapes a double quote as \["].
+func tsplQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `\["]`) + `"`
+}
+
+// mm formats a millimetre value without trailing zeros
+func mm(v float64) string {
+	return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.2f", v), "0"), ".")
+}
@@ -0,0 +1,199 @@
+package label
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MaxLabels bounds how many labels one request can expand to
+const MaxLabels = 1000
+
+// variablePattern matches {{name}} placeholders
+var variablePattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_.-]*)\s*\}\}`)
+
+// Serial describes a variable that is incremented on every label
+type Serial struct {
+	Variable string `json:"variable"`        // Placeholder name, e.g. "serial"
+	Start    string `json:"start"`           // First value, its trailing number is incremented, e.g. "A0001"
+	Step     int    `json:"step,omitempty"`  // Increment, defaults to 1
+	Count    int    `json:"count,omitempty"` // Labels to print, up to MaxLabels; the job's copies when unset
+}
+
+// ValidateZPL checks that every label opens with ^XA and closes with ^XZ
+func ValidateZPL(zpl string) error {
+	labels := 0
+	open := false
+
+	for i := 0; i+2 < len(zpl); i++ {
+		if zpl[i] != '^' {
+			continue
+		}
+		switch strings.ToUpper(zpl[i+1 : i+3]) {
+		case "XA":
+			if open {
+				return fmt.Errorf("^XA at offset %d inside an open label", i)
+			}
+			open = true
+		case "XZ":
+			if !open {
+				return fmt.Errorf("^XZ at offset %d without ^XA", i)
+			}
+			open = false
+			labels++
+		}
+	}
+
+	if open {
+		return fmt.Errorf("label is missing ^XZ")
+	}
+	if labels == 0 {
+		return fmt.Errorf("no ^XA...^XZ label found")
+	}
+	return nil
+}
+
+// Render replaces {{name}} placeholders with values from data. Unknown
+// placeholders are an error rather than printing blank fields.
+func Render(template string, data map[string]interface{}) (string, error) {
+	var missing []string
+	var invalid []string
+
+	out := variablePattern.ReplaceAllStringFunc(template, func(match string) string {
+		name := variablePattern.FindStringSubmatch(match)[1]
+		value, ok := data[name]
+		if !ok || value == nil {
+			missing = append(missing, name)
+			return match
+		}
+		s := formatValue(value)
+		// Values are field data, they must not start new commands
+		if strings.ContainsAny(s, "^~") {
+			invalid = append(invalid, name)
+			return match
+		}
+		return s
+	})
+
+	if len(missing) > 0 {
+		return "", fmt.Errorf("missing data for %s", strings.Join(missing, ", "))
+	}
+	if len(invalid) > 0 {
+		return "", fmt.Errorf("data for %s contains ^ or ~", strings.Join(invalid, ", "))
+	}
+	return out, nil
+}
+
+// formatValue formats a data value. JSON numbers decode as floats, which
+// are written out in full rather than as 1.23456789e+08.
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// RenderZPL renders and validates count labels. With a serial, each label
+// gets the next serial number; without one the same label is repeated.
+func RenderZPL(template string, data map[string]interface{}, serial *Serial, count int) (string, error) {
+	if count < 1 || count > MaxLabels {
+		return "", fmt.Errorf("label count must be between 1 and %d", MaxLabels)
+	}
+	// Values can't contain ^, so checking the template covers every label
+	if err := ValidateZPL(template); err != nil {
+		return "", err
+	}
+
+	values := make(map[string]interface{}, len(data)+1)
+	for k, v := range data {
+		values[k] = v
+	}
+
+	var next func() (string, error)
+	if serial != nil {
+		if serial.Variable == "" {
+			return "", fmt.Errorf("serial variable is required")
+		}
+		next = serial.sequence()
+	}
+
+	var b strings.Builder
+	for i := 0; i < count; i++ {
+		if next != nil {
+			value, err := next()
+			if err != nil {
+				return "", err
+			}
+			values[serial.Variable] = value
+		}
+
+		zpl, err := Render(template, values)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(zpl)
+	}
+	return b.String(), nil
+}
+
+// sequence returns a generator for the serial numbers, starting with Start
+func (s Serial) sequence() func() (string, error) {
+	step := s.Step
+	if step == 0 {
+		step = 1
+	}
+	current := s.Start
+	first := true
+
+	return func() (string, error) {
+		if first {
+			first = false
+			if _, _, err := splitSerial(current); err != nil {
+				return "", err
+			}
+			return current, nil
+		}
+		next, err := incrementSerial(current, step)
+		if err != nil {
+			return "", err
+		}
+		current = next
+		return current, nil
+	}
+}
+
+// splitSerial splits a serial into its prefix and trailing digits
+func splitSerial(serial string) (string, string, error) {
+	i := len(serial)
+	for i > 0 && serial[i-1] >= '0' && serial[i-1] <= '9' {
+		i--
+	}
+	if i == len(serial) {
+		return "", "", fmt.Errorf("serial %q does not end with a number", serial)
+	}
+	return serial[:i], serial[i:], nil
+}
+
+// incrementSerial adds step to the trailing number, keeping its width so
+// "A0009" becomes "A0010"
+func incrementSerial(serial string, step int) (string, error) {
+	prefix, digits, err := splitSerial(serial)
+	if err != nil {
+		return "", err
+	}
+	n, err := strconv.ParseUint(digits, 10, 63)
+	if err != nil {
+		return "", fmt.Errorf("serial %q is too large", serial)
+	}
+
+	value := int64(n) + int64(step)
+	if value < 0 {
+		return "", fmt.Errorf("serial %q would become negative", serial)
+	}
+	return fmt.Sprintf("%s%0*d", prefix, len(digits), value), nil
+}
@@ -0,0 +1,136 @@
+package label
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestValidateZPL(t *testing.T) {
+	tests := []struct {
+		zpl  string
+		want string // Error substring, empty when valid
+	}{
+		{zpl: "^XA^FO50,50^FDok^FS^XZ"},
+		{zpl: "^xa^FDlower case^FS^xz^XA^XZ"},
+		{zpl: "^FDno label^FS", want: "no ^XA...^XZ label"},
+		{zpl: "^XA^FDopen^FS", want: "missing ^XZ"},
+		{zpl: "^XA^XA^XZ", want: "inside an open label"},
+		{zpl: "^XZ", want: "without ^XA"},
+	}
+	for _, tt := range tests {
+		err := ValidateZPL(tt.zpl)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("ValidateZPL(%q) failed: %v", tt.zpl, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("ValidateZPL(%q) error = %v, want %q", tt.zpl, err, tt.want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	// Data decoded from a request, where every number is a float64
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(`{
+		"sku": 123456789,
+		"price": 19.99,
+		"weight": 0.000025,
+		"name": "Tea",
+		"stock": null
+	}`), &data); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		template string
+		want     string
+		err      string
+	}{
+		{template: "^FD{{sku}}^FS", want: "^FD123456789^FS"},
+		{template: "^FD{{ price }}^FS", want: "^FD19.99^FS"},
+		{template: "^FD{{weight}}^FS", want: "^FD0.000025^FS"},
+		{template: "^FD{{name}} {{name}}^FS", want: "^FDTea Tea^FS"},
+		{template: "^FD{{missing}}^FS", err: "missing data for missing"},
+		{template: "^FD{{stock}}^FS", err: "missing data for stock"},
+	}
+	for _, tt := range tests {
+		got, err := Render(tt.template, data)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Render(%q) error = %v, want %q", tt.template, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Render(%q) failed: %v", tt.template, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Render(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestRenderRejectsCommands(t *testing.T) {
+	for _, value := range []string{"^XZ^XA^FDinjected", "~JR"} {
+		_, err := Render("^XA^FD{{name}}^FS^XZ", map[string]interface{}{"name": value})
+		if err == nil || !strings.Contains(err.Error(), "contains ^ or ~") {
+			t.Errorf("Render with %q error = %v, want it rejected", value, err)
+		}
+	}
+}
+
+func TestRenderZPLSerial(t *testing.T) {
+	tests := []struct {
+		name   string
+		serial Serial
+		count  int
+		want   []string
+	}{
+		{name: "keeps the width", serial: Serial{Variable: "sn", Start: "A0008"}, count: 3, want: []string{"A0008", "A0009", "A0010"}},
+		{name: "grows past the width", serial: Serial{Variable: "sn", Start: "98", Step: 1}, count: 3, want: []string{"98", "99", "100"}},
+		{name: "step", serial: Serial{Variable: "sn", Start: "B-10", Step: 5}, count: 2, want: []string{"B-10", "B-15"}},
+		{name: "counts down", serial: Serial{Variable: "sn", Start: "X3", Step: -1}, count: 3, want: []string{"X3", "X2", "X1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RenderZPL("^XA^FD{{sn}}^FS^XZ", nil, &tt.serial, tt.count)
+			if err != nil {
+				t.Fatalf("RenderZPL failed: %v", err)
+			}
+			var want strings.Builder
+			for _, sn := range tt.want {
+				want.WriteString("^XA^FD" + sn + "^FS^XZ")
+			}
+			if got != want.String() {
+				t.Errorf("RenderZPL = %q, want %q", got, want.String())
+			}
+		})
+	}
+}
+
+func TestRenderZPLErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		zpl    string
+		serial *Serial
+		count  int
+		want   string
+	}{
+		{name: "no labels", zpl: "^XA^XZ", count: 0, want: "between 1 and"},
+		{name: "too many labels", zpl: "^XA^XZ", count: MaxLabels + 1, want: "between 1 and"},
+		{name: "invalid ZPL", zpl: "^XA", count: 1, want: "missing ^XZ"},
+		{name: "no serial variable", zpl: "^XA^XZ", serial: &Serial{Start: "1"}, count: 1, want: "serial variable is required"},
+		{name: "serial without a number", zpl: "^XA^FD{{sn}}^XZ", serial: &Serial{Variable: "sn", Start: "A"}, count: 1, want: "does not end with a number"},
+		{name: "negative serial", zpl: "^XA^FD{{sn}}^XZ", serial: &Serial{Variable: "sn", Start: "1", Step: -2}, count: 2, want: "negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := RenderZPL(tt.zpl, nil, tt.serial, tt.count)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("RenderZPL error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package layout
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// Column describes one column of a table
+type Column struct {
+	Width int    `json:"width,omitempty"` // Characters, 0 shares the remaining width
+	Align string `json:"align,omitempty"` // left, center, right
+	Wrap  string `json:"wrap,omitempty"`  // wrap (default) or truncate
+}
+
+// Width returns the number of monospaced cells s occupies. East Asian wide
+// characters take two.
+func Width(s string) int {
+	return runewidth.StringWidth(s)
+}
+
+// Pad aligns s within width cells
+func Pad(s string, width int, align string) string {
+	space := width - Width(s)
+	if space <= 0 {
+		return s
+	}
+	switch align {
+	case "right":
+		return strings.Repeat(" ", space) + s
+	case "center":
+		left := space / 2
+		return strings.Repeat(" ", left) + s + strings.Repeat(" ", space-left)
+	default:
+		return s + strings.Repeat(" ", space)
+	}
+}
+
+// Wrap breaks s into lines of at most width cells, at spaces where
+// possible. Words longer than a line are split.
+func Wrap(s string, width int) []string {
+	if width <= 0 {
+		return nil
+	}
+
+	var lines []string
+	line, lineWidth := "", 0
+	for _, word := range strings.Fields(s) {
+		wordWidth := Width(word)
+
+		if lineWidth > 0 && lineWidth+1+wordWidth <= width {
+			line += " " + word
+			lineWidth += 1 + wordWidth
+			continue
+		}
+		if lineWidth > 0 {
+			lines = append(lines, line)
+			line, lineWidth = "", 0
+		}
+
+		// Split words that don't fit on a line of their own
+		for wordWidth > width {
+			head := runewidth.Truncate(word, width, "")
+			if head == "" {
+				// A wide character in a one cell column
+				head = string([]rune(word)[:1])
+			}
+			lines = append(lines, head)
+			word = word[len(head):]
+			wordWidth = Width(word)
+		}
+		line, lineWidth = word, wordWidth
+	}
+	if lineWidth > 0 || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Truncate cuts s to at most width cells
+func Truncate(s string, width int) string {
+	return runewidth.Truncate(s, width, "")
+}
+
+// Table lays out rows in fixed columns separated by gap spaces
+type Table struct {
+	Columns []Column
+	Gap     int
+}
+
+// widths resolves the column widths for a line of total cells
+func (t Table) widths(total int) ([]int, error) {
+	if len(t.Columns) == 0 {
+		return nil, fmt.Errorf("table has no columns")
+	}
+
+	widths := make([]int, len(t.Columns))
+	remaining := total - t.Gap*(len(t.Columns)-1)
+	flexible := 0
+	for i, c := range t.Columns {
+		if c.Width < 0 {
+			return nil, fmt.Errorf("column %d has a negative width", i+1)
+		}
+		if c.Width == 0 {
+			flexible++
+			continue
+		}
+		widths[i] = c.Width
+		remaining -= c.Width
+	}
+	if remaining < flexible {
+		return nil, fmt.Errorf("columns don't fit in %d characters", total)
+	}
+
+	// Flexible columns share what's left, the first ones get the remainder
+	n := 0
+	for i, c := range t.Columns {
+		if c.Width == 0 {
+			widths[i] = remaining / flexible
+			if n < remaining%flexible {
+				widths[i]++
+			}
+			n++
+		}
+	}
+	return widths, nil
+}
+
+// Render lays out the rows in total cells. Each row takes as many lines
+// as its longest wrapped cell; missing cells are left blank.
+func (t Table) Render(rows [][]string, total int) ([]string, error) {
+	widths, err := t.widths(total)
+	if err != nil {
+		return nil, err
+	}
+	gap := strings.Repeat(" ", t.Gap)
+
+	var lines []string
+	for r, row := range rows {
+		if len(row) > len(t.Columns) {
+			return nil, fmt.Errorf("row %d has %d cells for %d columns", r+1, len(row), len(t.Columns))
+		}
+
+		cells := make([][]string, len(t.Columns))
+		height := 1
+		for i, c := range t.Columns {
+			text := ""
+			if i < len(row) {
+				text = row[i]
+			}
+			if c.Wrap == "truncate" {
+				cells[i] = []string{Truncate(strings.Join(strings.Fields(text), " "), widths[i])}
+			} else {
+				cells[i] = Wrap(text, widths[i])
+			}
+			height = max(height, len(cells[i]))
+		}
+
+		for l := 0; l < height; l++ {
+			parts := make([]string, len(t.Columns))
+			for i, c := range t.Columns {
+				text := ""
+				if l < len(cells[i]) {
+					text = cells[i][l]
+				}
+				parts[i] = Pad(text, widths[i], c.Align)
+			}
+			lines = append(lines, strings.TrimRight(strings.Join(parts, gap), " "))
+		}
+	}
+	return lines, nil
+}
@@ -0,0 +1,168 @@
+package layout
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		text  string
+		width int
+		want  []string
+	}{
+		{text: "one two three", width: 7, want: []string{"one two", "three"}},
+		{text: "  spaced   out  ", width: 20, want: []string{"spaced out"}},
+		{text: "abcdefghij", width: 4, want: []string{"abcd", "efgh", "ij"}},
+		{text: "", width: 4, want: []string{""}},
+		// Wide characters take two cells
+		{text: "日本語テキスト", width: 6, want: []string{"日本語", "テキス", "ト"}},
+		{text: "日本", width: 1, want: []string{"日", "本"}},
+	}
+	for _, tt := range tests {
+		got := Wrap(tt.text, tt.width)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("Wrap(%q, %d) = %q, want %q", tt.text, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestPad(t *testing.T) {
+	tests := []struct {
+		text, align string
+		want        string
+	}{
+		{text: "ab", align: "", want: "ab   "},
+		{text: "ab", align: "right", want: "   ab"},
+		{text: "ab", align: "center", want: " ab  "},
+		{text: "日本", align: "right", want: " 日本"},
+		{text: "toolong", align: "right", want: "toolong"},
+	}
+	for _, tt := range tests {
+		if got := Pad(tt.text, 5, tt.align); got != tt.want {
+			t.Errorf("Pad(%q, 5, %q) = %q, want %q", tt.text, tt.align, got, tt.want)
+		}
+	}
+}
+
+func TestTable(t *testing.T) {
+	table := Table{
+		Columns: []Column{{}, {Width: 3, Align: "right"}, {Width: 7, Align: "right", Wrap: "truncate"}},
+		Gap:     1,
+	}
+	got, err := table.Render([][]string{
+		{"Espresso", "2", "5.00"},
+		{"Very long product name", "1", "1234567.89"},
+		{"Tip"},
+	}, 24)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	want := []string{
+		"Espresso       2    5.00",
+		"Very long      1 1234567",
+		"product name",
+		"Tip",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Render =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestTableWidths(t *testing.T) {
+	tests := []struct {
+		columns []Column
+		total   int
+		want    []int
+	}{
+		{columns: []Column{{}, {}}, total: 11, want: []int{5, 5}},
+		{columns: []Column{{}, {}, {}}, total: 12, want: []int{4, 3, 3}},
+		// The remainder goes to the first flexible columns, not the first columns
+		{columns: []Column{{Width: 10}, {}, {}}, total: 33, want: []int{10, 11, 10}},
+	}
+	for _, tt := range tests {
+		got, err := Table{Columns: tt.columns, Gap: 1}.widths(tt.total)
+		if err != nil {
+			t.Errorf("widths(%d) failed: %v", tt.total, err)
+			continue
+		}
+		sum := tt.total - (len(tt.columns) - 1)
+		for i := range got {
+			sum -= got[i]
+			if got[i] != tt.want[i] {
+				t.Errorf("widths(%d) = %v, want %v", tt.total, got, tt.want)
+				break
+			}
+		}
+		if sum != 0 {
+			t.Errorf("widths(%d) = %v leave %d cells unused", tt.total, got, sum)
+		}
+	}
+
+	if _, err := (Table{Columns: []Column{{Width: 20}, {}}, Gap: 1}).widths(20); err == nil {
+		t.Error("widths accepted columns wider than the line")
+	}
+	if _, err := (Table{Columns: []Column{{}}}).Render([][]string{{"a", "b"}}, 10); err == nil {
+		t.Error("Render accepted a row with more cells than columns")
+	}
+}
+
+func TestReceiptLayout(t *testing.T) {
+	r, err := ParseReceipt(`{"blocks": [
+		{"type": "header", "text": "CAFE"},
+		{"type": "text", "text": "Big", "size": 2, "align": "right"},
+		{"type": "rule", "char": "="},
+		{"type": "table", "columns": [{}, {"width": 6, "align": "right"}], "header": ["Item", "Price"], "rows": [["Tea", "2.50"]]},
+		{"type": "kv", "rows": [["Total", "2.50"]]},
+		{"type": "blank", "lines": 2}
+	]}`)
+	if err != nil {
+		t.Fatalf("ParseReceipt failed: %v", err)
+	}
+	lines, err := r.Layout(16)
+	if err != nil {
+		t.Fatalf("Layout failed: %v", err)
+	}
+
+	want := []Line{
+		{Text: "      CAFE", Bold: true, Size: 1},
+		{Text: "     Big", Size: 2},
+		{Text: "================", Size: 1},
+		{Text: "Item       Price", Bold: true, Size: 1},
+		{Text: "----------------", Size: 1},
+		{Text: "Tea         2.50", Size: 1},
+		{Text: "Total       2.50", Size: 1},
+		{},
+		{},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("Layout = %q, want %d lines", Text(lines), len(want))
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d = %+v, want %+v", i+1, lines[i], want[i])
+		}
+	}
+}
+
+func TestParseReceiptErrors(t *testing.T) {
+	tests := []struct {
+		json string
+		want string
+	}{
+		{json: `{"blocks": []}`, want: "no blocks"},
+		{json: `{"blocks": [{"type": "image"}]}`, want: "block 1 (image): unknown block type"},
+		{json: `{"blocks": [{"type": "text", "align": "justify"}]}`, want: "invalid align"},
+		{json: `{"blocks": [{"type": "text", "size": 3}]}`, want: "size must be 1 or 2"},
+		{json: `{"blocks": [{"type": "table"}]}`, want: "missing columns"},
+		{json: `{"blocks": [{"type": "kv", "columns": [{}]}]}`, want: "two columns"},
+		{json: `{"blocks": [{"type": "rule", "char": "=="}]}`, want: "single character"},
+		{json: `{"blocks": [{"type": "blank", "lines": 21}]}`, want: "between 1 and 20"},
+	}
+	for _, tt := range tests {
+		_, err := ParseReceipt(tt.json)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseReceipt(%s) error = %v, want %q", tt.json, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package layout
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Receipt is a structured receipt laid out in monospaced lines
+type Receipt struct {
+	Blocks []Block `json:"blocks"`
+	Cut    bool    `json:"cut,omitempty"` // Cut the paper after the receipt
+}
+
+// Block is one part of a receipt. Which fields apply depends on Type:
+// text, header, table, kv, rule or blank.
+type Block struct {
+	Type string `json:"type"`
+
+	// text, header
+	Text  string `json:"text,omitempty"`
+	Align string `json:"align,omitempty"` // left, center, right
+	Bold  bool   `json:"bold,omitempty"`
+	Size  int    `json:"size,omitempty"` // 1 or 2, double size halves the line width
+
+	// table, kv
+	Columns []Column   `json:"columns,omitempty"`
+	Header  []string   `json:"header,omitempty"` // Bold first row, followed by a rule
+	Rows    [][]string `json:"rows,omitempty"`
+
+	// rule
+	Char string `json:"char,omitempty"` // defaults to "-"
+
+	// blank
+	Lines int `json:"lines,omitempty"` // defaults to 1
+}
+
+// Line is one laid out line of a receipt
+type Line struct {
+	Text string
+	Bold bool
+	Size int // 1 or 2
+}
+
+// ParseReceipt decodes and checks a JSON receipt description
+func ParseReceipt(content string) (*Receipt, error) {
+	var r Receipt
+	if err := json.Unmarshal([]byte(content), &r); err != nil {
+		return nil, fmt.Errorf("invalid receipt: %w", err)
+	}
+	if len(r.Blocks) == 0 {
+		return nil, fmt.Errorf("receipt has no blocks")
+	}
+
+	for i := range r.Blocks {
+		if err := r.Blocks[i].normalize(); err != nil {
+			return nil, fmt.Errorf("block %d (%s): %w", i+1, r.Blocks[i].Type, err)
+		}
+	}
+	return &r, nil
+}
+
+// normalize checks a block and fills in defaults
+func (b *Block) normalize() error {
+	if _, ok := aligns[b.Align]; !ok {
+		return fmt.Errorf("invalid align %q", b.Align)
+	}
+	for i, c := range b.Columns {
+		if _, ok := aligns[c.Align]; !ok {
+			return fmt.Errorf("column %d: invalid align %q", i+1, c.Align)
+		}
+		if c.Wrap != "" && c.Wrap != "wrap" && c.Wrap != "truncate" {
+			return fmt.Errorf("column %d: wrap must be wrap or truncate", i+1)
+		}
+	}
+
+	switch b.Type {
+	case "text", "header":
+		if b.Size == 0 {
+			b.Size = 1
+		}
+		if b.Size != 1 && b.Size != 2 {
+			return fmt.Errorf("size must be 1 or 2")
+		}
+		if b.Type == "header" {
+			b.Bold = true
+			if b.Align == "" {
+				b.Align = "center"
+			}
+		}
+	case "table":
+		if len(b.Columns) == 0 {
+			return fmt.Errorf("missing columns")
+		}
+		if b.Header != nil && len(b.Header) > len(b.Columns) {
+			return fmt.Errorf("header has %d cells for %d columns", len(b.Header), len(b.Columns))
+		}
+	case "kv":
+		// Key on the left, value on the right
+		if b.Columns == nil {
+			b.Columns = []Column{{}, {Align: "right", Wrap: "wrap"}}
+		}
+		if len(b.Columns) != 2 {
+			return fmt.Errorf("kv blocks have two columns")
+		}
+	case "rule":
+		if b.Char == "" {
+			b.Char = "-"
+		}
+		if Width(b.Char) != 1 {
+			return fmt.Errorf("char must be a single character")
+		}
+	case "blank":
+		if b.Lines == 0 {
+			b.Lines = 1
+		}
+		if b.Lines < 0 || b.Lines > 20 {
+			return fmt.Errorf("lines must be between 1 and 20")
+		}
+	default:
+		return fmt.Errorf("unknown block type")
+	}
+	return nil
+}
+
+// aligns lists the accepted alignments, empty meaning left
+var aligns = map[string]struct{}{"": {}, "left": {}, "center": {}, "right": {}}
+
+// Layout lays the receipt out in lines of the given number of columns
+func (r *Receipt) Layout(columns int) ([]Line, error) {
+	if columns <= 0 {
+		return nil, fmt.Errorf("invalid line width %d", columns)
+	}
+
+	var lines []Line
+	for i, b := range r.Blocks {
+		blockLines, err := b.layout(columns)
+		if err != nil {
+			return nil, fmt.Errorf("block %d (%s): %w", i+1, b.Type, err)
+		}
+		lines = append(lines, blockLines...)
+	}
+	return lines, nil
+}
+
+// layout lays out a single block
+func (b Block) layout(columns int) ([]Line, error) {
+	switch b.Type {
+	case "text", "header":
+		// Double width characters fit half as many on a line
+		width := columns / b.Size
+		var lines []Line
+		for _, paragraph := range strings.Split(b.Text, "\n") {
+			for _, text := range Wrap(paragraph, width) {
+				text = strings.TrimRight(Pad(text, width, b.Align), " ")
+				lines = append(lines, Line{Text: text, Bold: b.Bold, Size: b.Size})
+			}
+		}
+		return lines, nil
+	case "table", "kv":
+		t := Table{Columns: b.Columns, Gap: 1}
+		var lines []Line
+		if b.Header != nil {
+			header, err := t.Render([][]string{b.Header}, columns)
+			if err != nil {
+				return nil, err
+			}
+			for _, text := range header {
+				lines = append(lines, Line{Text: text, Bold: true, Size: 1})
+			}
+			lines = append(lines, Line{Text: strings.Repeat("-", columns), Size: 1})
+		}
+		rows, err := t.Render(b.Rows, columns)
+		if err != nil {
+			return nil, err
+		}
+		for _, text := range rows {
+			lines = append(lines, Line{Text: text, Size: 1})
+		}
+		return lines, nil
+	case "rule":
+		return []Line{{Text: strings.Repeat(b.Char, columns), Size: 1}}, nil
+	case "blank":
+		return make([]Line, b.Lines), nil
+	}
+	return nil, fmt.Errorf("unknown block type")
+}
+
+// Text joins laid out lines as plain text, for printers without styles
+func Text(lines []Line) string {
+	var sb strings.Builder
+	for _, line := range lines {
+		sb.WriteString(line.Text)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
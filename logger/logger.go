@@ -46,13 +46,36 @@ func Error(msg string, err error) {
 	log.Error().Err(err).Msg(msg)
 }
 
-// PrintRequest logs an incoming print request
-func PrintRequest(contentType string, contentLength int, remoteAddr string) {
-	log.Info().
+// PrintRequest logs an incoming print request. client is the name of
+// the API key used and identity the client certificate's, if any.
+func PrintRequest(contentType string, contentLength int, remoteAddr string, client string, identity string) {
+	event := log.Info().
 		Str("type", contentType).
 		Int("content_length", contentLength).
-		Str("remote_addr", remoteAddr).
-		Msg("Print request received")
+		Str("remote_addr", remoteAddr)
+	if client != "" {
+		event = event.Str("client", client)
+	}
+	if identity != "" {
+		event = event.Str("identity", identity)
+	}
+	event.Msg("Print request received")
+}
+
+// AccessDenied logs a request rejected by origin, API key or client
+// certificate checks
+func AccessDenied(reason string, path string, remoteAddr string, client string, identity string) {
+	event := log.Warn().
+		Str("reason", reason).
+		Str("path", path).
+		Str("remote_addr", remoteAddr)
+	if client != "" {
+		event = event.Str("client", client)
+	}
+	if identity != "" {
+		event = event.Str("identity", identity)
+	}
+	event.Msg("Request denied")
 }
 
 // PrintSuccess logs a successful print
@@ -80,3 +103,15 @@ func ServerStarted(port int) {
 func ServerStopped() {
 	log.Info().Msg("Print server stopped")
 }
+
+// JobUpdated logs a print job state change
+func JobUpdated(jobID string, state string, errMsg string) {
+	event := log.Info()
+	if errMsg != "" {
+		event = log.Error().Str("error", errMsg)
+	}
+	event.
+		Str("job_id", jobID).
+		Str("state", state).
+		Msg("Print job updated")
+}
@@ -73,6 +73,12 @@ func main() {
 		e.Cancel()
 	})
 
+	// Bring the window up when a browser origin needs the operator's approval
+	app.Event.On("origin-approval", func(e *application.CustomEvent) {
+		window.Show()
+		window.Focus()
+	})
+
 	// Create system tray (NATIVE!)
 	tray := app.SystemTray.New()
 
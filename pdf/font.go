@@ -0,0 +1,139 @@
+package pdf
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Font is one of the standard PDF fonts, which every viewer and printer
+// has built in, so nothing needs to be embedded
+type Font struct {
+	Name   string // PostScript name
+	widths [256]int
+}
+
+// Standard fonts by config name
+var fonts = map[string]*Font{
+	"courier":   newFont("Courier", courierWidth),
+	"helvetica": newFont("Helvetica", helveticaWidth),
+}
+
+// DefaultFont is monospaced, so columns in reports line up
+const DefaultFont = "courier"
+
+// LookupFont returns the standard font with the given name
+func LookupFont(name string) (*Font, error) {
+	if name == "" {
+		name = DefaultFont
+	}
+	f, ok := fonts[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported font %q (supported: courier, helvetica)", name)
+	}
+	return f, nil
+}
+
+// newFont builds the width table for the WinAnsi code points
+func newFont(name string, width func(rune) int) *Font {
+	f := &Font{Name: name}
+	for b := 32; b < 256; b++ {
+		f.widths[b] = width(charmap.Windows1252.DecodeByte(byte(b)))
+	}
+	return f
+}
+
+// resource returns the font dictionary
+func (f *Font) resource() string {
+	return fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", f.Name)
+}
+
+// Width returns the width of WinAnsi encoded text in points
+func (f *Font) Width(s []byte, size float64) float64 {
+	total := 0
+	for _, b := range s {
+		total += f.widths[b]
+	}
+	return float64(total) * size / 1000
+}
+
+// encodeWinAnsi encodes text for the standard fonts. Characters outside
+// WinAnsi fall back to their unaccented letter, then to '?'.
+func encodeWinAnsi(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		if b, ok := charmap.Windows1252.EncodeRune(r); ok && b >= 32 {
+			out = append(out, b)
+			continue
+		}
+		if base := baseLetter(r); base != r {
+			if b, ok := charmap.Windows1252.EncodeRune(base); ok {
+				out = append(out, b)
+				continue
+			}
+		}
+		out = append(out, '?')
+	}
+	return out
+}
+
+// baseLetter returns the letter without its accents, e.g. o for ő
+func baseLetter(r rune) rune {
+	decomposed := []rune(norm.NFD.String(string(r)))
+	if len(decomposed) < 2 {
+		return r
+	}
+	for _, mark := range decomposed[1:] {
+		if !unicode.Is(unicode.Mn, mark) {
+			return r
+		}
+	}
+	return decomposed[0]
+}
+
+// courierWidth returns the Courier advance width in 1/1000 em
+func courierWidth(rune) int {
+	return 600
+}
+
+// helveticaASCII lists the Helvetica widths of ' ' to '~'
+var helveticaASCII = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+// helveticaOther lists the Helvetica widths of WinAnsi characters that
+// aren't ASCII or accented ASCII letters
+var helveticaOther = map[rune]int{
+	'€': 556, '‚': 222, 'ƒ': 556, '„': 333, '…': 1000, '†': 556, '‡': 556, 'ˆ': 333,
+	'‰': 1000, '‹': 333, 'Œ': 1000, '‘': 222, '’': 222, '“': 333, '”': 333, '•': 350,
+	'–': 556, '—': 1000, '˜': 333, '™': 1000, '›': 333, 'œ': 944,
+	'\u00a0': 278, '¡': 333, '¢': 556, '£': 556, '¤': 556, '¥': 556, '¦': 260, '§': 556,
+	'¨': 333, '©': 737, 'ª': 370, '«': 556, '¬': 584, '\u00ad': 333, '®': 737, '¯': 333,
+	'°': 400, '±': 584, '²': 333, '³': 333, '´': 333, 'µ': 556, '¶': 537, '·': 278,
+	'¸': 333, '¹': 333, 'º': 365, '»': 556, '¼': 834, '½': 834, '¾': 834, '¿': 611,
+	'Æ': 1000, 'Ð': 722, '×': 584, 'Ø': 778, 'Þ': 667, 'ß': 611,
+	'æ': 889, 'ð': 556, '÷': 584, 'ø': 611, 'þ': 556,
+}
+
+// helveticaWidth returns the Helvetica advance width in 1/1000 em
+func helveticaWidth(r rune) int {
+	if r >= ' ' && r <= '~' {
+		return helveticaASCII[r-' ']
+	}
+	if w, ok := helveticaOther[r]; ok {
+		return w
+	}
+	// Accented letters are as wide as the plain letter
+	if base := baseLetter(r); base >= ' ' && base <= '~' {
+		return helveticaASCII[base-' ']
+	}
+	return 556
+}
@@ -0,0 +1,320 @@
+package pdf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+
+	"goprint-bridge/raster"
+)
+
+// Image layout defaults
+const (
+	DefaultImageMargin = 10 // Millimetres
+	DefaultImageDPI    = 96 // Assumed for actual size when the file doesn't say
+	cellGap            = 5  // Millimetres between images on a page
+)
+
+// Image scaling modes
+const (
+	ScaleFit    = "fit"    // Whole image, as large as fits
+	ScaleFill   = "fill"   // Cover the whole cell, cropping the overflow
+	ScaleActual = "actual" // The size the image's resolution gives, cropped if larger
+)
+
+// grids maps images per page to columns and rows on a portrait page
+var grids = map[int][2]int{
+	1:  {1, 1},
+	2:  {1, 2},
+	4:  {2, 2},
+	6:  {2, 3},
+	9:  {3, 3},
+	16: {4, 4},
+}
+
+// ImageOptions controls how images are placed on pages
+type ImageOptions struct {
+	PageSize    string  // A4 (default), Letter, ... or a CUPS media name
+	Orientation string  // portrait or landscape, chosen per page to suit the images when empty
+	Margin      float64 // Millimetres on every side
+	Scale       string  // fit (default), fill or actual
+	PerPage     int     // Images per page: 1 (default), 2, 4, 6, 9 or 16
+	Title       string  // Document title
+}
+
+// Images places PNG, JPEG, GIF or TIFF images on pages of a PDF
+func Images(images [][]byte, opts ImageOptions) ([]byte, error) {
+	if len(images) == 0 {
+		return nil, fmt.Errorf("no images")
+	}
+	switch opts.Scale {
+	case "":
+		opts.Scale = ScaleFit
+	case ScaleFit, ScaleFill, ScaleActual:
+	default:
+		return nil, fmt.Errorf("invalid scale %q", opts.Scale)
+	}
+	if opts.PerPage == 0 {
+		opts.PerPage = 1
+	}
+	if _, ok := grids[opts.PerPage]; !ok {
+		return nil, fmt.Errorf("images per page must be 1, 2, 4, 6, 9 or 16")
+	}
+	if opts.Orientation != "" && opts.Orientation != "portrait" && opts.Orientation != "landscape" {
+		return nil, fmt.Errorf("invalid orientation %q", opts.Orientation)
+	}
+
+	var d document
+	var imgs []*pdfImage
+	for i, data := range images {
+		img, err := loadImage(data)
+		if err != nil {
+			return nil, fmt.Errorf("image %d: %w", i+1, err)
+		}
+		img.object = d.stream(img.dict, img.data, img.filter)
+		img.name = fmt.Sprintf("Im%d", i+1)
+		imgs = append(imgs, img)
+	}
+
+	var pages []page
+	for start := 0; start < len(imgs); start += opts.PerPage {
+		end := min(start+opts.PerPage, len(imgs))
+		p, err := imagePage(imgs[start:end], opts)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, p)
+	}
+	return d.write(pages, opts.Title), nil
+}
+
+// imagePage lays out one page of images in the orientation that shows
+// them best, unless an orientation is given
+func imagePage(imgs []*pdfImage, opts ImageOptions) (page, error) {
+	var best []placement
+	var bestW, bestH, bestScore float64
+	for _, landscape := range []bool{false, true} {
+		if opts.Orientation != "" && landscape != (opts.Orientation == "landscape") {
+			continue
+		}
+		w, h, err := PageSize(opts.PageSize, landscape)
+		if err != nil {
+			return page{}, err
+		}
+		placements, err := place(imgs, w, h, opts)
+		if err != nil {
+			return page{}, err
+		}
+
+		score := 0.0
+		for _, p := range placements {
+			score += p.score()
+		}
+		// Landscape only wins when it's strictly better
+		if best == nil || score > bestScore {
+			best, bestW, bestH, bestScore = placements, w, h, score
+		}
+	}
+
+	var content strings.Builder
+	var xobjects []string
+	for _, p := range best {
+		fmt.Fprintf(&content, "q %s %s %s %s re W n %s 0 0 %s %s %s cm /%s Do Q\n",
+			num(p.clip.x), num(p.clip.y), num(p.clip.w), num(p.clip.h),
+			num(p.draw.w), num(p.draw.h), num(p.draw.x), num(p.draw.y), p.img.name)
+		xobjects = append(xobjects, fmt.Sprintf("/%s %d 0 R", p.img.name, p.img.object))
+	}
+	return page{
+		width:     bestW,
+		height:    bestH,
+		content:   []byte(content.String()),
+		resources: fmt.Sprintf("/XObject << %s >>", strings.Join(xobjects, " ")),
+	}, nil
+}
+
+// rect is a rectangle in points, from its lower left corner
+type rect struct {
+	x, y, w, h float64
+}
+
+// placement is an image drawn at draw and clipped to its cell
+type placement struct {
+	img  *pdfImage
+	draw rect
+	clip rect
+}
+
+// score rates a placement: the visible area, less what is cropped
+func (p placement) score() float64 {
+	visibleW := min(p.draw.x+p.draw.w, p.clip.x+p.clip.w) - max(p.draw.x, p.clip.x)
+	visibleH := min(p.draw.y+p.draw.h, p.clip.y+p.clip.h) - max(p.draw.y, p.clip.y)
+	visible := max(visibleW, 0) * max(visibleH, 0)
+	return visible * visible / (p.draw.w * p.draw.h)
+}
+
+// place lays the images out in a grid of cells, left to right and top
+// to bottom, each image centered in its cell
+func place(imgs []*pdfImage, width, height float64, opts ImageOptions) ([]placement, error) {
+	grid := grids[opts.PerPage]
+	cols, rows := grid[0], grid[1]
+	if width > height {
+		cols, rows = rows, cols
+	}
+
+	margin := opts.Margin * pointsPerMM
+	gap := float64(cellGap) * pointsPerMM
+	if opts.PerPage == 1 {
+		gap = 0
+	}
+	cellW := (width - 2*margin - float64(cols-1)*gap) / float64(cols)
+	cellH := (height - 2*margin - float64(rows-1)*gap) / float64(rows)
+	if opts.Margin < 0 || cellW <= 0 || cellH <= 0 {
+		return nil, fmt.Errorf("margin leaves no room on the page")
+	}
+
+	placements := make([]placement, len(imgs))
+	for i, img := range imgs {
+		col, row := i%cols, i/cols
+		cell := rect{
+			x: margin + float64(col)*(cellW+gap),
+			y: height - margin - float64(row+1)*cellH - float64(row)*gap,
+			w: cellW,
+			h: cellH,
+		}
+
+		iw, ih := float64(img.width), float64(img.height)
+		var w, h float64
+		switch opts.Scale {
+		case ScaleFit:
+			s := min(cellW/iw, cellH/ih)
+			w, h = iw*s, ih*s
+		case ScaleFill:
+			s := max(cellW/iw, cellH/ih)
+			w, h = iw*s, ih*s
+		case ScaleActual:
+			w, h = iw*72/img.dpi, ih*72/img.dpi
+		}
+
+		placements[i] = placement{
+			img:  img,
+			draw: rect{x: cell.x + (cellW-w)/2, y: cell.y + (cellH-h)/2, w: w, h: h},
+			clip: cell,
+		}
+	}
+	return placements, nil
+}
+
+// pdfImage is an image XObject
+type pdfImage struct {
+	width, height int
+	dpi           float64
+	dict          string
+	data          []byte
+	filter        string // Set when data is already compressed
+
+	object int    // Object number once added
+	name   string // Resource name
+}
+
+// loadImage prepares an image for embedding. RGB and gray JPEGs are kept
+// as they are; other images are decoded and stored deflated.
+func loadImage(data []byte) (*pdfImage, error) {
+	cfg, format, err := raster.DecodeConfig(data)
+	if err != nil {
+		return nil, err
+	}
+	img := &pdfImage{width: cfg.Width, height: cfg.Height, dpi: imageDPI(data, format)}
+
+	if format == "jpeg" && (cfg.ColorModel == color.YCbCrModel || cfg.ColorModel == color.GrayModel) {
+		colorSpace := "/DeviceRGB"
+		if cfg.ColorModel == color.GrayModel {
+			colorSpace = "/DeviceGray"
+		}
+		img.dict = fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s /BitsPerComponent 8", cfg.Width, cfg.Height, colorSpace)
+		img.data = data
+		img.filter = "/DCTDecode"
+		return img, nil
+	}
+
+	decoded, err := raster.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	pix, colorSpace := samples(decoded)
+	img.dict = fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s /BitsPerComponent 8", cfg.Width, cfg.Height, colorSpace)
+	img.data = pix
+	return img, nil
+}
+
+// samples returns 8-bit gray or RGB samples, with transparent pixels
+// blended onto white paper
+func samples(img image.Image) ([]byte, string) {
+	b := img.Bounds()
+	gray := false
+	switch img.ColorModel() {
+	case color.GrayModel, color.Gray16Model:
+		gray = true
+	}
+
+	channels := 3
+	colorSpace := "/DeviceRGB"
+	if gray {
+		channels = 1
+		colorSpace = "/DeviceGray"
+	}
+
+	pix := make([]byte, 0, b.Dx()*b.Dy()*channels)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, a := img.At(x, y).RGBA()
+			// Premultiplied, so adding the uncovered part gives white
+			white := 0xFFFF - a
+			r, g, bl = (r+white)>>8, (g+white)>>8, (bl+white)>>8
+			if gray {
+				pix = append(pix, byte(r))
+			} else {
+				pix = append(pix, byte(r), byte(g), byte(bl))
+			}
+		}
+	}
+	return pix, colorSpace
+}
+
+// imageDPI reads the resolution stored in a JPEG (JFIF) or PNG (pHYs)
+// file, falling back to DefaultImageDPI
+func imageDPI(data []byte, format string) float64 {
+	switch format {
+	case "jpeg":
+		// SOI, then the APP0 segment: length, "JFIF\0", version, units, density
+		if len(data) >= 18 && data[2] == 0xFF && data[3] == 0xE0 && string(data[6:11]) == "JFIF\x00" {
+			units := data[13]
+			density := float64(binary.BigEndian.Uint16(data[14:16]))
+			switch {
+			case units == 1 && density > 0:
+				return density
+			case units == 2 && density > 0:
+				return density * 2.54
+			}
+		}
+	case "png":
+		// Chunks follow the 8 byte signature: length, type, data, CRC
+		for pos := 8; pos+8 <= len(data); {
+			length := int(binary.BigEndian.Uint32(data[pos:]))
+			kind := string(data[pos+4 : pos+8])
+			if kind == "pHYs" && length == 9 && pos+17 <= len(data) {
+				perMetre := float64(binary.BigEndian.Uint32(data[pos+8:]))
+				if data[pos+16] == 1 && perMetre > 0 {
+					return perMetre * 0.0254
+				}
+				break
+			}
+			if kind == "IDAT" {
+				break
+			}
+			pos += 12 + length
+		}
+	}
+	return DefaultImageDPI
+}
@@ -0,0 +1,235 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// encodePNG encodes an image as PNG
+func encodePNG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withDPI adds a pHYs chunk with the given resolution after the IHDR
+func withDPI(data []byte, dpi float64) []byte {
+	chunk := make([]byte, 4+9)
+	copy(chunk, "pHYs")
+	perMetre := uint32(dpi/0.0254 + 0.5)
+	binary.BigEndian.PutUint32(chunk[4:], perMetre)
+	binary.BigEndian.PutUint32(chunk[8:], perMetre)
+	chunk[12] = 1 // Metres
+
+	// Signature (8) and IHDR (4 length, 4 type, 13 data, 4 CRC)
+	var out bytes.Buffer
+	out.Write(data[:33])
+	binary.Write(&out, binary.BigEndian, uint32(9))
+	out.Write(chunk)
+	binary.Write(&out, binary.BigEndian, crc32.ChecksumIEEE(chunk))
+	out.Write(data[33:])
+	return out.Bytes()
+}
+
+// rgba returns an image of one color
+func rgba(width, height int, c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+var drawPattern = regexp.MustCompile(`q (\S+) (\S+) (\S+) (\S+) re W n (\S+) 0 0 (\S+) (\S+) (\S+) cm /(Im\d+) Do Q`)
+
+// draws returns the images a page draws: clip rectangle, size, position
+// and name of each
+func draws(t *testing.T, content string) [][]string {
+	t.Helper()
+	var out [][]string
+	for _, m := range drawPattern.FindAllStringSubmatch(content, -1) {
+		out = append(out, m[1:])
+	}
+	return out
+}
+
+func TestImagesSamples(t *testing.T) {
+	half := rgba(2, 1, color.NRGBA{R: 255, A: 255})
+	half.SetNRGBA(1, 0, color.NRGBA{}) // Transparent
+	gray := image.NewGray(image.Rect(0, 0, 2, 1))
+	gray.SetGray(0, 0, color.Gray{Y: 0x40})
+
+	var jpg bytes.Buffer
+	if err := jpeg.Encode(&jpg, rgba(8, 8, color.NRGBA{G: 255, A: 255}), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := Images([][]byte{encodePNG(t, half), encodePNG(t, gray), jpg.Bytes()}, ImageOptions{PerPage: 4})
+	if err != nil {
+		t.Fatalf("Images failed: %v", err)
+	}
+	p := parsePDF(t, data)
+	pages := p.pages(t)
+	if len(pages) != 1 {
+		t.Fatalf("%d pages, want 1", len(pages))
+	}
+
+	tests := []struct {
+		name    string
+		dict    string
+		samples []byte
+	}{
+		// Transparent pixels are white
+		{name: "Im1", dict: "/Width 2 /Height 1 /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode", samples: []byte{255, 0, 0, 255, 255, 255}},
+		{name: "Im2", dict: "/Width 2 /Height 1 /ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /FlateDecode", samples: []byte{0x40, 0}},
+		// JPEGs are embedded as they are
+		{name: "Im3", dict: "/Width 8 /Height 8 /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /DCTDecode", samples: jpg.Bytes()},
+	}
+	for _, tt := range tests {
+		dict, samples := p.stream(t, p.ref(t, pages[0], "/"+tt.name))
+		if !strings.Contains(dict, "/Type /XObject /Subtype /Image "+tt.dict) {
+			t.Errorf("%s dictionary = %q, want %q", tt.name, dict, tt.dict)
+		}
+		if !bytes.Equal(samples, tt.samples) {
+			t.Errorf("%s samples = % X, want % X", tt.name, samples[:min(len(samples), 16)], tt.samples[:min(len(tt.samples), 16)])
+		}
+	}
+}
+
+func TestImagesLayout(t *testing.T) {
+	wide := encodePNG(t, rgba(200, 100, color.NRGBA{A: 255}))
+	tall := encodePNG(t, rgba(100, 200, color.NRGBA{A: 255}))
+
+	tests := []struct {
+		name   string
+		images [][]byte
+		opts   ImageOptions
+		box    string
+		draws  [][]string
+	}{
+		{
+			// A wide image turns the page to landscape and fits the margins
+			name:   "fit",
+			images: [][]byte{wide},
+			opts:   ImageOptions{Margin: 10},
+			box:    "[0 0 841.89 595.28]",
+			draws:  [][]string{{"28.35", "28.35", "785.2", "538.58", "785.2", "392.6", "28.35", "101.34", "Im1"}},
+		},
+		{
+			name:   "fill crops to the page",
+			images: [][]byte{wide},
+			opts:   ImageOptions{Scale: ScaleFill, Orientation: "portrait"},
+			box:    "[0 0 595.28 841.89]",
+			draws:  [][]string{{"0", "0", "595.28", "841.89", "1683.78", "841.89", "-544.25", "0", "Im1"}},
+		},
+		{
+			// 200 pixels at 96 dpi are 150 points
+			name:   "actual size",
+			images: [][]byte{tall},
+			opts:   ImageOptions{Scale: ScaleActual},
+			box:    "[0 0 595.28 841.89]",
+			draws:  [][]string{{"0", "0", "595.28", "841.89", "75", "150", "260.14", "345.94", "Im1"}},
+		},
+		{
+			// 300 pixels at 300 dpi are an inch
+			name:   "actual size at the stored resolution",
+			images: [][]byte{withDPI(encodePNG(t, rgba(300, 300, color.NRGBA{A: 255})), 300)},
+			opts:   ImageOptions{Scale: ScaleActual},
+			box:    "[0 0 595.28 841.89]",
+			draws:  [][]string{{"0", "0", "595.28", "841.89", "72", "72", "261.64", "384.94", "Im1"}},
+		},
+		{
+			// Two per page stack on a portrait page
+			name:   "two up",
+			images: [][]byte{tall, tall},
+			opts:   ImageOptions{PerPage: 2, Margin: 10, Orientation: "portrait"},
+			box:    "[0 0 595.28 841.89]",
+			draws: [][]string{
+				{"28.35", "428.03", "538.58", "385.51", "192.76", "385.51", "201.26", "428.03", "Im1"},
+				{"28.35", "28.35", "538.58", "385.51", "192.76", "385.51", "201.26", "28.35", "Im2"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Images(tt.images, tt.opts)
+			if err != nil {
+				t.Fatalf("Images failed: %v", err)
+			}
+			p := parsePDF(t, data)
+			pages := p.pages(t)
+			if len(pages) != 1 {
+				t.Fatalf("%d pages, want 1", len(pages))
+			}
+			if !strings.Contains(pages[0], "/MediaBox "+tt.box) {
+				t.Errorf("page = %q, want media box %s", pages[0], tt.box)
+			}
+			got := draws(t, p.content(t, pages[0]))
+			if len(got) != len(tt.draws) {
+				t.Fatalf("draws = %v, want %v", got, tt.draws)
+			}
+			for i := range got {
+				if strings.Join(got[i], " ") != strings.Join(tt.draws[i], " ") {
+					t.Errorf("draw %d = %v, want %v", i+1, got[i], tt.draws[i])
+				}
+			}
+		})
+	}
+}
+
+func TestImagesPerPage(t *testing.T) {
+	img := encodePNG(t, rgba(10, 10, color.NRGBA{A: 255}))
+	images := [][]byte{img, img, img, img, img, img, img}
+
+	data, err := Images(images, ImageOptions{PerPage: 4, Margin: DefaultImageMargin})
+	if err != nil {
+		t.Fatalf("Images failed: %v", err)
+	}
+	p := parsePDF(t, data)
+	pages := p.pages(t)
+	if len(pages) != 2 {
+		t.Fatalf("%d pages, want 2", len(pages))
+	}
+	for i, want := range []int{4, 3} {
+		if got := len(draws(t, p.content(t, pages[i]))); got != want {
+			t.Errorf("page %d draws %d images, want %d", i+1, got, want)
+		}
+	}
+}
+
+func TestImagesErrors(t *testing.T) {
+	img := encodePNG(t, rgba(10, 10, color.NRGBA{A: 255}))
+	tests := []struct {
+		name   string
+		images [][]byte
+		opts   ImageOptions
+		want   string
+	}{
+		{name: "no images", want: "no images"},
+		{name: "scale", images: [][]byte{img}, opts: ImageOptions{Scale: "stretch"}, want: "invalid scale"},
+		{name: "per page", images: [][]byte{img}, opts: ImageOptions{PerPage: 3}, want: "images per page"},
+		{name: "orientation", images: [][]byte{img}, opts: ImageOptions{Orientation: "upside-down"}, want: "invalid orientation"},
+		{name: "margin", images: [][]byte{img}, opts: ImageOptions{Margin: 200}, want: "no room"},
+		{name: "not an image", images: [][]byte{img, []byte("GIF89a")}, want: "image 2:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Images(tt.images, tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Images error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package pdf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultPageSize is used when no page size is given
+const DefaultPageSize = "A4"
+
+// pageSizes lists portrait page sizes in millimetres
+var pageSizes = map[string][2]float64{
+	"a3":      {297, 420},
+	"a4":      {210, 297},
+	"a5":      {148, 210},
+	"a6":      {105, 148},
+	"letter":  {215.9, 279.4},
+	"legal":   {215.9, 355.6},
+	"tabloid": {279.4, 431.8},
+}
+
+// PageSize returns the width and height in points of a named page size,
+// e.g. A4 or Letter. CUPS media names such as iso_a4_210x297mm and
+// na_letter_8.5x11in are accepted too.
+func PageSize(name string, landscape bool) (float64, float64, error) {
+	if name == "" {
+		name = DefaultPageSize
+	}
+
+	key := strings.ToLower(name)
+	if parts := strings.Split(key, "_"); len(parts) == 3 {
+		key = parts[1]
+	}
+	size, ok := pageSizes[key]
+	if !ok {
+		return 0, 0, fmt.Errorf("unsupported page size %q (supported: A3, A4, A5, A6, Letter, Legal, Tabloid)", name)
+	}
+
+	w, h := size[0]*pointsPerMM, size[1]*pointsPerMM
+	if landscape {
+		w, h = h, w
+	}
+	return w, h, nil
+}
+
+// Margins are page margins in millimetres
+type Margins struct {
+	Top    float64
+	Right  float64
+	Bottom float64
+	Left   float64
+}
+
+// points returns the margins in points
+func (m Margins) points() Margins {
+	return Margins{
+		Top:    m.Top * pointsPerMM,
+		Right:  m.Right * pointsPerMM,
+		Bottom: m.Bottom * pointsPerMM,
+		Left:   m.Left * pointsPerMM,
+	}
+}
+
+// check rejects negative margins and margins that leave no room
+func (m Margins) check(width, height float64) error {
+	if m.Top < 0 || m.Right < 0 || m.Bottom < 0 || m.Left < 0 {
+		return fmt.Errorf("margins must not be negative")
+	}
+	p := m.points()
+	if p.Left+p.Right >= width || p.Top+p.Bottom >= height {
+		return fmt.Errorf("margins leave no room on the page")
+	}
+	return nil
+}
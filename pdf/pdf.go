@@ -0,0 +1,140 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Points per millimetre
+const pointsPerMM = 72 / 25.4
+
+// document collects numbered objects and writes them with a cross
+// reference table. Object numbers start at 1.
+type document struct {
+	objects [][]byte
+}
+
+// reserve allocates an object number to be filled in later
+func (d *document) reserve() int {
+	d.objects = append(d.objects, nil)
+	return len(d.objects)
+}
+
+// set fills in a reserved object
+func (d *document) set(n int, body string) {
+	d.objects[n-1] = []byte(body)
+}
+
+// add appends an object and returns its number
+func (d *document) add(body string) int {
+	n := d.reserve()
+	d.set(n, body)
+	return n
+}
+
+// stream appends a stream object. Extra dictionary entries go in dict;
+// unless the data is already compressed (filter set), it is deflated.
+func (d *document) stream(dict string, data []byte, filter string) int {
+	if filter == "" {
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		zw.Write(data)
+		zw.Close()
+		data = buf.Bytes()
+		filter = "/FlateDecode"
+	}
+
+	if dict != "" {
+		dict += " "
+	}
+	var obj bytes.Buffer
+	fmt.Fprintf(&obj, "<< %s/Filter %s /Length %d >>\nstream\n", dict, filter, len(data))
+	obj.Write(data)
+	obj.WriteString("\nendstream")
+
+	n := d.reserve()
+	d.objects[n-1] = obj.Bytes()
+	return n
+}
+
+// page is the content and resources of one page
+type page struct {
+	width, height float64
+	content       []byte
+	resources     string
+}
+
+// write lays out the page tree and returns the complete file
+func (d *document) write(pages []page, title string) []byte {
+	catalog := d.reserve()
+	tree := d.reserve()
+
+	kids := make([]string, 0, len(pages))
+	for _, p := range pages {
+		content := d.stream("", p.content, "")
+		n := d.add(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources << %s >> /Contents %d 0 R >>",
+			tree, num(p.width), num(p.height), p.resources, content))
+		kids = append(kids, fmt.Sprintf("%d 0 R", n))
+	}
+	d.set(tree, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	d.set(catalog, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", tree))
+
+	info := d.add(fmt.Sprintf("<< /Title %s /Producer (GoPrint Bridge) /CreationDate (D:%s) >>",
+		literal(encodeWinAnsi(title)), time.Now().UTC().Format("20060102150405Z")))
+
+	var buf bytes.Buffer
+	// The comment with high bytes marks the file as binary
+	buf.WriteString("%PDF-1.4\n%\xE2\xE3\xCF\xD3\n")
+	offsets := make([]int, len(d.objects))
+	for i, obj := range d.objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n", i+1)
+		buf.Write(obj)
+		buf.WriteString("\nendobj\n")
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(d.objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(d.objects)+1, catalog, info, xref)
+	return buf.Bytes()
+}
+
+// literal quotes bytes as a PDF string
+func literal(s []byte) string {
+	var sb strings.Builder
+	sb.WriteByte('(')
+	for _, b := range s {
+		switch b {
+		case '(', ')', '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(b)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\n':
+			sb.WriteString(`\n`)
+		default:
+			sb.WriteByte(b)
+		}
+	}
+	sb.WriteByte(')')
+	return sb.String()
+}
+
+// num formats a coordinate with at most two decimals
+func num(v float64) string {
+	s := fmt.Sprintf("%.2f", v)
+	s = strings.TrimRight(s, "0")
+	s = strings.TrimSuffix(s, ".")
+	if s == "-0" {
+		// Rounded from a tiny negative value
+		return "0"
+	}
+	return s
+}
@@ -0,0 +1,160 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// parsedPDF is a PDF file split into its numbered objects
+type parsedPDF struct {
+	objects map[int]string // Dictionary and stream data of each object
+	root    int
+	info    int
+}
+
+var (
+	objectPattern  = regexp.MustCompile(`(?s)^(\d+) 0 obj\n(.*?)\nendobj\n`)
+	trailerPattern = regexp.MustCompile(`(?s)trailer\n<< /Size (\d+) /Root (\d+) 0 R /Info (\d+) 0 R >>\nstartxref\n(\d+)\n%%EOF\n$`)
+	refPattern     = regexp.MustCompile(`(\d+) 0 R`)
+)
+
+// parsePDF checks the structure of a file written by document.write: the
+// header, an xref entry pointing at every object and the trailer
+func parsePDF(t *testing.T, data []byte) *parsedPDF {
+	t.Helper()
+	if !bytes.HasPrefix(data, []byte("%PDF-1.4\n")) {
+		t.Fatalf("missing PDF header: %q", data[:min(len(data), 16)])
+	}
+
+	m := trailerPattern.FindSubmatch(data)
+	if m == nil {
+		t.Fatalf("missing trailer: %q", data[max(0, len(data)-120):])
+	}
+	size, _ := strconv.Atoi(string(m[1]))
+	p := &parsedPDF{objects: make(map[int]string)}
+	p.root, _ = strconv.Atoi(string(m[2]))
+	p.info, _ = strconv.Atoi(string(m[3]))
+	xref, _ := strconv.Atoi(string(m[4]))
+
+	table := string(data[xref:])
+	header := fmt.Sprintf("xref\n0 %d\n0000000000 65535 f \n", size)
+	if !strings.HasPrefix(table, header) {
+		t.Fatalf("startxref %d doesn't point at an xref table for %d objects", xref, size)
+	}
+	entries := strings.Split(strings.TrimPrefix(table, header), "\n")
+	for n := 1; n < size; n++ {
+		var offset int
+		if _, err := fmt.Sscanf(entries[n-1], "%010d 00000 n ", &offset); err != nil {
+			t.Fatalf("xref entry %d = %q: %v", n, entries[n-1], err)
+		}
+		obj := objectPattern.FindSubmatch(data[offset:])
+		if obj == nil || string(obj[1]) != strconv.Itoa(n) {
+			t.Fatalf("xref entry %d points at %q", n, data[offset:min(len(data), offset+20)])
+		}
+		p.objects[n] = string(obj[2])
+	}
+	return p
+}
+
+// object returns an object by number
+func (p *parsedPDF) object(t *testing.T, n int) string {
+	t.Helper()
+	obj, ok := p.objects[n]
+	if !ok {
+		t.Fatalf("object %d doesn't exist", n)
+	}
+	return obj
+}
+
+// ref returns the object number after key in an object's dictionary
+func (p *parsedPDF) ref(t *testing.T, obj string, key string) int {
+	t.Helper()
+	m := regexp.MustCompile(regexp.QuoteMeta(key) + ` (\d+) 0 R`).FindStringSubmatch(obj)
+	if m == nil {
+		t.Fatalf("%s not found in %q", key, obj)
+	}
+	n, _ := strconv.Atoi(m[1])
+	return n
+}
+
+// pages returns the page objects in order
+func (p *parsedPDF) pages(t *testing.T) []string {
+	t.Helper()
+	catalog := p.object(t, p.root)
+	if !strings.Contains(catalog, "/Type /Catalog") {
+		t.Fatalf("root is not a catalog: %q", catalog)
+	}
+	tree := p.object(t, p.ref(t, catalog, "/Pages"))
+	kids := regexp.MustCompile(`/Kids \[(.*?)\] /Count (\d+)`).FindStringSubmatch(tree)
+	if kids == nil {
+		t.Fatalf("page tree has no kids: %q", tree)
+	}
+
+	var pages []string
+	for _, ref := range refPattern.FindAllStringSubmatch(kids[1], -1) {
+		n, _ := strconv.Atoi(ref[1])
+		page := p.object(t, n)
+		if !strings.Contains(page, "/Type /Page ") {
+			t.Fatalf("kid %d is not a page: %q", n, page)
+		}
+		pages = append(pages, page)
+	}
+	if count, _ := strconv.Atoi(kids[2]); count != len(pages) {
+		t.Fatalf("page tree /Count %d for %d kids", count, len(pages))
+	}
+	return pages
+}
+
+// stream returns the data of a stream object, inflated when deflated
+func (p *parsedPDF) stream(t *testing.T, n int) (string, []byte) {
+	t.Helper()
+	obj := p.object(t, n)
+	i := strings.Index(obj, " >>\nstream\n")
+	if i < 0 || !strings.HasSuffix(obj, "\nendstream") {
+		t.Fatalf("object %d is not a stream: %q", n, obj[:min(len(obj), 80)])
+	}
+	dict := obj[:i+3]
+	data := []byte(obj[i+len(" >>\nstream\n") : len(obj)-len("\nendstream")])
+
+	m := regexp.MustCompile(`/Length (\d+)`).FindStringSubmatch(dict)
+	if m == nil || m[1] != strconv.Itoa(len(data)) {
+		t.Fatalf("object %d %s, stream is %d bytes", n, dict, len(data))
+	}
+	if strings.Contains(dict, "/FlateDecode") {
+		zr, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("object %d: %v", n, err)
+		}
+		if data, err = io.ReadAll(zr); err != nil {
+			t.Fatalf("object %d: %v", n, err)
+		}
+	}
+	return dict, data
+}
+
+// content returns the content stream of a page
+func (p *parsedPDF) content(t *testing.T, page string) string {
+	t.Helper()
+	_, data := p.stream(t, p.ref(t, page, "/Contents"))
+	return string(data)
+}
+
+func TestLiteral(t *testing.T) {
+	if got, want := literal([]byte("a(b)\\c\r\n")), `(a\(b\)\\c\r\n)`; got != want {
+		t.Errorf("literal = %s, want %s", got, want)
+	}
+}
+
+func TestNum(t *testing.T) {
+	for v, want := range map[float64]string{595.2756: "595.28", 10: "10", 0.5: "0.5", -3.999: "-4", -0.001: "0"} {
+		if got := num(v); got != want {
+			t.Errorf("num(%v) = %q, want %q", v, got, want)
+		}
+	}
+}
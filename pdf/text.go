@@ -0,0 +1,294 @@
+package pdf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Text layout defaults
+const (
+	DefaultFontSize = 10 // Points
+	DefaultMargin   = 15 // Millimetres
+	tabWidth        = 8
+)
+
+// TextOptions controls how plain text is laid out on pages. The header
+// and footer are split into left|center|right parts, so
+// "{title}||Page {page} of {pages}" puts the title on the left and the
+// page number on the right. {page}, {pages}, {title} and {date} are
+// replaced on every page.
+type TextOptions struct {
+	PageSize    string  // A4 (default), Letter, ... or a CUPS media name
+	Landscape   bool    // Swap the page width and height
+	Margins     Margins // Millimetres, DefaultMargin on every side when all are zero
+	Font        string  // courier (default) or helvetica
+	FontSize    float64 // Points, defaults to DefaultFontSize
+	LineNumbers bool    // Number the lines in a left gutter
+	Header      string  // Printed above the text
+	Footer      string  // Printed below the text
+	Title       string  // Document title, also {title} in the header and footer
+}
+
+// Text renders plain text as a PDF. Long lines wrap at spaces, tabs
+// expand to every 8th column and a form feed starts a new page.
+func Text(content string, opts TextOptions) ([]byte, error) {
+	font, err := LookupFont(opts.Font)
+	if err != nil {
+		return nil, err
+	}
+	size := opts.FontSize
+	if size == 0 {
+		size = DefaultFontSize
+	}
+	if size < 4 || size > 72 {
+		return nil, fmt.Errorf("font size must be between 4 and 72")
+	}
+
+	width, height, err := PageSize(opts.PageSize, opts.Landscape)
+	if err != nil {
+		return nil, err
+	}
+	margins := opts.Margins
+	if margins == (Margins{}) {
+		margins = Margins{DefaultMargin, DefaultMargin, DefaultMargin, DefaultMargin}
+	}
+	if err := margins.check(width, height); err != nil {
+		return nil, err
+	}
+
+	l := textLayout{font: font, size: size, leading: size * 1.2, margins: margins.points(), width: width, height: height}
+	lines := splitLines(content)
+	pages, err := l.paginate(lines, opts.LineNumbers, opts.Header != "", opts.Footer != "")
+	if err != nil {
+		return nil, err
+	}
+
+	date := time.Now().Format("2006-01-02")
+	out := make([]page, len(pages))
+	for i, p := range pages {
+		vars := strings.NewReplacer(
+			"{page}", strconv.Itoa(i+1),
+			"{pages}", strconv.Itoa(len(pages)),
+			"{title}", opts.Title,
+			"{date}", date,
+		)
+		var sb strings.Builder
+		sb.WriteString("BT\n")
+		fmt.Fprintf(&sb, "/F1 %s Tf\n", num(size))
+		if opts.Header != "" {
+			l.writeBand(&sb, vars.Replace(opts.Header), height-l.margins.Top-size)
+		}
+		for _, line := range p {
+			l.writeText(&sb, line.text, line.x, line.y)
+		}
+		if opts.Footer != "" {
+			l.writeBand(&sb, vars.Replace(opts.Footer), l.margins.Bottom)
+		}
+		sb.WriteString("ET\n")
+
+		out[i] = page{
+			width:     width,
+			height:    height,
+			content:   []byte(sb.String()),
+			resources: fmt.Sprintf("/Font << /F1 %s >>", font.resource()),
+		}
+	}
+
+	var d document
+	return d.write(out, opts.Title), nil
+}
+
+// textLayout places lines of one font and size on pages
+type textLayout struct {
+	font          *Font
+	size, leading float64
+	margins       Margins // Points
+	width, height float64
+}
+
+// placed is a line of encoded text at its baseline position
+type placed struct {
+	text []byte
+	x, y float64
+}
+
+// sourceLine is one input line, or a page break
+type sourceLine struct {
+	text      string
+	pageBreak bool
+}
+
+// splitLines splits text into lines, expanding tabs and turning form
+// feeds into page breaks
+func splitLines(content string) []sourceLine {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	content = strings.TrimSuffix(content, "\n")
+
+	var lines []sourceLine
+	for _, line := range strings.Split(content, "\n") {
+		parts := strings.Split(line, "\f")
+		for i, part := range parts {
+			if i > 0 {
+				lines = append(lines, sourceLine{pageBreak: true})
+			}
+			if part != "" || len(parts) == 1 {
+				lines = append(lines, sourceLine{text: expandTabs(part)})
+			}
+		}
+	}
+	return lines
+}
+
+// expandTabs replaces tabs with spaces up to the next tab stop
+func expandTabs(s string) string {
+	if !strings.Contains(s, "\t") {
+		return s
+	}
+	var sb strings.Builder
+	col := 0
+	for _, r := range s {
+		if r == '\t' {
+			n := tabWidth - col%tabWidth
+			sb.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		}
+		sb.WriteRune(r)
+		col++
+	}
+	return sb.String()
+}
+
+// paginate wraps the lines to the text width and splits them into pages.
+// The header and footer each take two lines of the text area.
+func (l textLayout) paginate(lines []sourceLine, numbers, header, footer bool) ([][]placed, error) {
+	left := l.margins.Left
+	gutter := 0.0
+	digits := 0
+	if numbers {
+		digits = len(strconv.Itoa(len(lines)))
+		gutter = l.font.Width([]byte(strings.Repeat("0", digits+2)), l.size)
+	}
+	textWidth := l.width - l.margins.Left - l.margins.Right - gutter
+
+	top := l.height - l.margins.Top - l.size
+	bottom := l.margins.Bottom
+	if header {
+		top -= 2 * l.leading
+	}
+	if footer {
+		bottom += 2 * l.leading
+	}
+	if top < bottom {
+		return nil, fmt.Errorf("no room for text between the header and footer")
+	}
+
+	var pages [][]placed
+	var current []placed
+	y := top
+	newPage := func() {
+		pages = append(pages, current)
+		current = nil
+		y = top
+	}
+
+	number := 0
+	for _, line := range lines {
+		if line.pageBreak {
+			newPage()
+			continue
+		}
+		number++
+		for j, text := range l.wrap(encodeWinAnsi(line.text), textWidth) {
+			if y < bottom {
+				newPage()
+			}
+			if numbers && j == 0 {
+				n := []byte(fmt.Sprintf("%*d", digits, number))
+				current = append(current, placed{text: n, x: left, y: y})
+			}
+			current = append(current, placed{text: text, x: left + gutter, y: y})
+			y -= l.leading
+		}
+	}
+	// A trailing form feed doesn't add a blank page
+	if len(current) > 0 || len(pages) == 0 {
+		pages = append(pages, current)
+	}
+	return pages, nil
+}
+
+// wrap splits an encoded line to fit in width points, at the last space
+// that fits or else mid-word
+func (l textLayout) wrap(text []byte, width float64) [][]byte {
+	var lines [][]byte
+	for l.font.Width(text, l.size) > width {
+		fit, used := 0, 0.0
+		for fit < len(text) {
+			used += l.font.Width(text[fit:fit+1], l.size)
+			if used > width {
+				break
+			}
+			fit++
+		}
+		if fit == 0 {
+			fit = 1
+		}
+		cut := fit
+		if i := lastSpace(text[:fit+min(1, len(text)-fit)]); i > 0 {
+			cut = i
+		}
+		lines = append(lines, text[:cut])
+		text = trimLeadingSpaces(text[cut:])
+	}
+	return append(lines, text)
+}
+
+// lastSpace returns the index of the last space in s, or -1
+func lastSpace(s []byte) int {
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i] == ' ' {
+			return i
+		}
+	}
+	return -1
+}
+
+// trimLeadingSpaces drops the spaces a wrapped line starts with
+func trimLeadingSpaces(s []byte) []byte {
+	for len(s) > 0 && s[0] == ' ' {
+		s = s[1:]
+	}
+	return s
+}
+
+// writeBand writes a header or footer line split into left|center|right
+func (l textLayout) writeBand(sb *strings.Builder, band string, y float64) {
+	parts := strings.SplitN(band, "|", 3)
+	left, right := l.margins.Left, l.width-l.margins.Right
+	for i, part := range parts {
+		text := encodeWinAnsi(part)
+		if len(text) == 0 {
+			continue
+		}
+		w := l.font.Width(text, l.size)
+		switch i {
+		case 0:
+			l.writeText(sb, text, left, y)
+		case 1:
+			l.writeText(sb, text, (left+right-w)/2, y)
+		case 2:
+			l.writeText(sb, text, right-w, y)
+		}
+	}
+}
+
+// writeText shows text at an absolute position
+func (l textLayout) writeText(sb *strings.Builder, text []byte, x, y float64) {
+	if len(text) == 0 {
+		return
+	}
+	fmt.Fprintf(sb, "1 0 0 1 %s %s Tm %s Tj\n", num(x), num(y), literal(text))
+}
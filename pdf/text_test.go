@@ -0,0 +1,144 @@
+package pdf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestText(t *testing.T) {
+	data, err := Text("Hello (world)\nGyőr costs 5 €\n", TextOptions{Title: "Report"})
+	if err != nil {
+		t.Fatalf("Text failed: %v", err)
+	}
+	p := parsePDF(t, data)
+
+	pages := p.pages(t)
+	if len(pages) != 1 {
+		t.Fatalf("%d pages, want 1", len(pages))
+	}
+	// A4 in points, with Courier
+	if !strings.Contains(pages[0], "/MediaBox [0 0 595.28 841.89]") {
+		t.Errorf("page = %q, want an A4 media box", pages[0])
+	}
+	if !strings.Contains(pages[0], "/BaseFont /Courier /Encoding /WinAnsiEncoding") {
+		t.Errorf("page = %q, want Courier in WinAnsi", pages[0])
+	}
+
+	content := p.content(t, pages[0])
+	// 15 mm margins, the first baseline a font size below the top margin
+	for _, want := range []string{
+		"/F1 10 Tf\n",
+		"1 0 0 1 42.52 789.37 Tm (Hello \\(world\\)) Tj\n",
+		"1 0 0 1 42.52 777.37 Tm (Gyor costs 5 \x80) Tj\n",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("content = %q, want it to contain %q", content, want)
+		}
+	}
+
+	if info := p.object(t, p.info); !strings.Contains(info, "/Title (Report)") {
+		t.Errorf("info = %q, want the title", info)
+	}
+}
+
+func TestTextPagination(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		opts    TextOptions
+		pages   int
+	}{
+		{name: "empty", content: "", pages: 1},
+		// 63 lines fit on an A4 page at 10 pt
+		{name: "full page", content: strings.Repeat("line\n", 63), pages: 1},
+		{name: "overflow", content: strings.Repeat("line\n", 64), pages: 2},
+		{name: "form feed", content: "one\ftwo\n\fthree", pages: 3},
+		{name: "trailing form feed", content: "one\f", pages: 1},
+		// The header and footer take four lines
+		{name: "header and footer", content: strings.Repeat("line\n", 60), opts: TextOptions{Header: "x", Footer: "y"}, pages: 2},
+		{name: "landscape", content: strings.Repeat("line\n", 63), opts: TextOptions{Landscape: true}, pages: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Text(tt.content, tt.opts)
+			if err != nil {
+				t.Fatalf("Text failed: %v", err)
+			}
+			if pages := parsePDF(t, data).pages(t); len(pages) != tt.pages {
+				t.Errorf("%d pages, want %d", len(pages), tt.pages)
+			}
+		})
+	}
+}
+
+func TestTextHeaderFooter(t *testing.T) {
+	data, err := Text("one\ftwo", TextOptions{
+		PageSize: "na_letter_8.5x11in",
+		Header:   "{title}||Page {page} of {pages}",
+		Footer:   "|centered|",
+		Title:    "Invoice",
+	})
+	if err != nil {
+		t.Fatalf("Text failed: %v", err)
+	}
+	p := parsePDF(t, data)
+	pages := p.pages(t)
+	if len(pages) != 2 {
+		t.Fatalf("%d pages, want 2", len(pages))
+	}
+
+	for i, want := range []string{"(Page 1 of 2) Tj", "(Page 2 of 2) Tj"} {
+		content := p.content(t, pages[i])
+		if !strings.Contains(content, want) || !strings.Contains(content, "(Invoice) Tj") {
+			t.Errorf("page %d content = %q, want the title and %q", i+1, content, want)
+		}
+		// Letter is 612 points wide, the footer is centered on it
+		if !strings.Contains(content, "1 0 0 1 282 42.52 Tm (centered) Tj") {
+			t.Errorf("page %d content = %q, want a centered footer", i+1, content)
+		}
+	}
+}
+
+func TestTextWrapAndNumbers(t *testing.T) {
+	// Next to the gutter, 82 Courier characters fit between A4 margins at 10 pt
+	long := strings.Repeat("x", 100) + " " + strings.Repeat("word ", 10)
+	data, err := Text(long+"\n\tindented", TextOptions{LineNumbers: true})
+	if err != nil {
+		t.Fatalf("Text failed: %v", err)
+	}
+	p := parsePDF(t, data)
+	content := p.content(t, p.pages(t)[0])
+
+	for _, want := range []string{
+		// The gutter holds the number and two spaces, 3 characters at 6 points
+		"1 0 0 1 42.52 789.37 Tm (1) Tj\n1 0 0 1 60.52 789.37 Tm (" + strings.Repeat("x", 82) + ") Tj\n",
+		"1 0 0 1 60.52 777.37 Tm (" + strings.Repeat("x", 18) + " word word",
+		"Tm (2) Tj\n1 0 0 1 60.52 765.37 Tm (        indented) Tj\n",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("content = %q, want it to contain %q", content, want)
+		}
+	}
+}
+
+func TestTextErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		opts TextOptions
+		want string
+	}{
+		{name: "font", opts: TextOptions{Font: "times"}, want: "unsupported font"},
+		{name: "font size", opts: TextOptions{FontSize: 100}, want: "between 4 and 72"},
+		{name: "page size", opts: TextOptions{PageSize: "B5"}, want: "unsupported page size"},
+		{name: "negative margin", opts: TextOptions{Margins: Margins{Top: -1}}, want: "must not be negative"},
+		{name: "wide margins", opts: TextOptions{Margins: Margins{Left: 110, Right: 110}}, want: "no room on the page"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Text("x", tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Text error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package printer
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"goprint-bridge/config"
+	"goprint-bridge/logger"
+)
+
+// Document formats accepted by backends
+const (
+	FormatPDF = "pdf"
+	FormatRaw = "raw"
+)
+
+// ErrNotSupported is returned when a backend can't perform an operation
+var ErrNotSupported = errors.New("operation not supported by backend")
+
+// Job is a document submitted to a backend
+type Job struct {
+	ID      string       // Bridge job ID
+	Printer string       // Target printer name
+	Format  string       // FormatPDF or FormatRaw
+	Data    []byte       // Document bytes
+	Options PrintOptions // Copies, duplex, media, etc.
+}
+
+// Capabilities describes what a printer accepts
+type Capabilities struct {
+	Formats []string `json:"formats"` // Document formats the printer accepts
+	Options []string `json:"options"` // PrintOptions fields the backend applies
+}
+
+// JobStatus reports the state of a job inside a backend
+type JobStatus struct {
+	State       string `json:"state"` // pending, processing, completed, cancelled, aborted, unknown
+	Message     string `json:"message,omitempty"`
+	Impressions int    `json:"impressions,omitempty"` // Pages printed so far, if reported
+}
+
+// Backend is a way of reaching printers (CUPS, Windows spooler, network, ...)
+type Backend interface {
+	// Name returns the identifier used in config.yaml
+	Name() string
+	// Discover lists the printers reachable through this backend
+	Discover() ([]Printer, error)
+	// Capabilities reports the formats and options a printer supports
+	Capabilities(printerName string) (Capabilities, error)
+	// Submit sends a job and returns the backend's job ID, if it has one
+	Submit(job Job) (string, error)
+	// Status reports the state of a submitted job
+	Status(printerName string, jobID string) (JobStatus, error)
+	// Cancel cancels a submitted job
+	Cancel(printerName string, jobID string) error
+}
+
+var (
+	registryMu sync.RWMutex
+	backends   = make(map[string]Backend)
+)
+
+// Register makes a backend available by name. Registering the same name
+// twice replaces the previous backend.
+func Register(b Backend) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	backends[b.Name()] = b
+}
+
+// Lookup returns the backend registered under name
+func Lookup(name string) (Backend, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	b, ok := backends[name]
+	return b, ok
+}
+
+// Backends returns all registered backends sorted by name
+func Backends() []Backend {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	list := make([]Backend, 0, len(backends))
+	for _, b := range backends {
+		list = append(list, b)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name() < list[j].Name()
+	})
+	return list
+}
+
+// Resolve returns the backend that drives the named printer. Printers not
+// declared in config.yaml use the system backend.
+func Resolve(printerName string) (Backend, error) {
+	backendName := SystemBackend
+	if pc := config.GetConfig().FindPrinter(printerName); pc != nil && pc.Backend != "" {
+		backendName = pc.Backend
+	}
+
+	b, ok := Lookup(backendName)
+	if !ok {
+		return nil, fmt.Errorf("unknown backend %q for printer %q", backendName, printerName)
+	}
+	return b, nil
+}
+
+// List returns the printers discovered by every backend. A failing backend
+// is skipped unless all of them fail.
+func List() ([]Printer, error) {
+	var printers []Printer
+	var lastErr error
+	succeeded := 0
+
+	for _, b := range Backends() {
+		found, err := b.Discover()
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to discover %s printers", b.Name()), err)
+			lastErr = err
+			continue
+		}
+		succeeded++
+		printers = append(printers, found...)
+	}
+
+	if succeeded == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return printers, nil
+}
+
+// Find returns the discovered printer with the given name
+func Find(name string) (Printer, bool, error) {
+	printers, err := List()
+	if err != nil {
+		return Printer{}, false, err
+	}
+	for _, p := range printers {
+		if p.Name == name {
+			return p, true, nil
+		}
+	}
+	return Printer{}, false, nil
+}
+
+// Exists reports whether a printer with the given name is known. Printers
+// declared in config.yaml only need a registered backend; other names are
+// looked up among the system printers, without probing any other backend.
+func Exists(name string) (bool, error) {
+	if pc := config.GetConfig().FindPrinter(name); pc != nil && pc.Backend != "" && pc.Backend != SystemBackend {
+		_, ok := Lookup(pc.Backend)
+		return ok, nil
+	}
+
+	b, ok := Lookup(SystemBackend)
+	if !ok {
+		return false, nil
+	}
+	printers, err := b.Discover()
+	if err != nil {
+		return false, err
+	}
+	for _, p := range printers {
+		if p.Name == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// PrintTestPage prints a simple test page
+func PrintTestPage(printerName string) error {
+	testContent := `
+================================
+       GOPRINT TEST PAGE
+================================
+
+Printer: %s
+Time: %s
+
+Hello World!
+
+This is a test page from 
+GoPrintBridge v1.0.0
+
+If you can read this, your
+printer is working correctly.
+
+================================
+`
+	content := fmt.Sprintf(testContent, printerName, time.Now().Format("2006-01-02 15:04:05"))
+
+	b, err := Resolve(printerName)
+	if err != nil {
+		return err
+	}
+
+	logger.Info(fmt.Sprintf("Printing test page to: %s", printerName))
+	_, err = b.Submit(Job{
+		Printer: printerName,
+		Format:  FormatRaw,
+		Data:    []byte(content),
+		Options: PrintOptions{Title: "GoPrintBridge Test Page", Copies: 1},
+	})
+	return err
+}
@@ -0,0 +1,240 @@
+package printer
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"goprint-bridge/config"
+	"goprint-bridge/logger"
+)
+
+// statusTimeout bounds each ESC/POS status read-back
+const statusTimeout = 500 * time.Millisecond
+
+// ESC/POS real-time status requests (DLE EOT n)
+var (
+	statusPrinter = []byte{0x10, 0x04, 0x01}
+	statusOffline = []byte{0x10, 0x04, 0x02}
+	statusPaper   = []byte{0x10, 0x04, 0x04}
+)
+
+// deviceBackend writes jobs straight to a USB or serial device file,
+// bypassing the spooler
+type deviceBackend struct{}
+
+var (
+	deviceLocksMu sync.Mutex
+	deviceLocks   = make(map[string]*sync.Mutex)
+
+	// lastStatus holds the status each device reported to the last job,
+	// so listing printers never has to open a device
+	lastStatusMu sync.Mutex
+	lastStatus   = make(map[string]string)
+)
+
+func init() {
+	Register(deviceBackend{})
+}
+
+// Name returns the backend identifier
+func (deviceBackend) Name() string {
+	return "device"
+}
+
+// Discover lists the configured device printers
+func (deviceBackend) Discover() ([]Printer, error) {
+	var printers []Printer
+	for _, pc := range config.GetConfig().PrintersWithBackend("device") {
+		printers = append(printers, Printer{
+			Name:    pc.Name,
+			Status:  deviceStatus(pc),
+			Backend: "device",
+		})
+	}
+	return printers, nil
+}
+
+// Capabilities reports that only raw data is accepted
+func (deviceBackend) Capabilities(printerName string) (Capabilities, error) {
+	return Capabilities{
+		Formats: []string{FormatRaw},
+		Options: []string{"copies"},
+	}, nil
+}
+
+// Submit writes the job to the device while holding an exclusive lock
+func (deviceBackend) Submit(job Job) (string, error) {
+	if job.Format != FormatRaw {
+		return "", fmt.Errorf("device backend only accepts raw data, got %q", job.Format)
+	}
+
+	pc := config.GetConfig().FindPrinter(job.Printer)
+	if pc == nil || pc.Device == "" {
+		return "", fmt.Errorf("printer %q has no device configured", job.Printer)
+	}
+
+	f, release, err := openDevice(*pc)
+	if err != nil {
+		logger.PrintError("Failed to open printer device", err)
+		return "", err
+	}
+	defer release()
+
+	if pc.ReadBack {
+		if status, err := readStatus(f); err == nil {
+			setLastStatus(pc.Device, status)
+			if status != "Ready" {
+				return "", fmt.Errorf("printer is not ready: %s", status)
+			}
+		}
+	}
+
+	for i := 0; i < job.Options.copies(); i++ {
+		if _, err := f.Write(job.Data); err != nil {
+			logger.PrintError("Failed to write to printer device", err)
+			return "", fmt.Errorf("failed to write to %s: %w", pc.Device, err)
+		}
+	}
+
+	logger.PrintSuccess(job.Printer)
+	return "", nil
+}
+
+// Status reports the job as completed, writes are synchronous
+func (deviceBackend) Status(printerName string, jobID string) (JobStatus, error) {
+	return JobStatus{State: "completed"}, nil
+}
+
+// Cancel is not possible once bytes have been written
+func (deviceBackend) Cancel(printerName string, jobID string) error {
+	return ErrNotSupported
+}
+
+// openDevice opens and locks the device, applying serial settings.
+// The returned function unlocks and closes it.
+func openDevice(pc config.PrinterConfig) (*os.File, func(), error) {
+	// Serialize jobs inside this process, the file lock covers other processes
+	mu := deviceLock(pc.Device)
+	mu.Lock()
+
+	flags := os.O_WRONLY
+	if pc.ReadBack {
+		flags = os.O_RDWR
+	}
+	f, err := os.OpenFile(pc.Device, flags, 0)
+	if err != nil {
+		mu.Unlock()
+		return nil, nil, fmt.Errorf("failed to open %s: %w", pc.Device, err)
+	}
+
+	if err := lockDevice(f); err != nil {
+		f.Close()
+		mu.Unlock()
+		return nil, nil, fmt.Errorf("device %s is busy: %w", pc.Device, err)
+	}
+
+	release := func() {
+		unlockDevice(f)
+		f.Close()
+		mu.Unlock()
+	}
+
+	if pc.BaudRate > 0 || pc.Parity != "" || pc.DataBits > 0 || pc.StopBits > 0 {
+		if err := configureSerial(f, pc); err != nil {
+			release()
+			return nil, nil, fmt.Errorf("failed to configure %s: %w", pc.Device, err)
+		}
+	}
+
+	return f, release, nil
+}
+
+// deviceLock returns the in-process lock for a device path
+func deviceLock(path string) *sync.Mutex {
+	deviceLocksMu.Lock()
+	defer deviceLocksMu.Unlock()
+
+	mu, ok := deviceLocks[path]
+	if !ok {
+		mu = &sync.Mutex{}
+		deviceLocks[path] = mu
+	}
+	return mu
+}
+
+// deviceStatus reports whether the device exists and, with read-back
+// enabled, what the printer said about itself before the last job. The
+// device isn't opened, which would wait for a running job and send status
+// requests in between jobs.
+func deviceStatus(pc config.PrinterConfig) string {
+	if _, err := os.Stat(pc.Device); err != nil {
+		return "Offline"
+	}
+	if !pc.ReadBack {
+		return "Ready"
+	}
+
+	lastStatusMu.Lock()
+	defer lastStatusMu.Unlock()
+	if status, ok := lastStatus[pc.Device]; ok {
+		return status
+	}
+	return "Ready"
+}
+
+// setLastStatus records the status a device reported
+func setLastStatus(device string, status string) {
+	lastStatusMu.Lock()
+	defer lastStatusMu.Unlock()
+	lastStatus[device] = status
+}
+
+// readStatus asks an ESC/POS printer for its real-time status
+func readStatus(f *os.File) (string, error) {
+	b, err := queryStatus(f, statusPrinter)
+	if err != nil {
+		return "", err
+	}
+	if b&0x08 == 0 {
+		// Online, check the paper sensor for a low roll
+		if paper, err := queryStatus(f, statusPaper); err == nil && paper&0x0C != 0 {
+			return "Paper low", nil
+		}
+		return "Ready", nil
+	}
+
+	cause, err := queryStatus(f, statusOffline)
+	if err != nil {
+		return "Offline", nil
+	}
+	switch {
+	case cause&0x04 != 0:
+		return "Cover open", nil
+	case cause&0x20 != 0:
+		return "Paper out", nil
+	case cause&0x40 != 0:
+		return "Error", nil
+	default:
+		return "Offline", nil
+	}
+}
+
+// queryStatus sends a status request and reads the single reply byte
+func queryStatus(f *os.File, request []byte) (byte, error) {
+	if err := f.SetReadDeadline(time.Now().Add(statusTimeout)); err != nil {
+		// Device can't time out reads, don't risk blocking the job
+		return 0, err
+	}
+	defer f.SetReadDeadline(time.Time{})
+
+	if _, err := f.Write(request); err != nil {
+		return 0, err
+	}
+	buf := make([]byte, 1)
+	if _, err := f.Read(buf); err != nil {
+		return 0, err
+	}
+	return buf[0], nil
+}
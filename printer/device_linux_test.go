@@ -0,0 +1,81 @@
+//go:build linux
+// +build linux
+
+package printer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"goprint-bridge/config"
+)
+
+// openSilentPrinter opens a pseudo-terminal and returns the path of its
+// printer end. Nothing is ever written to the other end, so status requests
+// are never answered.
+func openSilentPrinter(t *testing.T) string {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		t.Skipf("no pseudo-terminals: %v", err)
+	}
+	t.Cleanup(func() { master.Close() })
+
+	var n int
+	err = control(master, func(fd int) error {
+		if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+			return err
+		}
+		n, err = unix.IoctlGetInt(fd, unix.TIOCGPTN)
+		return err
+	})
+	if err != nil {
+		t.Skipf("failed to unlock pseudo-terminal: %v", err)
+	}
+	return fmt.Sprintf("/dev/pts/%d", n)
+}
+
+func TestReadStatusTimeout(t *testing.T) {
+	tests := []struct {
+		name string
+		pc   config.PrinterConfig
+	}{
+		{name: "plain device", pc: config.PrinterConfig{ReadBack: true}},
+		{name: "serial settings", pc: config.PrinterConfig{ReadBack: true, BaudRate: 9600, Parity: "none"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.pc.Device = openSilentPrinter(t)
+			f, release, err := openDevice(tt.pc)
+			if err != nil {
+				t.Fatalf("openDevice failed: %v", err)
+			}
+			defer release()
+
+			done := make(chan error, 1)
+			start := time.Now()
+			go func() {
+				_, err := readStatus(f)
+				done <- err
+			}()
+
+			select {
+			case err := <-done:
+				if !errors.Is(err, os.ErrDeadlineExceeded) {
+					t.Fatalf("readStatus error = %v, want a deadline error", err)
+				}
+				if elapsed := time.Since(start); elapsed < statusTimeout {
+					t.Errorf("readStatus returned after %v, before the %v timeout", elapsed, statusTimeout)
+				}
+			case <-time.After(10 * statusTimeout):
+				// Unblock the read so the test can finish
+				f.Close()
+				t.Fatalf("readStatus still blocked after %v", 10*statusTimeout)
+			}
+		})
+	}
+}
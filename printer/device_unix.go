@@ -0,0 +1,58 @@
+//go:build !windows
+// +build !windows
+
+package printer
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// deviceLockTimeout is how long a job waits for another process to release the device
+const deviceLockTimeout = 30 * time.Second
+
+// lockDevice takes an exclusive advisory lock on the device file
+func lockDevice(f *os.File) error {
+	deadline := time.Now().Add(deviceLockTimeout)
+	for {
+		err := control(f, func(fd int) error {
+			return unix.Flock(fd, unix.LOCK_EX|unix.LOCK_NB)
+		})
+		if err == nil {
+			return nil
+		}
+		if err != unix.EWOULDBLOCK {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for lock")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// unlockDevice releases the lock taken by lockDevice
+func unlockDevice(f *os.File) {
+	control(f, func(fd int) error {
+		return unix.Flock(fd, unix.LOCK_UN)
+	})
+}
+
+// control runs fn on the file's descriptor. Unlike f.Fd() it leaves the
+// descriptor non-blocking, so read deadlines keep working.
+func control(f *os.File, fn func(fd int) error) error {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var fnErr error
+	if err := rc.Control(func(fd uintptr) {
+		fnErr = fn(int(fd))
+	}); err != nil {
+		return err
+	}
+	return fnErr
+}
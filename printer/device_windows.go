@@ -0,0 +1,14 @@
+//go:build windows
+// +build windows
+
+package printer
+
+import "os"
+
+// lockDevice is a no-op, Windows opens COM and USB ports exclusively
+func lockDevice(f *os.File) error {
+	return nil
+}
+
+// unlockDevice is a no-op on Windows
+func unlockDevice(f *os.File) {}
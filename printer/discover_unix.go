@@ -0,0 +1,70 @@
+//go:build !windows
+// +build !windows
+
+package printer
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// listSystemPrinters gets printers using lpstat (macOS/Linux)
+func listSystemPrinters() ([]Printer, error) {
+	// Use -l for long output to get alerts/status
+	cmd := exec.Command("lpstat", "-l", "-p")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run lpstat: %w", err)
+	}
+
+	lines := strings.Split(string(output), "\n")
+	var printers []Printer
+	var currentPrinter *Printer
+
+	// Regex to match the start of a printer block: "printer <name> is <status>."
+	reStart := regexp.MustCompile(`^printer\s+(\S+)\s+is\s+(\w+)`)
+	// Regex to match alert line: "Alerts: <alert>"
+	reAlert := regexp.MustCompile(`^\s+Alerts:\s+(.*)`)
+
+	for _, line := range lines {
+		// Check for start of new printer block
+		if matches := reStart.FindStringSubmatch(line); len(matches) >= 3 {
+			// If we were processing a printer, save it
+			if currentPrinter != nil {
+				printers = append(printers, *currentPrinter)
+			}
+			status := matches[2]
+			if status == "idle" {
+				status = "Ready"
+			} else {
+				// Capitalize first letter
+				if len(status) > 0 {
+					status = strings.ToUpper(status[:1]) + status[1:]
+				}
+			}
+
+			// Start new printer
+			currentPrinter = &Printer{
+				Name:   matches[1],
+				Status: status,
+			}
+		} else if currentPrinter != nil {
+			// Check for alerts in the current block
+			if matches := reAlert.FindStringSubmatch(line); len(matches) >= 2 {
+				alert := matches[1]
+				if strings.Contains(alert, "offline") {
+					currentPrinter.Status = "Offline"
+				}
+			}
+		}
+	}
+
+	// Append the last printer
+	if currentPrinter != nil {
+		printers = append(printers, *currentPrinter)
+	}
+
+	return printers, nil
+}
@@ -0,0 +1,75 @@
+//go:build windows
+// +build windows
+
+package printer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"syscall"
+)
+
+// windowsPrinter matches PowerShell JSON output
+type windowsPrinter struct {
+	Name          string      `json:"Name"`
+	PrinterStatus interface{} `json:"PrinterStatus"` // Can be string or int
+}
+
+// listSystemPrinters gets printers using PowerShell
+func listSystemPrinters() ([]Printer, error) {
+	// Wrap in @() so a single printer is still returned as a JSON array
+	cmd := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", "@(Get-Printer | Select-Object Name, PrinterStatus) | ConvertTo-Json")
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		HideWindow:    true,
+		CreationFlags: 0x08000000, // CREATE_NO_WINDOW
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run Get-Printer: %w", err)
+	}
+
+	var winPrinters []windowsPrinter
+	if err := json.Unmarshal(output, &winPrinters); err != nil {
+		return nil, fmt.Errorf("failed to parse printers JSON: %w", err)
+	}
+
+	var result []Printer
+	for _, p := range winPrinters {
+		status := "Unknown"
+		switch v := p.PrinterStatus.(type) {
+		case string:
+			status = v
+		case float64:
+			// Map standard Windows printer status codes
+			// Ref: https://learn.microsoft.com/en-us/windows/win32/cimwin32prov/win32-printer
+			code := int(v)
+			switch code {
+			case 1:
+				status = "Other"
+			case 2:
+				status = "Error"
+			case 3:
+				status = "Ready" // Idle
+			case 4:
+				status = "Printing"
+			case 5:
+				status = "Warmup"
+			case 6:
+				status = "Stopped"
+			case 7:
+				status = "Offline"
+			default:
+				status = fmt.Sprintf("Status %d", code)
+			}
+		default:
+			status = fmt.Sprintf("%v", v)
+		}
+
+		result = append(result, Printer{
+			Name:   p.Name,
+			Status: status,
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,143 @@
+package printer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"goprint-bridge/config"
+	"goprint-bridge/logger"
+)
+
+// defaultSpoolDir is used when a file sink printer has no spool_dir
+const defaultSpoolDir = "storage/spool"
+
+// fileSinkBackend is a virtual printer that writes every job to a spool
+// directory, for development and CI machines without real printers
+type fileSinkBackend struct{}
+
+// fileSinkTicket is the JSON sidecar written next to each payload
+type fileSinkTicket struct {
+	JobID     string               `json:"job_id"`
+	Printer   config.PrinterConfig `json:"printer"`
+	Format    string               `json:"format"`
+	Size      int                  `json:"size"`
+	Payload   string               `json:"payload"`
+	Options   PrintOptions         `json:"options"`
+	CreatedAt time.Time            `json:"created_at"`
+}
+
+func init() {
+	Register(fileSinkBackend{})
+}
+
+// Name returns the backend identifier
+func (fileSinkBackend) Name() string {
+	return "file"
+}
+
+// Discover lists the configured file sink printers
+func (fileSinkBackend) Discover() ([]Printer, error) {
+	var printers []Printer
+	for _, pc := range config.GetConfig().PrintersWithBackend("file") {
+		status := "Ready"
+		if err := os.MkdirAll(spoolDir(pc), 0755); err != nil {
+			status = "Offline"
+		}
+		printers = append(printers, Printer{
+			Name:    pc.Name,
+			Status:  status,
+			Backend: "file",
+		})
+	}
+	return printers, nil
+}
+
+// Capabilities reports that every format and option is accepted and recorded
+func (fileSinkBackend) Capabilities(printerName string) (Capabilities, error) {
+	return Capabilities{
+		Formats: []string{FormatPDF, FormatRaw},
+		Options: []string{"copies", "collate", "sides", "page_ranges", "media", "orientation", "fit_to_page", "color_mode"},
+	}, nil
+}
+
+// Submit writes the payload and its sidecar to the spool directory
+func (fileSinkBackend) Submit(job Job) (string, error) {
+	pc := config.GetConfig().FindPrinter(job.Printer)
+	if pc == nil {
+		return "", fmt.Errorf("printer %q is not configured", job.Printer)
+	}
+
+	dir := spoolDir(*pc)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	now := time.Now()
+	id := now.Format("20060102-150405.000000")
+	if job.ID != "" {
+		id += "_" + job.ID
+	}
+
+	ext := ".bin"
+	if job.Format == FormatPDF {
+		ext = ".pdf"
+	}
+	payload := id + ext
+
+	if err := os.WriteFile(filepath.Join(dir, payload), job.Data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write spool file: %w", err)
+	}
+
+	ticket, err := json.MarshalIndent(fileSinkTicket{
+		JobID:     job.ID,
+		Printer:   *pc,
+		Format:    job.Format,
+		Size:      len(job.Data),
+		Payload:   payload,
+		Options:   job.Options,
+		CreatedAt: now,
+	}, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode spool ticket: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, id+".json"), ticket, 0644); err != nil {
+		return "", fmt.Errorf("failed to write spool ticket: %w", err)
+	}
+
+	logger.Info(fmt.Sprintf("Spooled job to %s", filepath.Join(dir, payload)))
+	logger.PrintSuccess(job.Printer)
+	return id, nil
+}
+
+// Status reports a job as completed once its sidecar exists
+func (fileSinkBackend) Status(printerName string, jobID string) (JobStatus, error) {
+	pc := config.GetConfig().FindPrinter(printerName)
+	if pc == nil {
+		return JobStatus{}, fmt.Errorf("printer %q is not configured", printerName)
+	}
+	if strings.ContainsAny(jobID, `/\`) {
+		return JobStatus{}, fmt.Errorf("invalid job ID %q", jobID)
+	}
+
+	if _, err := os.Stat(filepath.Join(spoolDir(*pc), jobID+".json")); err != nil {
+		return JobStatus{State: "unknown"}, nil
+	}
+	return JobStatus{State: "completed"}, nil
+}
+
+// Cancel is not possible, jobs are written synchronously
+func (fileSinkBackend) Cancel(printerName string, jobID string) error {
+	return ErrNotSupported
+}
+
+// spoolDir returns the directory a file sink printer writes to
+func spoolDir(pc config.PrinterConfig) string {
+	if pc.SpoolDir != "" {
+		return pc.SpoolDir
+	}
+	return filepath.Join(defaultSpoolDir, pc.Name)
+}
@@ -0,0 +1,256 @@
+package printer
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"goprint-bridge/config"
+	"goprint-bridge/ipp"
+	"goprint-bridge/logger"
+)
+
+// ippTimeout bounds each IPP request; documents are sent in the request body
+const ippTimeout = 60 * time.Second
+
+// ippClient is shared by all IPP printers
+var ippClient = ipp.NewClient(ippTimeout)
+
+// ippBackend talks IPP/1.1 to CUPS or IPP Everywhere printers directly
+type ippBackend struct{}
+
+func init() {
+	Register(ippBackend{})
+}
+
+// Name returns the backend identifier
+func (ippBackend) Name() string {
+	return "ipp"
+}
+
+// Discover lists the configured IPP printers with their printer-state
+func (ippBackend) Discover() ([]Printer, error) {
+	configured := config.GetConfig().PrintersWithBackend("ipp")
+	printers := make([]Printer, len(configured))
+
+	var wg sync.WaitGroup
+	for i, pc := range configured {
+		wg.Add(1)
+		go func(i int, pc config.PrinterConfig) {
+			defer wg.Done()
+			printers[i] = Printer{
+				Name:    pc.Name,
+				Status:  ippPrinterStatus(ippURI(pc)),
+				Backend: "ipp",
+			}
+		}(i, pc)
+	}
+	wg.Wait()
+
+	return printers, nil
+}
+
+// Capabilities reads the supported document formats from the printer
+func (ippBackend) Capabilities(printerName string) (Capabilities, error) {
+	pc, err := ippPrinter(printerName)
+	if err != nil {
+		return Capabilities{}, err
+	}
+
+	resp, err := ippClient.GetPrinterAttributes(ippURI(pc), "document-format-supported")
+	if err != nil {
+		return Capabilities{}, err
+	}
+
+	caps := Capabilities{
+		Formats: []string{FormatRaw},
+		Options: []string{"copies", "collate", "sides", "page_ranges", "media", "orientation", "fit_to_page", "color_mode"},
+	}
+	if a, ok := resp.Find("document-format-supported"); ok {
+		for _, format := range a.Strings() {
+			if format == "application/pdf" {
+				caps.Formats = append(caps.Formats, FormatPDF)
+				break
+			}
+		}
+	}
+	return caps, nil
+}
+
+// Submit sends the document with Print-Job and returns the IPP job ID
+func (ippBackend) Submit(job Job) (string, error) {
+	pc, err := ippPrinter(job.Printer)
+	if err != nil {
+		return "", err
+	}
+	uri := ippURI(pc)
+
+	var format string
+	switch job.Format {
+	case FormatPDF:
+		format = "application/pdf"
+	case FormatRaw:
+		format = rawMimeType(uri)
+	default:
+		return "", fmt.Errorf("unsupported format %q", job.Format)
+	}
+
+	jobID, err := ippClient.PrintJob(uri, job.Data, format, job.Options.Title, ippJobAttributes(job.Options))
+	if err != nil {
+		logger.PrintError("Failed to send IPP print job", err)
+		return "", err
+	}
+
+	logger.PrintSuccess(job.Printer)
+	return strconv.Itoa(jobID), nil
+}
+
+// Status polls Get-Job-Attributes
+func (ippBackend) Status(printerName string, jobID string) (JobStatus, error) {
+	pc, err := ippPrinter(printerName)
+	if err != nil {
+		return JobStatus{}, err
+	}
+	id, err := strconv.Atoi(jobID)
+	if err != nil {
+		return JobStatus{}, fmt.Errorf("invalid job ID %q", jobID)
+	}
+
+	info, err := ippClient.GetJobAttributes(ippURI(pc), id)
+	if err != nil {
+		return JobStatus{}, err
+	}
+
+	status := JobStatus{
+		Impressions: info.ImpressionsCompleted,
+		Message:     strings.Join(info.StateReasons, ", "),
+	}
+	switch info.State {
+	case ipp.JobStatePending, ipp.JobStatePendingHeld:
+		status.State = "pending"
+	case ipp.JobStateProcessing, ipp.JobStateProcessingStopped:
+		status.State = "processing"
+	case ipp.JobStateCanceled:
+		status.State = "cancelled"
+	case ipp.JobStateAborted:
+		status.State = "aborted"
+	case ipp.JobStateCompleted:
+		status.State = "completed"
+	default:
+		status.State = "unknown"
+	}
+	return status, nil
+}
+
+// Cancel sends Cancel-Job
+func (ippBackend) Cancel(printerName string, jobID string) error {
+	pc, err := ippPrinter(printerName)
+	if err != nil {
+		return err
+	}
+	id, err := strconv.Atoi(jobID)
+	if err != nil {
+		return fmt.Errorf("invalid job ID %q", jobID)
+	}
+	return ippClient.CancelJob(ippURI(pc), id)
+}
+
+// ippPrinter returns the config of an IPP printer
+func ippPrinter(name string) (config.PrinterConfig, error) {
+	pc := config.GetConfig().FindPrinter(name)
+	if pc == nil {
+		return config.PrinterConfig{}, fmt.Errorf("printer %q is not configured", name)
+	}
+	return *pc, nil
+}
+
+// ippURI returns the printer URI, defaulting to the local CUPS queue of
+// the same name
+func ippURI(pc config.PrinterConfig) string {
+	if pc.URI != "" {
+		return pc.URI
+	}
+	return "ipp://localhost:631/printers/" + url.PathEscape(pc.Name)
+}
+
+// ippPrinterStatus maps printer-state to a display status
+func ippPrinterStatus(uri string) string {
+	resp, err := ippClient.GetPrinterAttributes(uri, "printer-state")
+	if err != nil {
+		return "Offline"
+	}
+	a, ok := resp.Find("printer-state")
+	if !ok {
+		return "Unknown"
+	}
+	switch a.Int() {
+	case ipp.PrinterStateIdle:
+		return "Ready"
+	case ipp.PrinterStateProcessing:
+		return "Printing"
+	case ipp.PrinterStateStopped:
+		return "Stopped"
+	default:
+		return "Unknown"
+	}
+}
+
+// rawMimeType picks the format that makes the server pass bytes through
+// untouched. CUPS queues live under /printers/ or /classes/.
+func rawMimeType(uri string) string {
+	if u, err := url.Parse(uri); err == nil {
+		if strings.HasPrefix(u.Path, "/printers/") || strings.HasPrefix(u.Path, "/classes/") {
+			return "application/vnd.cups-raw"
+		}
+	}
+	return "application/octet-stream"
+}
+
+// ippJobAttributes maps print options to IPP job template attributes
+func ippJobAttributes(opts PrintOptions) []ipp.Attribute {
+	var attrs []ipp.Attribute
+	if copies := opts.copies(); copies > 1 {
+		attrs = append(attrs, ipp.Integer("copies", ipp.TagInteger, copies))
+	}
+	if opts.Collate {
+		attrs = append(attrs, ipp.String("multiple-document-handling", ipp.TagKeyword, "separate-documents-collated-copies"))
+	}
+	if opts.Sides != "" {
+		attrs = append(attrs, ipp.String("sides", ipp.TagKeyword, opts.Sides))
+	}
+	if opts.PageRanges != "" {
+		attrs = append(attrs, ipp.RangeOfInteger("page-ranges", parsePageRanges(opts.PageRanges)...))
+	}
+	if opts.Media != "" {
+		attrs = append(attrs, ipp.String("media", ipp.TagKeyword, opts.Media))
+	}
+	if code, ok := validOrientations[opts.Orientation]; ok {
+		value, _ := strconv.Atoi(code)
+		attrs = append(attrs, ipp.Integer("orientation-requested", ipp.TagEnum, value))
+	}
+	if opts.FitToPage {
+		attrs = append(attrs, ipp.String("print-scaling", ipp.TagKeyword, "fit"))
+	}
+	if opts.ColorMode != "" {
+		attrs = append(attrs, ipp.String("print-color-mode", ipp.TagKeyword, opts.ColorMode))
+	}
+	return attrs
+}
+
+// parsePageRanges converts validated page ranges such as "1-3,5"
+func parsePageRanges(ranges string) []ipp.Range {
+	var result []ipp.Range
+	for _, part := range strings.Split(ranges, ",") {
+		bounds := strings.SplitN(part, "-", 2)
+		first, _ := strconv.Atoi(bounds[0])
+		last := first
+		if len(bounds) == 2 {
+			last, _ = strconv.Atoi(bounds[1])
+		}
+		result = append(result, ipp.Range{Lower: first, Upper: last})
+	}
+	return result
+}
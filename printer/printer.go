@@ -0,0 +1,146 @@
+package printer
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxCopies is the highest number of copies accepted for a single job
+const maxCopies = 99
+
+// Printer represents a printer with its status
+type Printer struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Backend string `json:"backend"`
+}
+
+// PrintOptions holds per-job settings passed along with the document.
+// Only these typed fields reach the print system, so callers can't inject
+// arbitrary driver options.
+type PrintOptions struct {
+	Title       string `json:"title,omitempty"`
+	Copies      int    `json:"copies,omitempty"`
+	Collate     bool   `json:"collate,omitempty"`
+	Sides       string `json:"sides,omitempty"`       // one-sided, two-sided-long-edge, two-sided-short-edge
+	PageRanges  string `json:"page_ranges,omitempty"` // e.g. "1-3,5"
+	Media       string `json:"media,omitempty"`       // e.g. A4, Letter, na_letter_8.5x11in
+	Orientation string `json:"orientation,omitempty"` // portrait, landscape, reverse-portrait, reverse-landscape
+	FitToPage   bool   `json:"fit_to_page,omitempty"`
+	ColorMode   string `json:"color_mode,omitempty"` // color, monochrome
+	Dither      string `json:"dither,omitempty"`     // threshold, floyd-steinberg, atkinson (image jobs)
+
+	// Image jobs on document printers
+	Scale    string   `json:"scale,omitempty"`     // fit, fill, actual
+	NumberUp int      `json:"number_up,omitempty"` // Images per page: 1, 2, 4, 6, 9, 16
+	Margin   *float64 `json:"margin,omitempty"`    // Millimetres on every side
+}
+
+// Accepted values for the enumerated options
+var (
+	validSides = map[string]bool{
+		"one-sided":            true,
+		"two-sided-long-edge":  true,
+		"two-sided-short-edge": true,
+	}
+	validOrientations = map[string]string{
+		"portrait":          "3",
+		"landscape":         "4",
+		"reverse-landscape": "5",
+		"reverse-portrait":  "6",
+	}
+	validColorModes = map[string]bool{
+		"color":      true,
+		"monochrome": true,
+	}
+	validDithers = map[string]bool{
+		"threshold":       true,
+		"floyd-steinberg": true,
+		"atkinson":        true,
+	}
+	validScales = map[string]bool{
+		"fit":    true,
+		"fill":   true,
+		"actual": true,
+	}
+	validNumberUp = map[int]bool{1: true, 2: true, 4: true, 6: true, 9: true, 16: true}
+)
+
+var (
+	// mediaPattern matches media names such as A4 or na_letter_8.5x11in
+	mediaPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+	// pageRangesPattern matches a comma separated list of pages and ranges
+	pageRangesPattern = regexp.MustCompile(`^\d+(-\d+)?(,\d+(-\d+)?)*$`)
+)
+
+// Validate checks the options and fills in defaults
+func (o *PrintOptions) Validate() error {
+	if o.Copies == 0 {
+		o.Copies = 1
+	}
+	if o.Copies < 1 || o.Copies > maxCopies {
+		return fmt.Errorf("copies must be between 1 and %d", maxCopies)
+	}
+	if o.Sides != "" && !validSides[o.Sides] {
+		return fmt.Errorf("invalid sides %q", o.Sides)
+	}
+	if o.Orientation != "" {
+		if _, ok := validOrientations[o.Orientation]; !ok {
+			return fmt.Errorf("invalid orientation %q", o.Orientation)
+		}
+	}
+	if o.ColorMode != "" && !validColorModes[o.ColorMode] {
+		return fmt.Errorf("invalid color mode %q", o.ColorMode)
+	}
+	if o.Dither != "" && !validDithers[o.Dither] {
+		return fmt.Errorf("invalid dither %q", o.Dither)
+	}
+	if o.Scale != "" && !validScales[o.Scale] {
+		return fmt.Errorf("invalid scale %q", o.Scale)
+	}
+	if o.NumberUp != 0 && !validNumberUp[o.NumberUp] {
+		return fmt.Errorf("number_up must be 1, 2, 4, 6, 9 or 16")
+	}
+	if o.Margin != nil && (*o.Margin < 0 || *o.Margin > 50) {
+		return fmt.Errorf("margin must be between 0 and 50")
+	}
+	if o.Media != "" && !mediaPattern.MatchString(o.Media) {
+		return fmt.Errorf("invalid media %q", o.Media)
+	}
+	if o.PageRanges != "" {
+		if err := validatePageRanges(o.PageRanges); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copies returns the number of copies to print
+func (o PrintOptions) copies() int {
+	if o.Copies < 1 {
+		return 1
+	}
+	return o.Copies
+}
+
+// validatePageRanges checks that every range is ascending and starts at 1 or later
+func validatePageRanges(ranges string) error {
+	if !pageRangesPattern.MatchString(ranges) {
+		return fmt.Errorf("invalid page ranges %q", ranges)
+	}
+
+	for _, part := range strings.Split(ranges, ",") {
+		bounds := strings.SplitN(part, "-", 2)
+		first, _ := strconv.Atoi(bounds[0])
+		last := first
+		if len(bounds) == 2 {
+			last, _ = strconv.Atoi(bounds[1])
+		}
+		if first < 1 || last < first {
+			return fmt.Errorf("invalid page range %q", part)
+		}
+	}
+	return nil
+}
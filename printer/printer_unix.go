@@ -4,49 +4,120 @@
 package printer
 
 import (
-	"encoding/base64"
+	"bytes"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"strconv"
+	"strings"
 	"time"
 
 	"goprint-bridge/logger"
 )
 
-// PrintPDF prints a PDF file using system commands (macOS/Linux)
-func PrintPDF(printerName string, base64Content string) error {
-	// Decode base64 content
-	pdfData, err := base64.StdEncoding.DecodeString(base64Content)
+// SystemBackend is the backend used for printers not declared in config.yaml
+const SystemBackend = "cups"
+
+// requestIDPattern extracts the job ID from lp output: "request id is Printer-42 (1 file(s))"
+var requestIDPattern = regexp.MustCompile(`request id is (\S+)`)
+
+// cupsBackend prints through the CUPS lp command (macOS/Linux)
+type cupsBackend struct{}
+
+func init() {
+	Register(cupsBackend{})
+}
+
+// Name returns the backend identifier
+func (cupsBackend) Name() string {
+	return SystemBackend
+}
+
+// Discover lists CUPS queues
+func (cupsBackend) Discover() ([]Printer, error) {
+	printers, err := listSystemPrinters()
 	if err != nil {
-		logger.PrintError("Failed to decode base64 PDF", err)
-		return fmt.Errorf("failed to decode base64: %w", err)
+		return nil, err
+	}
+	for i := range printers {
+		printers[i].Backend = SystemBackend
+	}
+	return printers, nil
+}
+
+// Capabilities reports what lp can pass on to CUPS
+func (cupsBackend) Capabilities(printerName string) (Capabilities, error) {
+	return Capabilities{
+		Formats: []string{FormatPDF, FormatRaw},
+		Options: []string{"copies", "collate", "sides", "page_ranges", "media", "orientation", "fit_to_page", "color_mode"},
+	}, nil
+}
+
+// Submit prints the job and returns the CUPS request ID
+func (cupsBackend) Submit(job Job) (string, error) {
+	switch job.Format {
+	case FormatPDF:
+		return printPDF(job.Printer, job.Data, job.Options)
+	case FormatRaw:
+		return printRaw(job.Printer, job.Data, job.Options)
+	default:
+		return "", fmt.Errorf("unsupported format %q", job.Format)
 	}
+}
 
+// Status looks the request up in the CUPS job lists
+func (cupsBackend) Status(printerName string, jobID string) (JobStatus, error) {
+	for _, which := range []string{"not-completed", "completed"} {
+		output, err := exec.Command("lpstat", "-W", which, "-o", printerName).Output()
+		if err != nil {
+			return JobStatus{}, fmt.Errorf("failed to run lpstat: %w", err)
+		}
+		for _, line := range strings.Split(string(output), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) > 0 && fields[0] == jobID {
+				if which == "completed" {
+					return JobStatus{State: "completed"}, nil
+				}
+				return JobStatus{State: "pending"}, nil
+			}
+		}
+	}
+	return JobStatus{State: "unknown"}, nil
+}
+
+// Cancel cancels a CUPS request
+func (cupsBackend) Cancel(printerName string, jobID string) error {
+	if output, err := exec.Command("cancel", jobID).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to cancel job: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// printPDF prints a PDF file using system commands (macOS/Linux)
+func printPDF(printerName string, pdfData []byte, opts PrintOptions) (string, error) {
 	// Create temp file
 	tempDir := os.TempDir()
-	tempFile := filepath.Join(tempDir, fmt.Sprintf("goprint_%d.pdf", time.Now().UnixNano()))
+	tempFile := filepath.Join(tempDir, fmt.Sprintf("%s%d.pdf", tempFilePrefix, time.Now().UnixNano()))
 
 	if err := os.WriteFile(tempFile, pdfData, 0644); err != nil {
 		logger.PrintError("Failed to write temp PDF file", err)
-		return fmt.Errorf("failed to write temp file: %w", err)
+		return "", fmt.Errorf("failed to write temp file: %w", err)
 	}
 
 	logger.Info(fmt.Sprintf("Created temp PDF: %s", tempFile))
 
 	// Print using lp command (CUPS)
-	var cmd *exec.Cmd
-	if printerName != "" {
-		cmd = exec.Command("lp", "-d", printerName, tempFile)
-	} else {
-		cmd = exec.Command("lp", tempFile)
-	}
+	args := append(lpArgs(printerName, opts), tempFile)
+	cmd := exec.Command("lp", args...)
 
-	if err := cmd.Run(); err != nil {
+	output, err := cmd.Output()
+	if err != nil {
 		logger.PrintError("Failed to execute print command", err)
 		go cleanupTempFile(tempFile, 20*time.Second)
-		return fmt.Errorf("failed to print PDF: %w", err)
+		return "", fmt.Errorf("failed to print PDF: %w", err)
 	}
 
 	logger.PrintSuccess(printerName)
@@ -54,81 +125,69 @@ func PrintPDF(printerName string, base64Content string) error {
 	// Cleanup temp file after delay
 	go cleanupTempFile(tempFile, 20*time.Second)
 
-	return nil
+	return parseRequestID(output), nil
 }
 
-// PrintRaw prints raw text using lp command (macOS/Linux)
-func PrintRaw(printerName string, content string) error {
-	var cmd *exec.Cmd
-
+// printRaw prints raw data using lp command (macOS/Linux)
+func printRaw(printerName string, data []byte, opts PrintOptions) (string, error) {
+	args := lpArgs(printerName, opts)
 	if runtime.GOOS == "darwin" {
 		// macOS: Use lp with raw option
-		if printerName != "" {
-			cmd = exec.Command("lp", "-d", printerName, "-o", "raw")
-		} else {
-			cmd = exec.Command("lp", "-o", "raw")
-		}
-	} else {
-		// Linux: Use lp
-		if printerName != "" {
-			cmd = exec.Command("lp", "-d", printerName)
-		} else {
-			cmd = exec.Command("lp")
-		}
+		args = append(args, "-o", "raw")
 	}
+	cmd := exec.Command("lp", args...)
+	cmd.Stdin = bytes.NewReader(data)
 
-	// Write content to stdin
-	stdin, err := cmd.StdinPipe()
+	output, err := cmd.Output()
 	if err != nil {
-		return fmt.Errorf("failed to get stdin pipe: %w", err)
-	}
-
-	go func() {
-		defer stdin.Close()
-		stdin.Write([]byte(content))
-	}()
-
-	if err := cmd.Run(); err != nil {
 		logger.PrintError("Failed to print raw text", err)
-		return fmt.Errorf("failed to print raw text: %w", err)
+		return "", fmt.Errorf("failed to print raw text: %w", err)
 	}
 
 	logger.PrintSuccess(printerName)
-	return nil
+	return parseRequestID(output), nil
 }
 
-// PrintTestPage prints a simple test page
-func PrintTestPage(printerName string) error {
-	testContent := `
-================================
-       GOPRINT TEST PAGE
-================================
-
-Printer: %s
-Time: %s
-
-Hello World!
-
-This is a test page from 
-GoPrintBridge v1.0.0
-
-If you can read this, your
-printer is working correctly.
-
-================================
-`
-	content := fmt.Sprintf(testContent, printerName, time.Now().Format("2006-01-02 15:04:05"))
-
-	logger.Info(fmt.Sprintf("Printing test page to: %s", printerName))
-	return PrintRaw(printerName, content)
+// parseRequestID returns the CUPS request ID printed by lp
+func parseRequestID(output []byte) string {
+	if matches := requestIDPattern.FindSubmatch(output); len(matches) >= 2 {
+		return string(matches[1])
+	}
+	return ""
 }
 
-// cleanupTempFile deletes a temporary file after a delay
-func cleanupTempFile(filePath string, delay time.Duration) {
-	time.Sleep(delay)
-	if err := os.Remove(filePath); err != nil {
-		logger.Error("Failed to cleanup temp file", err)
-	} else {
-		logger.Info(fmt.Sprintf("Cleaned up temp file: %s", filePath))
+// lpArgs builds the lp arguments for the printer and job options
+func lpArgs(printerName string, opts PrintOptions) []string {
+	var args []string
+	if printerName != "" {
+		args = append(args, "-d", printerName)
+	}
+	if opts.Title != "" {
+		args = append(args, "-t", opts.Title)
+	}
+	if copies := opts.copies(); copies > 1 {
+		args = append(args, "-n", strconv.Itoa(copies))
+	}
+	if opts.Collate {
+		args = append(args, "-o", "collate=true")
+	}
+	if opts.Sides != "" {
+		args = append(args, "-o", "sides="+opts.Sides)
+	}
+	if opts.PageRanges != "" {
+		args = append(args, "-o", "page-ranges="+opts.PageRanges)
+	}
+	if opts.Media != "" {
+		args = append(args, "-o", "media="+opts.Media)
+	}
+	if code, ok := validOrientations[opts.Orientation]; ok {
+		args = append(args, "-o", "orientation-requested="+code)
+	}
+	if opts.FitToPage {
+		args = append(args, "-o", "fit-to-page")
+	}
+	if opts.ColorMode != "" {
+		args = append(args, "-o", "print-color-mode="+opts.ColorMode)
 	}
+	return args
 }
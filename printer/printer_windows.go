@@ -4,11 +4,11 @@
 package printer
 
 import (
-	"encoding/base64"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
@@ -17,18 +17,69 @@ import (
 	winPrinter "github.com/alexbrainman/printer"
 )
 
-// PrintPDF prints a PDF file silently using PowerShell
-func PrintPDF(printerName string, base64Content string) error {
-	// Decode base64 content
-	pdfData, err := base64.StdEncoding.DecodeString(base64Content)
+// SystemBackend is the backend used for printers not declared in config.yaml
+const SystemBackend = "windows"
+
+// spoolerBackend prints through the Windows shell and spooler API
+type spoolerBackend struct{}
+
+func init() {
+	Register(spoolerBackend{})
+}
+
+// Name returns the backend identifier
+func (spoolerBackend) Name() string {
+	return SystemBackend
+}
+
+// Discover lists installed Windows printers
+func (spoolerBackend) Discover() ([]Printer, error) {
+	printers, err := listSystemPrinters()
 	if err != nil {
-		logger.PrintError("Failed to decode base64 PDF", err)
-		return fmt.Errorf("failed to decode base64: %w", err)
+		return nil, err
+	}
+	for i := range printers {
+		printers[i].Backend = SystemBackend
 	}
+	return printers, nil
+}
+
+// Capabilities reports what the spooler path can apply
+func (spoolerBackend) Capabilities(printerName string) (Capabilities, error) {
+	return Capabilities{
+		Formats: []string{FormatPDF, FormatRaw},
+		Options: []string{"copies"},
+	}, nil
+}
+
+// Submit prints the job. The spooler API does not expose job IDs.
+func (spoolerBackend) Submit(job Job) (string, error) {
+	switch job.Format {
+	case FormatPDF:
+		return "", printPDF(job.Printer, job.Data, job.Options)
+	case FormatRaw:
+		return "", printRaw(job.Printer, job.Data, job.Options)
+	default:
+		return "", fmt.Errorf("unsupported format %q", job.Format)
+	}
+}
+
+// Status is not available without a spooler job ID
+func (spoolerBackend) Status(printerName string, jobID string) (JobStatus, error) {
+	return JobStatus{}, ErrNotSupported
+}
+
+// Cancel is not available without a spooler job ID
+func (spoolerBackend) Cancel(printerName string, jobID string) error {
+	return ErrNotSupported
+}
 
+// printPDF prints a PDF file silently using PowerShell.
+// The shell print verb only honours the copies option.
+func printPDF(printerName string, pdfData []byte, opts PrintOptions) error {
 	// Create temp file
 	tempDir := os.TempDir()
-	tempFile := filepath.Join(tempDir, fmt.Sprintf("goprint_%d.pdf", time.Now().UnixNano()))
+	tempFile := filepath.Join(tempDir, fmt.Sprintf("%s%d.pdf", tempFilePrefix, time.Now().UnixNano()))
 
 	if err := os.WriteFile(tempFile, pdfData, 0644); err != nil {
 		logger.PrintError("Failed to write temp PDF file", err)
@@ -39,21 +90,31 @@ func PrintPDF(printerName string, base64Content string) error {
 
 	// Print using PowerShell (silent)
 	// Command: Start-Process -FilePath 'path' -Verb Print -WindowStyle Hidden
+	// PrintTo is used instead of Print to target a specific printer
 	psCmd := fmt.Sprintf(
 		`Start-Process -FilePath '%s' -Verb Print -WindowStyle Hidden`,
-		tempFile,
+		psQuote(tempFile),
 	)
-
-	cmd := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", psCmd)
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		HideWindow:    true,
-		CreationFlags: 0x08000000, // CREATE_NO_WINDOW
+	if printerName != "" {
+		psCmd = fmt.Sprintf(
+			`Start-Process -FilePath '%s' -Verb PrintTo -ArgumentList '"%s"' -WindowStyle Hidden`,
+			psQuote(tempFile), psQuote(printerName),
+		)
 	}
-	if err := cmd.Run(); err != nil {
-		logger.PrintError("Failed to execute print command", err)
-		// Still try to cleanup
-		go cleanupTempFile(tempFile, 20*time.Second)
-		return fmt.Errorf("failed to print PDF: %w", err)
+
+	// The shell print verb has no copies argument, so print once per copy
+	for i := 0; i < opts.copies(); i++ {
+		cmd := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", psCmd)
+		cmd.SysProcAttr = &syscall.SysProcAttr{
+			HideWindow:    true,
+			CreationFlags: 0x08000000, // CREATE_NO_WINDOW
+		}
+		if err := cmd.Run(); err != nil {
+			logger.PrintError("Failed to execute print command", err)
+			// Still try to cleanup
+			go cleanupTempFile(tempFile, 20*time.Second)
+			return fmt.Errorf("failed to print PDF: %w", err)
+		}
 	}
 
 	logger.PrintSuccess(printerName)
@@ -64,8 +125,9 @@ func PrintPDF(printerName string, base64Content string) error {
 	return nil
 }
 
-// PrintRaw prints raw text directly to the printer using Windows Spooler API
-func PrintRaw(printerName string, content string) error {
+// printRaw prints raw data directly to the printer using Windows Spooler API.
+// Only the title and copies options apply to raw data.
+func printRaw(printerName string, data []byte, opts PrintOptions) error {
 	// Open printer
 	p, err := winPrinter.Open(printerName)
 	if err != nil {
@@ -74,28 +136,36 @@ func PrintRaw(printerName string, content string) error {
 	}
 	defer p.Close()
 
+	title := opts.Title
+	if title == "" {
+		title = "GoPrintBridge Document"
+	}
+
 	// Start document
-	if err := p.StartDocument("GoPrintBridge Document", "RAW"); err != nil {
+	if err := p.StartDocument(title, "RAW"); err != nil {
 		logger.PrintError("Failed to start document", err)
 		return fmt.Errorf("failed to start document: %w", err)
 	}
 
-	// Start page
-	if err := p.StartPage(); err != nil {
-		logger.PrintError("Failed to start page", err)
-		return fmt.Errorf("failed to start page: %w", err)
-	}
-
-	// Write content
-	if _, err := p.Write([]byte(content)); err != nil {
-		logger.PrintError("Failed to write to printer", err)
-		return fmt.Errorf("failed to write to printer: %w", err)
-	}
-
-	// End page
-	if err := p.EndPage(); err != nil {
-		logger.PrintError("Failed to end page", err)
-		return fmt.Errorf("failed to end page: %w", err)
+	// Each copy is written as its own page
+	for i := 0; i < opts.copies(); i++ {
+		// Start page
+		if err := p.StartPage(); err != nil {
+			logger.PrintError("Failed to start page", err)
+			return fmt.Errorf("failed to start page: %w", err)
+		}
+
+		// Write content
+		if _, err := p.Write(data); err != nil {
+			logger.PrintError("Failed to write to printer", err)
+			return fmt.Errorf("failed to write to printer: %w", err)
+		}
+
+		// End page
+		if err := p.EndPage(); err != nil {
+			logger.PrintError("Failed to end page", err)
+			return fmt.Errorf("failed to end page: %w", err)
+		}
 	}
 
 	// End document
@@ -108,38 +178,7 @@ func PrintRaw(printerName string, content string) error {
 	return nil
 }
 
-// PrintTestPage prints a simple test page
-func PrintTestPage(printerName string) error {
-	testContent := `
-================================
-       GOPRINT TEST PAGE
-================================
-
-Printer: %s
-Time: %s
-
-Hello World!
-
-This is a test page from 
-GoPrintBridge v1.0.0
-
-If you can read this, your
-printer is working correctly.
-
-================================
-`
-	content := fmt.Sprintf(testContent, printerName, time.Now().Format("2006-01-02 15:04:05"))
-
-	logger.Info(fmt.Sprintf("Printing test page to: %s", printerName))
-	return PrintRaw(printerName, content)
-}
-
-// cleanupTempFile deletes a temporary file after a delay
-func cleanupTempFile(filePath string, delay time.Duration) {
-	time.Sleep(delay)
-	if err := os.Remove(filePath); err != nil {
-		logger.Error("Failed to cleanup temp file", err)
-	} else {
-		logger.Info(fmt.Sprintf("Cleaned up temp file: %s", filePath))
-	}
+// psQuote escapes a value for use inside a single-quoted PowerShell string
+func psQuote(value string) string {
+	return strings.ReplaceAll(value, "'", "''")
 }
@@ -0,0 +1,100 @@
+//go:build linux
+// +build linux
+
+package printer
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+
+	"goprint-bridge/config"
+)
+
+// baudRates maps supported speeds to termios constants
+var baudRates = map[int]uint32{
+	1200:   unix.B1200,
+	2400:   unix.B2400,
+	4800:   unix.B4800,
+	9600:   unix.B9600,
+	19200:  unix.B19200,
+	38400:  unix.B38400,
+	57600:  unix.B57600,
+	115200: unix.B115200,
+	230400: unix.B230400,
+}
+
+// dataBits maps character sizes to termios constants
+var dataBits = map[int]uint32{
+	5: unix.CS5,
+	6: unix.CS6,
+	7: unix.CS7,
+	8: unix.CS8,
+}
+
+// configureSerial puts a serial port in raw mode with the configured settings
+func configureSerial(f *os.File, pc config.PrinterConfig) error {
+	return control(f, func(fd int) error {
+		return setTermios(fd, pc)
+	})
+}
+
+// setTermios applies the serial settings to a descriptor
+func setTermios(fd int, pc config.PrinterConfig) error {
+	t, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return fmt.Errorf("not a serial device: %w", err)
+	}
+
+	baud := uint32(unix.B9600)
+	if pc.BaudRate > 0 {
+		b, ok := baudRates[pc.BaudRate]
+		if !ok {
+			return fmt.Errorf("unsupported baud rate %d", pc.BaudRate)
+		}
+		baud = b
+	}
+
+	size := uint32(unix.CS8)
+	if pc.DataBits > 0 {
+		s, ok := dataBits[pc.DataBits]
+		if !ok {
+			return fmt.Errorf("unsupported data bits %d", pc.DataBits)
+		}
+		size = s
+	}
+
+	// Raw mode: no line editing, translation or flow control
+	t.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON | unix.IXOFF
+	t.Oflag &^= unix.OPOST
+	t.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	t.Cflag &^= unix.CSIZE | unix.PARENB | unix.PARODD | unix.CSTOPB | unix.CBAUD
+	t.Cflag |= unix.CREAD | unix.CLOCAL | size | baud
+	t.Ispeed = baud
+	t.Ospeed = baud
+
+	switch pc.Parity {
+	case "", "none":
+	case "even":
+		t.Cflag |= unix.PARENB
+	case "odd":
+		t.Cflag |= unix.PARENB | unix.PARODD
+	default:
+		return fmt.Errorf("unsupported parity %q", pc.Parity)
+	}
+
+	switch pc.StopBits {
+	case 0, 1:
+	case 2:
+		t.Cflag |= unix.CSTOPB
+	default:
+		return fmt.Errorf("unsupported stop bits %d", pc.StopBits)
+	}
+
+	// Reads return whatever arrived within 0.5s
+	t.Cc[unix.VMIN] = 0
+	t.Cc[unix.VTIME] = 5
+
+	return unix.IoctlSetTermios(fd, unix.TCSETS, t)
+}
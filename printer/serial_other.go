@@ -0,0 +1,17 @@
+//go:build !linux
+// +build !linux
+
+package printer
+
+import (
+	"fmt"
+	"os"
+
+	"goprint-bridge/config"
+)
+
+// configureSerial is only implemented on Linux. Elsewhere the port must be
+// configured beforehand (e.g. with stty or Device Manager).
+func configureSerial(f *os.File, pc config.PrinterConfig) error {
+	return fmt.Errorf("serial settings are only supported on Linux")
+}
@@ -0,0 +1,171 @@
+package printer
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"goprint-bridge/config"
+	"goprint-bridge/logger"
+)
+
+const (
+	// defaultRawPort is the JetDirect / AppSocket port
+	defaultRawPort = "9100"
+
+	defaultConnectTimeout = 5 * time.Second
+	defaultWriteTimeout   = 30 * time.Second
+	defaultRetries        = 2
+
+	// probeTimeout bounds the reachability check used for status
+	probeTimeout = 2 * time.Second
+)
+
+// tcpBackend streams raw bytes to network printers on port 9100 (JetDirect)
+type tcpBackend struct{}
+
+func init() {
+	Register(tcpBackend{})
+}
+
+// Name returns the backend identifier
+func (tcpBackend) Name() string {
+	return "tcp"
+}
+
+// Discover lists the configured network printers and probes them in parallel
+func (tcpBackend) Discover() ([]Printer, error) {
+	configured := config.GetConfig().PrintersWithBackend("tcp")
+	printers := make([]Printer, len(configured))
+
+	var wg sync.WaitGroup
+	for i, pc := range configured {
+		wg.Add(1)
+		go func(i int, pc config.PrinterConfig) {
+			defer wg.Done()
+			status := "Ready"
+			if err := probe(rawAddress(pc.Address), probeTimeout); err != nil {
+				status = "Offline"
+			}
+			printers[i] = Printer{
+				Name:    pc.Name,
+				Status:  status,
+				Backend: "tcp",
+			}
+		}(i, pc)
+	}
+	wg.Wait()
+
+	return printers, nil
+}
+
+// Capabilities reports that only raw data is accepted
+func (tcpBackend) Capabilities(printerName string) (Capabilities, error) {
+	return Capabilities{
+		Formats: []string{FormatRaw},
+		Options: []string{"copies"},
+	}, nil
+}
+
+// Submit streams the job to the printer, retrying failed connections
+func (tcpBackend) Submit(job Job) (string, error) {
+	if job.Format != FormatRaw {
+		return "", fmt.Errorf("tcp backend only accepts raw data, got %q", job.Format)
+	}
+
+	pc := config.GetConfig().FindPrinter(job.Printer)
+	if pc == nil || pc.Address == "" {
+		return "", fmt.Errorf("printer %q has no address configured", job.Printer)
+	}
+
+	addr := rawAddress(pc.Address)
+	connectTimeout := secondsOr(pc.ConnectTimeout, defaultConnectTimeout)
+	writeTimeout := secondsOr(pc.WriteTimeout, defaultWriteTimeout)
+	retries := defaultRetries
+	if pc.Retries != nil && *pc.Retries >= 0 {
+		retries = *pc.Retries
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			logger.Info(fmt.Sprintf("Retrying %s (attempt %d of %d)", addr, attempt, retries))
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+
+		written, err := sendRaw(addr, job.Data, job.Options.copies(), connectTimeout, writeTimeout)
+		if err == nil {
+			logger.PrintSuccess(job.Printer)
+			return "", nil
+		}
+		lastErr = err
+
+		// Resending after part of the job reached the printer would
+		// print a partial duplicate
+		if written > 0 {
+			break
+		}
+	}
+
+	logger.PrintError("Failed to send raw data", lastErr)
+	return "", lastErr
+}
+
+// Status reports the job as completed, the printer keeps no job list
+func (tcpBackend) Status(printerName string, jobID string) (JobStatus, error) {
+	return JobStatus{State: "completed"}, nil
+}
+
+// Cancel is not possible once bytes have been sent
+func (tcpBackend) Cancel(printerName string, jobID string) error {
+	return ErrNotSupported
+}
+
+// sendRaw opens a connection and writes the data once per copy.
+// It returns the number of bytes written before any error.
+func sendRaw(addr string, data []byte, copies int, connectTimeout, writeTimeout time.Duration) (int, error) {
+	conn, err := net.DialTimeout("tcp", addr, connectTimeout)
+	if err != nil {
+		return 0, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	defer conn.Close()
+
+	written := 0
+	for i := 0; i < copies; i++ {
+		if err := conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+			return written, err
+		}
+		n, err := conn.Write(data)
+		written += n
+		if err != nil {
+			return written, fmt.Errorf("failed to write to %s: %w", addr, err)
+		}
+	}
+	return written, nil
+}
+
+// probe checks that the printer accepts connections
+func probe(addr string, timeout time.Duration) error {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// rawAddress adds the default port when the address has none
+func rawAddress(address string) string {
+	if _, _, err := net.SplitHostPort(address); err == nil {
+		return address
+	}
+	return net.JoinHostPort(address, defaultRawPort)
+}
+
+// secondsOr converts a timeout in seconds, using def when unset
+func secondsOr(seconds int, def time.Duration) time.Duration {
+	if seconds <= 0 {
+		return def
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,53 @@
+package printer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"goprint-bridge/logger"
+)
+
+// tempFilePrefix is the name prefix of temp files created for print jobs
+const tempFilePrefix = "goprint_"
+
+// cleanupTempFile deletes a temporary file after a delay
+func cleanupTempFile(filePath string, delay time.Duration) {
+	time.Sleep(delay)
+	if err := os.Remove(filePath); err != nil {
+		logger.Error("Failed to cleanup temp file", err)
+	} else {
+		logger.Info(fmt.Sprintf("Cleaned up temp file: %s", filePath))
+	}
+}
+
+// CleanupTempFiles removes temp files left behind by a previous run whose
+// delayed cleanup never happened (quit or crash). It returns the number of
+// files removed.
+func CleanupTempFiles() int {
+	tempDir := os.TempDir()
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		logger.Error("Failed to read temp directory", err)
+		return 0
+	}
+
+	removed := 0
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasPrefix(e.Name(), tempFilePrefix) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(tempDir, e.Name())); err != nil {
+			logger.Error("Failed to remove orphaned temp file", err)
+			continue
+		}
+		removed++
+	}
+
+	if removed > 0 {
+		logger.Info(fmt.Sprintf("Removed %d orphaned temp files", removed))
+	}
+	return removed
+}
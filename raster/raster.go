@@ -0,0 +1,265 @@
+package raster
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+
+	// Register decoders for image.Decode
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/tiff"
+)
+
+const (
+	// MaxImageSide bounds the width and height of decoded images
+	MaxImageSide = 20000
+	// MaxImagePixels bounds the area of decoded images, about 160 MB as RGBA
+	MaxImagePixels = 40000000
+)
+
+// Bitmap is a 1-bit image. Rows are packed MSB first and a set bit is a
+// black dot, which is the layout printer raster commands expect.
+type Bitmap struct {
+	Width  int
+	Height int
+	Stride int // Bytes per row
+	Pix    []byte
+}
+
+// NewBitmap creates a white bitmap
+func NewBitmap(width, height int) *Bitmap {
+	stride := (width + 7) / 8
+	return &Bitmap{
+		Width:  width,
+		Height: height,
+		Stride: stride,
+		Pix:    make([]byte, stride*height),
+	}
+}
+
+// Set marks the dot at x, y as black
+func (b *Bitmap) Set(x, y int) {
+	b.Pix[y*b.Stride+x/8] |= 0x80 >> uint(x%8)
+}
+
+// At reports whether the dot at x, y is black
+func (b *Bitmap) At(x, y int) bool {
+	return b.Pix[y*b.Stride+x/8]&(0x80>>uint(x%8)) != 0
+}
+
+// Row returns the packed bytes of row y
+func (b *Bitmap) Row(y int) []byte {
+	return b.Pix[y*b.Stride : (y+1)*b.Stride]
+}
+
+// DecodeBase64 decodes a base64 PNG, JPEG, GIF or TIFF image
+func DecodeBase64(content string) (image.Image, error) {
+	data, err := base64.StdEncoding.DecodeString(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode base64: %w", err)
+	}
+	return Decode(data)
+}
+
+// DecodeConfig reads an image's format and dimensions without decoding
+// it, and rejects images too large to decode safely
+func DecodeConfig(data []byte) (image.Config, string, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return cfg, format, fmt.Errorf("failed to decode image: %w", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return cfg, format, fmt.Errorf("empty image")
+	}
+	if cfg.Width > MaxImageSide || cfg.Height > MaxImageSide || cfg.Width*cfg.Height > MaxImagePixels {
+		return cfg, format, fmt.Errorf("image is %dx%d, larger than %d megapixels or %d pixels a side",
+			cfg.Width, cfg.Height, MaxImagePixels/1000000, MaxImageSide)
+	}
+	return cfg, format, nil
+}
+
+// Decode decodes a PNG, JPEG, GIF or TIFF image. The dimensions are
+// checked before any pixels are decoded, so a small file can't claim a
+// huge image.
+func Decode(data []byte) (image.Image, error) {
+	if _, _, err := DecodeConfig(data); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	return img, nil
+}
+
+// FitWidth returns the image scaled to the given width, keeping the aspect
+// ratio. Images narrower than width are left as they are.
+func FitWidth(img image.Image, width int) image.Image {
+	b := img.Bounds()
+	if width <= 0 || b.Dx() <= width {
+		return img
+	}
+	height := b.Dy() * width / b.Dx()
+	if height < 1 {
+		height = 1
+	}
+	return Resize(img, width, height)
+}
+
+// Resize scales the image to width x height by averaging the source
+// pixels that fall under each destination pixel
+func Resize(img image.Image, width, height int) *image.Gray {
+	src := Grayscale(img)
+	sb := src.Bounds()
+	dst := image.NewGray(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		y0 := sb.Min.Y + y*sb.Dy()/height
+		y1 := sb.Min.Y + (y+1)*sb.Dy()/height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0 := sb.Min.X + x*sb.Dx()/width
+			x1 := sb.Min.X + (x+1)*sb.Dx()/width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			sum, n := 0, 0
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					sum += int(src.GrayAt(sx, sy).Y)
+					n++
+				}
+			}
+			dst.SetGray(x, y, color.Gray{Y: uint8(sum / n)})
+		}
+	}
+	return dst
+}
+
+// Grayscale converts the image to 8-bit gray. Transparent pixels become
+// white, since paper is white.
+func Grayscale(img image.Image) *image.Gray {
+	if g, ok := img.(*image.Gray); ok {
+		return g
+	}
+
+	b := img.Bounds()
+	gray := image.NewGray(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, a := img.At(x, y).RGBA()
+			// Composite over white
+			r = r + (0xFFFF - a)
+			g = g + (0xFFFF - a)
+			bl = bl + (0xFFFF - a)
+			lum := (299*r + 587*g + 114*bl) / 1000
+			gray.SetGray(x, y, color.Gray{Y: uint8(lum >> 8)})
+		}
+	}
+	return gray
+}
+
+// Threshold converts the image to a bitmap, pixels darker than level
+// become black dots
+func Threshold(img image.Image, level uint8) *Bitmap {
+	gray := Grayscale(img)
+	b := gray.Bounds()
+	bmp := NewBitmap(b.Dx(), b.Dy())
+
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			if gray.GrayAt(b.Min.X+x, b.Min.Y+y).Y < level {
+				bmp.Set(x, y)
+			}
+		}
+	}
+	return bmp
+}
+
+// Dithering methods accepted by Monochrome
+const (
+	DitherThreshold      = "threshold"
+	DitherFloydSteinberg = "floyd-steinberg"
+	DitherAtkinson       = "atkinson"
+)
+
+// Monochrome converts the image to a bitmap with the given dithering
+// method. An empty method uses a plain threshold, which keeps logos and
+// signatures crisp.
+func Monochrome(img image.Image, method string) (*Bitmap, error) {
+	switch method {
+	case "", DitherThreshold:
+		return Threshold(img, 128), nil
+	case DitherFloydSteinberg:
+		return diffuse(img, floydSteinberg, 16), nil
+	case DitherAtkinson:
+		return diffuse(img, atkinson, 8), nil
+	default:
+		return nil, fmt.Errorf("unknown dithering method %q", method)
+	}
+}
+
+// weight spreads part of a pixel's quantization error to a neighbour
+type weight struct {
+	dx, dy int
+	w      int
+}
+
+var (
+	// floydSteinberg diffuses the whole error (weights sum to 16)
+	floydSteinberg = []weight{
+		{1, 0, 7},
+		{-1, 1, 3}, {0, 1, 5}, {1, 1, 1},
+	}
+	// atkinson diffuses 6/8 of the error, which keeps highlights and
+	// shadows clean on thermal paper
+	atkinson = []weight{
+		{1, 0, 1}, {2, 0, 1},
+		{-1, 1, 1}, {0, 1, 1}, {1, 1, 1},
+		{0, 2, 1},
+	}
+)
+
+// diffuse converts the image to a bitmap with error diffusion
+func diffuse(img image.Image, kernel []weight, divisor int) *Bitmap {
+	gray := Grayscale(img)
+	b := gray.Bounds()
+	width, height := b.Dx(), b.Dy()
+	bmp := NewBitmap(width, height)
+
+	levels := make([]int, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			levels[y*width+x] = int(gray.GrayAt(b.Min.X+x, b.Min.Y+y).Y)
+		}
+	}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			old := levels[y*width+x]
+			value := 255
+			if old < 128 {
+				value = 0
+				bmp.Set(x, y)
+			}
+
+			err := old - value
+			for _, k := range kernel {
+				nx, ny := x+k.dx, y+k.dy
+				if nx < 0 || nx >= width || ny >= height {
+					continue
+				}
+				levels[ny*width+nx] += err * k.w / divisor
+			}
+		}
+	}
+	return bmp
+}
@@ -0,0 +1,168 @@
+package raster
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// pngHeader returns the signature and IHDR chunk of a PNG that claims the
+// given size, without any pixel data
+func pngHeader(width, height uint32) []byte {
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], width)
+	binary.BigEndian.PutUint32(ihdr[4:], height)
+	ihdr[8] = 8 // Bit depth
+	ihdr[9] = 0 // Grayscale
+
+	var buf bytes.Buffer
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+	binary.Write(&buf, binary.BigEndian, uint32(len(ihdr)))
+	chunk := append([]byte("IHDR"), ihdr...)
+	buf.Write(chunk)
+	binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(chunk))
+	return buf.Bytes()
+}
+
+// uniform returns a gray image of one level
+func uniform(width, height int, level uint8) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = level
+	}
+	return img
+}
+
+// black counts the black dots of a bitmap
+func black(bmp *Bitmap) int {
+	n := 0
+	for y := 0; y < bmp.Height; y++ {
+		for x := 0; x < bmp.Width; x++ {
+			if bmp.At(x, y) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func TestDecodeRejectsHugeImages(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height uint32
+		want          string
+	}{
+		{name: "too wide", width: MaxImageSide + 1, height: 1, want: "larger than"},
+		{name: "too many pixels", width: 10000, height: 10000, want: "larger than"},
+		{name: "empty", width: 0, height: 10, want: "decode image"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(pngHeader(tt.width, tt.height))
+			if err == nil {
+				t.Fatal("Decode succeeded, want an error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, uniform(3, 2, 0)); err != nil {
+		t.Fatal(err)
+	}
+	img, err := Decode(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 3 || b.Dy() != 2 {
+		t.Errorf("size = %dx%d, want 3x2", b.Dx(), b.Dy())
+	}
+
+	if _, err := DecodeBase64("not base64!"); err == nil {
+		t.Error("DecodeBase64 accepted invalid base64")
+	}
+}
+
+func TestThreshold(t *testing.T) {
+	// Black left half, white right half, 10 dots wide so rows take 2 bytes
+	img := image.NewGray(image.Rect(0, 0, 10, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 10; x++ {
+			if x >= 5 {
+				img.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+
+	bmp, err := Monochrome(img, "")
+	if err != nil {
+		t.Fatalf("Monochrome failed: %v", err)
+	}
+	if bmp.Stride != 2 {
+		t.Fatalf("stride = %d, want 2", bmp.Stride)
+	}
+	for y := 0; y < 2; y++ {
+		if row := bmp.Row(y); !bytes.Equal(row, []byte{0xF8, 0x00}) {
+			t.Errorf("row %d = %08b, want [11111000 00000000]", y, row)
+		}
+	}
+}
+
+func TestGrayscaleTransparentIsWhite(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{A: 0})
+	if got := Grayscale(img).GrayAt(0, 0).Y; got != 255 {
+		t.Errorf("transparent pixel = %d, want 255", got)
+	}
+}
+
+func TestDither(t *testing.T) {
+	mid := uniform(64, 64, 128)
+	dark := uniform(64, 64, 64)
+	total := 64 * 64
+
+	tests := []struct {
+		method   string
+		img      image.Image
+		min, max int
+	}{
+		// A plain threshold prints mid gray as white
+		{method: DitherThreshold, img: mid, min: 0, max: 0},
+		// Error diffusion keeps the average level
+		{method: DitherFloydSteinberg, img: mid, min: total * 45 / 100, max: total * 55 / 100},
+		{method: DitherFloydSteinberg, img: dark, min: total * 70 / 100, max: total * 80 / 100},
+		// Atkinson drops a quarter of the error, so shadows fill in
+		{method: DitherAtkinson, img: dark, min: total * 78 / 100, max: total * 90 / 100},
+	}
+	for _, tt := range tests {
+		bmp, err := Monochrome(tt.img, tt.method)
+		if err != nil {
+			t.Fatalf("Monochrome(%s) failed: %v", tt.method, err)
+		}
+		if n := black(bmp); n < tt.min || n > tt.max {
+			t.Errorf("Monochrome(%s) printed %d of %d dots, want %d-%d", tt.method, n, total, tt.min, tt.max)
+		}
+	}
+
+	if _, err := Monochrome(mid, "ordered"); err == nil {
+		t.Error("Monochrome accepted an unknown dithering method")
+	}
+}
+
+func TestFitWidth(t *testing.T) {
+	if got := FitWidth(uniform(100, 50, 0), 40).Bounds(); got.Dx() != 40 || got.Dy() != 20 {
+		t.Errorf("scaled size = %dx%d, want 40x20", got.Dx(), got.Dy())
+	}
+	if got := FitWidth(uniform(30, 50, 0), 40).Bounds(); got.Dx() != 30 || got.Dy() != 50 {
+		t.Errorf("narrow image size = %dx%d, want 30x50 unscaled", got.Dx(), got.Dy())
+	}
+}
@@ -0,0 +1,286 @@
+package raster
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/go-text/typesetting/di"
+	"github.com/go-text/typesetting/font"
+	ot "github.com/go-text/typesetting/font/opentype"
+	"github.com/go-text/typesetting/shaping"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// DefaultFontSize matches the height of the ESC/POS font A (12x24 dots)
+const DefaultFontSize = 24
+
+// Font is a list of faces tried in order for each character. The built-in
+// Go font, which covers Latin, Greek and Cyrillic, is always the last one.
+type Font struct {
+	faces []*font.Face
+}
+
+var (
+	faceCacheMu sync.Mutex
+	faceCache   = make(map[string]*font.Face)
+)
+
+// LoadFont loads TrueType/OpenType fonts (.ttf, .otf, or the first font
+// of a .ttc collection). Parsed files are cached, since CJK fonts are large.
+func LoadFont(paths ...string) (*Font, error) {
+	f := &Font{}
+	for _, path := range paths {
+		face, err := loadFace(path)
+		if err != nil {
+			return nil, err
+		}
+		f.faces = append(f.faces, face)
+	}
+
+	builtin, err := loadFace("")
+	if err != nil {
+		return nil, err
+	}
+	f.faces = append(f.faces, builtin)
+	return f, nil
+}
+
+// loadFace parses a font file, or the built-in font for an empty path
+func loadFace(path string) (*font.Face, error) {
+	faceCacheMu.Lock()
+	defer faceCacheMu.Unlock()
+
+	if face, ok := faceCache[path]; ok {
+		return face, nil
+	}
+
+	data := goregular.TTF
+	if path != "" {
+		var err error
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read font %s: %w", path, err)
+		}
+	}
+
+	faces, err := font.ParseTTC(bytes.NewReader(data))
+	if err != nil {
+		if path == "" {
+			return nil, fmt.Errorf("failed to parse the built-in font: %w", err)
+		}
+		return nil, fmt.Errorf("failed to parse font %s: %w", path, err)
+	}
+	faceCache[path] = faces[0]
+	return faces[0], nil
+}
+
+// ResolveFace returns the first face that has a glyph for r
+func (f *Font) ResolveFace(r rune) *font.Face {
+	for _, face := range f.faces {
+		if _, ok := face.NominalGlyph(r); ok {
+			return face
+		}
+	}
+	return f.faces[0]
+}
+
+// TextStyle controls how text is rendered
+type TextStyle struct {
+	Size  int    // Font size in dots, defaults to DefaultFontSize
+	Bold  bool   // Thicken strokes by one dot
+	Align string // left, center, right; right-to-left paragraphs default to right
+}
+
+// RenderText draws text into a bitmap of the given width. Each line is
+// shaped (Arabic joining, Thai and Indic marks, ligatures), laid out in
+// bidi visual order and wrapped to fit.
+func RenderText(f *Font, text string, width int, style TextStyle) (*Bitmap, error) {
+	if width <= 0 || width > MaxImageSide {
+		return nil, fmt.Errorf("width %d must be between 1 and %d dots", width, MaxImageSide)
+	}
+	size := style.Size
+	if size <= 0 {
+		size = DefaultFontSize
+	}
+
+	r := textRenderer{font: f, size: fixed.I(size), width: width}
+	var lines []*image.Alpha
+	height := 0
+	for _, paragraph := range strings.Split(text, "\n") {
+		for _, line := range r.paragraph([]rune(paragraph), style.Align) {
+			lines = append(lines, line)
+			height += line.Bounds().Dy()
+		}
+	}
+	if height > MaxImageSide || width*height > MaxImagePixels {
+		return nil, fmt.Errorf("text is %dx%d dots, larger than %d megapixels or %d dots a side",
+			width, height, MaxImagePixels/1000000, MaxImageSide)
+	}
+
+	bmp := NewBitmap(width, height)
+	y0 := 0
+	for _, line := range lines {
+		b := line.Bounds()
+		for y := 0; y < b.Dy(); y++ {
+			for x := 0; x < width; x++ {
+				black := line.AlphaAt(x, y).A >= 128
+				if style.Bold && x > 0 && line.AlphaAt(x-1, y).A >= 128 {
+					black = true
+				}
+				if black {
+					bmp.Set(x, y0+y)
+				}
+			}
+		}
+		y0 += b.Dy()
+	}
+	return bmp, nil
+}
+
+// textRenderer lays out paragraphs at one size and width
+type textRenderer struct {
+	font  *Font
+	size  fixed.Int26_6
+	width int
+
+	shaper    shaping.HarfbuzzShaper
+	segmenter shaping.Segmenter
+	wrapper   shaping.LineWrapper
+}
+
+// paragraph shapes, wraps and draws one paragraph, one image per line
+func (r *textRenderer) paragraph(text []rune, align string) []*image.Alpha {
+	dir := di.DirectionLTR
+	if isRTL(text) {
+		dir = di.DirectionRTL
+		if align == "" {
+			align = "right"
+		}
+	}
+
+	if len(text) == 0 {
+		// Keep blank lines at the height of a text line
+		text = []rune{' '}
+	}
+
+	input := shaping.Input{
+		Text:      text,
+		RunStart:  0,
+		RunEnd:    len(text),
+		Direction: dir,
+		Size:      r.size,
+	}
+	var runs []shaping.Output
+	for _, run := range r.segmenter.Split(input, r.font) {
+		runs = append(runs, r.shaper.Shape(run))
+	}
+
+	lines, _ := r.wrapper.WrapParagraph(shaping.WrapConfig{Direction: dir}, r.width, text, shaping.NewSliceIterator(runs))
+
+	images := make([]*image.Alpha, 0, len(lines))
+	for _, line := range lines {
+		images = append(images, r.drawLine(line, align))
+	}
+	return images
+}
+
+// drawLine rasterizes the glyph outlines of a wrapped line
+func (r *textRenderer) drawLine(line shaping.Line, align string) *image.Alpha {
+	// Runs are drawn in visual order
+	sort.Slice(line, func(i, j int) bool {
+		return line[i].VisualIndex < line[j].VisualIndex
+	})
+
+	var ascent, descent, advance fixed.Int26_6
+	for _, run := range line {
+		ascent = max(ascent, run.LineBounds.Ascent)
+		descent = min(descent, run.LineBounds.Descent)
+		advance += run.Advance
+	}
+	height := (ascent - descent).Ceil()
+	if height < 1 {
+		height = 1
+	}
+
+	pen := float32(0)
+	switch align {
+	case "center":
+		pen = float32(r.width-advance.Round()) / 2
+	case "right":
+		pen = float32(r.width - advance.Round())
+	}
+	baseline := float32(ascent.Ceil())
+
+	z := vector.NewRasterizer(r.width, height)
+	for _, run := range line {
+		scale := float32(run.Size) / 64 / float32(run.Face.Upem())
+		for _, g := range run.Glyphs {
+			outline, ok := run.Face.GlyphDataOutline(g.GlyphID)
+			if ok {
+				x := pen + fixedToFloat(g.XOffset)
+				y := baseline - fixedToFloat(g.YOffset)
+				drawOutline(z, outline, x, y, scale)
+			}
+			pen += fixedToFloat(g.XAdvance)
+		}
+	}
+
+	img := image.NewAlpha(image.Rect(0, 0, r.width, height))
+	z.Draw(img, img.Bounds(), image.Opaque, image.Point{})
+	return img
+}
+
+// drawOutline adds a glyph outline with its origin at x, y. Font units
+// grow upwards, image rows grow downwards.
+func drawOutline(z *vector.Rasterizer, outline font.GlyphOutline, x, y, scale float32) {
+	px := func(p font.SegmentPoint) (float32, float32) {
+		return x + p.X*scale, y - p.Y*scale
+	}
+
+	for _, seg := range outline.Segments {
+		switch seg.Op {
+		case ot.SegmentOpMoveTo:
+			// Each contour is closed before the next one starts
+			z.ClosePath()
+			z.MoveTo(px(seg.Args[0]))
+		case ot.SegmentOpLineTo:
+			z.LineTo(px(seg.Args[0]))
+		case ot.SegmentOpQuadTo:
+			x1, y1 := px(seg.Args[0])
+			x2, y2 := px(seg.Args[1])
+			z.QuadTo(x1, y1, x2, y2)
+		case ot.SegmentOpCubeTo:
+			x1, y1 := px(seg.Args[0])
+			x2, y2 := px(seg.Args[1])
+			x3, y3 := px(seg.Args[2])
+			z.CubeTo(x1, y1, x2, y2, x3, y3)
+		}
+	}
+	z.ClosePath()
+}
+
+// isRTL reports whether the first strong character is right-to-left
+func isRTL(text []rune) bool {
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Arabic, unicode.Hebrew, unicode.Syriac, unicode.Thaana, unicode.Nko):
+			return true
+		case unicode.IsLetter(r):
+			return false
+		}
+	}
+	return false
+}
+
+// fixedToFloat converts a 26.6 fixed point value to dots
+func fixedToFloat(v fixed.Int26_6) float32 {
+	return float32(v) / 64
+}
@@ -0,0 +1,144 @@
+package raster
+
+import (
+	"strings"
+	"testing"
+)
+
+// inkColumns returns the first and last columns with a black dot
+func inkColumns(bmp *Bitmap) (int, int) {
+	first, last := -1, -1
+	for x := 0; x < bmp.Width; x++ {
+		for y := 0; y < bmp.Height; y++ {
+			if bmp.At(x, y) {
+				if first < 0 {
+					first = x
+				}
+				last = x
+				break
+			}
+		}
+	}
+	return first, last
+}
+
+func TestRenderText(t *testing.T) {
+	font, err := LoadFont()
+	if err != nil {
+		t.Fatalf("LoadFont failed: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		text  string
+		style TextStyle
+		check func(t *testing.T, first, last int)
+	}{
+		{
+			name: "left",
+			text: "Total",
+			check: func(t *testing.T, first, last int) {
+				if first > 10 || last > 200 {
+					t.Errorf("ink in columns %d-%d, want it at the left", first, last)
+				}
+			},
+		},
+		{
+			name:  "right",
+			text:  "Total",
+			style: TextStyle{Align: "right"},
+			check: func(t *testing.T, first, last int) {
+				if first < 184 || last < 374 {
+					t.Errorf("ink in columns %d-%d, want it at the right", first, last)
+				}
+			},
+		},
+		{
+			name: "right-to-left defaults to the right",
+			text: "שלום",
+			check: func(t *testing.T, first, last int) {
+				if first < 184 || last < 374 {
+					t.Errorf("ink in columns %d-%d, want it at the right", first, last)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bmp, err := RenderText(font, tt.text, 384, tt.style)
+			if err != nil {
+				t.Fatalf("RenderText failed: %v", err)
+			}
+			if bmp.Width != 384 || bmp.Height < DefaultFontSize {
+				t.Fatalf("size = %dx%d, want 384 wide and a line high", bmp.Width, bmp.Height)
+			}
+			first, last := inkColumns(bmp)
+			if first < 0 {
+				t.Fatal("nothing was drawn")
+			}
+			tt.check(t, first, last)
+		})
+	}
+}
+
+func TestRenderTextLines(t *testing.T) {
+	font, err := LoadFont()
+	if err != nil {
+		t.Fatalf("LoadFont failed: %v", err)
+	}
+
+	one, err := RenderText(font, "word", 384, TextStyle{})
+	if err != nil {
+		t.Fatalf("RenderText failed: %v", err)
+	}
+	// A blank line keeps its height, long text wraps to more lines
+	two, err := RenderText(font, "word\n", 384, TextStyle{})
+	if err != nil {
+		t.Fatalf("RenderText failed: %v", err)
+	}
+	wrapped, err := RenderText(font, strings.Repeat("word ", 30), 384, TextStyle{})
+	if err != nil {
+		t.Fatalf("RenderText failed: %v", err)
+	}
+	if two.Height != 2*one.Height {
+		t.Errorf("two lines are %d dots high, want %d", two.Height, 2*one.Height)
+	}
+	if wrapped.Height < 3*one.Height {
+		t.Errorf("wrapped text is %d dots high, want at least 3 lines of %d", wrapped.Height, one.Height)
+	}
+
+	bold, err := RenderText(font, "word", 384, TextStyle{Bold: true})
+	if err != nil {
+		t.Fatalf("RenderText failed: %v", err)
+	}
+	count := func(bmp *Bitmap) int {
+		n := 0
+		for _, b := range bmp.Pix {
+			for ; b != 0; b &= b - 1 {
+				n++
+			}
+		}
+		return n
+	}
+	if count(bold) <= count(one) {
+		t.Errorf("bold text has %d dots, want more than %d", count(bold), count(one))
+	}
+}
+
+func TestRenderTextErrors(t *testing.T) {
+	font, err := LoadFont()
+	if err != nil {
+		t.Fatalf("LoadFont failed: %v", err)
+	}
+	if _, err := RenderText(font, "x", 0, TextStyle{}); err == nil {
+		t.Error("RenderText accepted a width of 0")
+	}
+	// Far more lines than the tallest image allowed
+	if _, err := RenderText(font, strings.Repeat("x\n", 1000), 384, TextStyle{}); err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("RenderText error = %v, want the text rejected as too large", err)
+	}
+
+	if _, err := LoadFont("/nonexistent/font.ttf"); err == nil || !strings.Contains(err.Error(), "/nonexistent/font.ttf") {
+		t.Errorf("LoadFont error = %v, want it to name the file", err)
+	}
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"goprint-bridge/auth"
+	"goprint-bridge/config"
+	"goprint-bridge/logger"
+)
+
+// apiKeyHeader carries the client's API key
+const apiKeyHeader = "X-API-Key"
+
+// apiKeyLocal is the request local holding the authenticated key
+const apiKeyLocal = "api_key"
+
+// Error codes returned with authentication failures
+const (
+	errMissingAPIKey     = "missing_api_key"
+	errInvalidAPIKey     = "invalid_api_key"
+	errPrinterNotAllowed = "printer_not_allowed"
+	errTypeNotAllowed    = "type_not_allowed"
+	errPayloadTooLarge   = "payload_too_large"
+)
+
+// authenticate requires a valid API key once keys are configured, and
+// enforces the key's payload limit. The health check stays open, and
+// clients identified by their certificate need no key.
+func authenticate(c *fiber.Ctx) error {
+	keys := config.GetConfig().APIKeys
+	if len(keys) == 0 || c.Path() == "/health" || clientCert(c) != nil {
+		return c.Next()
+	}
+
+	key := c.Get(apiKeyHeader)
+	if key == "" {
+		return deny(c, 401, errMissingAPIKey, "Missing API key")
+	}
+
+	var client *config.APIKeyConfig
+	for i := range keys {
+		if auth.VerifyKey(key, keys[i].Hash) {
+			client = &keys[i]
+			break
+		}
+	}
+	if client == nil {
+		return deny(c, 401, errInvalidAPIKey, "Invalid API key")
+	}
+
+	c.Locals(apiKeyLocal, client)
+	if client.MaxPayload > 0 && c.Request().Header.ContentLength() > client.MaxPayload {
+		return deny(c, 413, errPayloadTooLarge, "Payload exceeds the limit for this API key")
+	}
+	// Chunked bodies have no Content-Length, check what was read
+	if client.MaxPayload > 0 && len(c.Body()) > client.MaxPayload {
+		return deny(c, 413, errPayloadTooLarge, "Payload exceeds the limit for this API key")
+	}
+	return c.Next()
+}
+
+// apiKey returns the key that authenticated the request, or nil when
+// authentication is off
+func apiKey(c *fiber.Ctx) *config.APIKeyConfig {
+	client, _ := c.Locals(apiKeyLocal).(*config.APIKeyConfig)
+	return client
+}
+
+// clientName returns the name of the request's API key for logs
+func clientName(c *fiber.Ctx) string {
+	if client := apiKey(c); client != nil {
+		return client.Name
+	}
+	return ""
+}
+
+// scope is what a client may print: an API key or a client certificate
+type scope interface {
+	AllowsPrinter(name string) bool
+	AllowsType(printType string) bool
+}
+
+// scopes returns the limits that apply to the request
+func scopes(c *fiber.Ctx) []scope {
+	var list []scope
+	if client := apiKey(c); client != nil {
+		list = append(list, client)
+	}
+	if client := clientCert(c); client != nil {
+		list = append(list, client)
+	}
+	return list
+}
+
+// allowsPrinter reports whether the request's API key and client
+// certificate may use the printer
+func allowsPrinter(c *fiber.Ctx, name string) bool {
+	for _, sc := range scopes(c) {
+		if !sc.AllowsPrinter(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// deny logs and answers a rejected request
+func deny(c *fiber.Ctx, status int, code string, message string) error {
+	logger.AccessDenied(code, c.Path(), c.IP(), clientName(c), identityName(c))
+	return c.Status(status).JSON(PrintResponse{
+		Success: false,
+		Message: message,
+		Error:   code,
+	})
+}
@@ -0,0 +1,229 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+
+	"goprint-bridge/auth"
+	"goprint-bridge/printer"
+)
+
+// authConfig declares file sink printers and keys limited to some of
+// them, with plaintext keys that LoadConfig hashes
+const authConfig = `
+printers:
+  - name: Front
+    backend: file
+  - name: Kitchen
+    backend: file
+api_keys:
+  - name: admin
+    key: admin-secret
+  - name: front
+    key: front-secret
+    printers: [Front]
+    types: [text]
+    max_payload: 200
+`
+
+// newTestServer builds a server with the authentication middleware and
+// routes, whose jobs complete without printing
+func newTestServer(t *testing.T) *Server {
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use(identifyClient)
+	app.Use(authenticate)
+
+	s := &Server{app: app, nonces: auth.NewNonceCache()}
+	s.queue = NewJobQueue(nil, func(q *JobQueue, job *Job) error { return nil }, nil, nil)
+	s.templates = openTemplates()
+	t.Cleanup(func() { s.templates.Close() })
+	s.setupRoutes()
+	return s
+}
+
+// request sends a request with an optional API key and returns the status
+// and body
+func request(t *testing.T, s *Server, method, path, key, body string) (int, string) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if key != "" {
+		req.Header.Set(apiKeyHeader, key)
+	}
+	resp, err := s.app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(data)
+}
+
+// errorCode returns the error code of a JSON response
+func errorCode(t *testing.T, body string) string {
+	t.Helper()
+	var resp PrintResponse
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatalf("response %q: %v", body, err)
+	}
+	return resp.Error
+}
+
+func TestAPIKeysHashed(t *testing.T) {
+	cfg := loadConfig(t, authConfig)
+
+	for _, k := range cfg.APIKeys {
+		if k.Key != "" || !auth.IsHash(k.Hash) {
+			t.Errorf("key %s = %+v, want only a hash", k.Name, k)
+		}
+	}
+	data, err := os.ReadFile("config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret") {
+		t.Errorf("config.yaml still holds a plaintext key:\n%s", data)
+	}
+
+	s := newTestServer(t)
+	tests := []struct {
+		name   string
+		key    string
+		status int
+		code   string
+	}{
+		{name: "missing key", status: 401, code: errMissingAPIKey},
+		{name: "unknown key", key: "guess", status: 401, code: errInvalidAPIKey},
+		{name: "the stored hash", key: cfg.APIKeys[0].Hash, status: 401, code: errInvalidAPIKey},
+		{name: "plaintext key", key: "admin-secret", status: 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := request(t, s, "GET", "/templates", tt.key, "")
+			if status != tt.status {
+				t.Fatalf("status = %d, want %d: %s", status, tt.status, body)
+			}
+			if tt.code != "" {
+				if code := errorCode(t, body); code != tt.code {
+					t.Errorf("error = %q, want %q", code, tt.code)
+				}
+			}
+		})
+	}
+
+	// The health check needs no key
+	if status, body := request(t, s, "GET", "/health", "", ""); status != 200 {
+		t.Errorf("GET /health = %d, want 200: %s", status, body)
+	}
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	loadConfig(t, authConfig)
+	s := newTestServer(t)
+
+	tests := []struct {
+		name   string
+		key    string
+		body   string
+		status int
+		code   string
+	}{
+		{name: "allowed", key: "front-secret", body: `{"type": "text", "content": "hi", "printer": "Front"}`, status: 202},
+		{name: "other printer", key: "front-secret", body: `{"type": "text", "content": "hi", "printer": "Kitchen"}`, status: 403, code: errPrinterNotAllowed},
+		{name: "other type", key: "front-secret", body: `{"type": "raw", "content": "hi", "printer": "Front"}`, status: 403, code: errTypeNotAllowed},
+		{name: "too large", key: "front-secret", body: `{"type": "text", "printer": "Front", "content": "` + strings.Repeat("x", 200) + `"}`, status: 413, code: errPayloadTooLarge},
+		{name: "unlimited key", key: "admin-secret", body: `{"type": "raw", "printer": "Kitchen", "content": "` + strings.Repeat("x", 200) + `"}`, status: 202},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := request(t, s, "POST", "/print", tt.key, tt.body)
+			if status != tt.status {
+				t.Fatalf("status = %d, want %d: %s", status, tt.status, body)
+			}
+			if tt.code != "" {
+				if code := errorCode(t, body); code != tt.code {
+					t.Errorf("error = %q, want %q", code, tt.code)
+				}
+			}
+		})
+	}
+}
+
+func TestAPIKeyScopesJobsAndPrinters(t *testing.T) {
+	loadConfig(t, authConfig)
+	s := newTestServer(t)
+
+	// Clients only see the printers they may use
+	for key, want := range map[string][]string{"admin-secret": {"Front", "Kitchen"}, "front-secret": {"Front"}} {
+		status, body := request(t, s, "GET", "/printers", key, "")
+		if status != 200 {
+			t.Fatalf("GET /printers = %d: %s", status, body)
+		}
+		var printers []printer.Printer
+		if err := json.Unmarshal([]byte(body), &printers); err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, p := range printers {
+			if p.Backend == "file" {
+				names = append(names, p.Name)
+			}
+		}
+		if strings.Join(names, ",") != strings.Join(want, ",") {
+			t.Errorf("GET /printers with %s = %v, want %v", key, names, want)
+		}
+	}
+	if status, body := request(t, s, "GET", "/printers/Kitchen", "front-secret", ""); status != 403 || errorCode(t, body) != errPrinterNotAllowed {
+		t.Errorf("GET /printers/Kitchen = %d %s, want 403", status, body)
+	}
+
+	// Jobs for other printers can't be looked at or cancelled
+	job, err := s.queue.Enqueue("raw", "Kitchen", printer.PrintOptions{}, "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, method := range []string{"GET", "DELETE"} {
+		if status, body := request(t, s, method, "/jobs/"+job.ID, "front-secret", ""); status != 403 || errorCode(t, body) != errPrinterNotAllowed {
+			t.Errorf("%s /jobs/:id = %d %s, want 403", method, status, body)
+		}
+	}
+	if status, body := request(t, s, "GET", "/jobs/"+job.ID, "admin-secret", ""); status != 200 {
+		t.Errorf("GET /jobs/:id = %d %s, want 200", status, body)
+	}
+}
+
+func TestPayloadLimitBeforeBody(t *testing.T) {
+	loadConfig(t, authConfig)
+	app := fiber.New()
+
+	// The declared length rejects a request before its body is read
+	fctx := &fasthttp.RequestCtx{}
+	fctx.Request.Header.SetMethod("POST")
+	fctx.Request.SetRequestURI("/print")
+	fctx.Request.Header.Set(apiKeyHeader, "front-secret")
+	fctx.Request.Header.SetContentLength(201)
+	c := app.AcquireCtx(fctx)
+	defer app.ReleaseCtx(c)
+
+	if err := authenticate(c); err != nil {
+		t.Fatalf("authenticate failed: %v", err)
+	}
+	if status := fctx.Response.StatusCode(); status != 413 {
+		t.Fatalf("status = %d, want 413", status)
+	}
+	if code := errorCode(t, string(fctx.Response.Body())); code != errPayloadTooLarge {
+		t.Errorf("error = %q, want %q", code, errPayloadTooLarge)
+	}
+}
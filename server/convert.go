@@ -0,0 +1,335 @@
+package server
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"goprint-bridge/config"
+	"goprint-bridge/escpos"
+	"goprint-bridge/label"
+	"goprint-bridge/layout"
+	"goprint-bridge/pdf"
+	"goprint-bridge/printer"
+)
+
+// imageSeparator separates the images of a job in its content
+const imageSeparator = "\n"
+
+// prepareContent fills in templates that depend on request fields which
+// aren't kept with the job, so the queued content is final
+func prepareContent(req *PrintRequest, opts *printer.PrintOptions) (string, error) {
+	switch req.Type {
+	case "zpl":
+		template, err := label.EmbedGraphics(req.Content, req.Graphics)
+		if err != nil {
+			return "", err
+		}
+
+		// With a serial, every copy is its own label
+		count := 1
+		if req.Serial != nil {
+			count = serialLabels(req.Serial, *opts)
+			if req.Serial.Count > 0 && opts.Copies > 1 {
+				return "", fmt.Errorf("serial count and copies can't be combined")
+			}
+			opts.Copies = 1
+		}
+		return label.RenderZPL(template, req.Data, req.Serial, count)
+	case "image":
+		// One image per line, with any line breaks inside the base64 removed
+		var images []string
+		for _, content := range append([]string{req.Content}, req.Images...) {
+			content = strings.Join(strings.Fields(content), "")
+			if content != "" {
+				images = append(images, content)
+			}
+		}
+		if len(images) == 0 {
+			return "", fmt.Errorf("no images")
+		}
+		return strings.Join(images, imageSeparator), nil
+	default:
+		return req.Content, nil
+	}
+}
+
+// serialLabels returns how many labels a serial prints: its count, or
+// else one per copy
+func serialLabels(serial *label.Serial, opts printer.PrintOptions) int {
+	if serial.Count > 0 {
+		return serial.Count
+	}
+	return opts.Copies
+}
+
+// convertContent turns the job content into a document format and bytes
+// that a printer backend understands
+func convertContent(job *Job) (string, []byte, error) {
+	switch job.Type {
+	case "pdf":
+		// PDF: decode base64
+		data, err := base64.StdEncoding.DecodeString(job.content)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to decode base64: %w", err)
+		}
+		return printer.FormatPDF, data, nil
+	case "escpos":
+		// ESC/POS: compile the JSON command list
+		cmds, err := escpos.Parse(job.content)
+		if err != nil {
+			return "", nil, err
+		}
+		opts, err := escposOptions(job.Printer)
+		if err != nil {
+			return "", nil, err
+		}
+		data, err := escpos.Compile(cmds, opts)
+		if err != nil {
+			return "", nil, err
+		}
+		return printer.FormatRaw, data, nil
+	case "image":
+		// Image: PDF pages for document printers, otherwise dither and
+		// print in the printer's language
+		images := strings.Split(job.content, imageSeparator)
+		pc := config.GetConfig().FindPrinter(job.Printer)
+		if pc != nil && pc.Language == "pdf" {
+			data, err := imagePDF(images, job.Options)
+			return printer.FormatPDF, data, err
+		}
+		if pc != nil && pc.Language == "zpl" {
+			var zpl strings.Builder
+			for i, content := range images {
+				z, err := label.ImageLabel(content, pc.DotWidth, job.Options.Dither)
+				if err != nil {
+					return "", nil, fmt.Errorf("image %d: %w", i+1, err)
+				}
+				zpl.WriteString(z)
+			}
+			return printer.FormatRaw, []byte(zpl.String()), nil
+		}
+
+		cmds := make([]escpos.Command, 0, len(images))
+		for _, content := range images {
+			cmds = append(cmds, escpos.Command{
+				Type:    "image",
+				Content: content,
+				Dither:  job.Options.Dither,
+				Align:   "center",
+			})
+		}
+		opts, err := escposOptions(job.Printer)
+		if err != nil {
+			return "", nil, err
+		}
+		data, err := escpos.Compile(cmds, opts)
+		if err != nil {
+			return "", nil, err
+		}
+		return printer.FormatRaw, data, nil
+	case "zpl":
+		// ZPL: already rendered, sent as is
+		if err := label.ValidateZPL(job.content); err != nil {
+			return "", nil, err
+		}
+		return printer.FormatRaw, []byte(job.content), nil
+	case "tspl", "epl":
+		// TSPL / EPL2: compile the label description
+		doc, err := label.ParseDocument(job.content)
+		if err != nil {
+			return "", nil, err
+		}
+		var data []byte
+		if job.Type == "tspl" {
+			data, err = label.CompileTSPL(doc)
+		} else {
+			data, err = label.CompileEPL(doc)
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		return printer.FormatRaw, data, nil
+	case "receipt":
+		// Receipt: lay out in the printer's line width
+		data, err := renderReceipt(job)
+		return printer.FormatRaw, data, err
+	case "text":
+		// Plain text: lay out as PDF pages for office printers
+		if pc := config.GetConfig().FindPrinter(job.Printer); pc != nil && pc.TextPDF != nil {
+			data, err := pdf.Text(job.content, textPDFOptions(pc.TextPDF, job.Options))
+			return printer.FormatPDF, data, err
+		}
+		// Otherwise send in the printer's encoding, or as images
+		data, err := renderText(job)
+		return printer.FormatRaw, data, err
+	case "raw":
+		// Raw data: send in the printer's encoding
+		data, err := encodeText(job.Printer, job.content)
+		return printer.FormatRaw, data, err
+	default:
+		// Default: treat as raw text
+		data, err := encodeText(job.Printer, job.content)
+		return printer.FormatRaw, data, err
+	}
+}
+
+// encodeText transcodes raw text to the printer's configured encoding.
+// Printers set to the escpos language also get the ESC t command that
+// selects it. Without an encoding the text is sent as is.
+func encodeText(printerName, content string) ([]byte, error) {
+	opts, err := escposOptions(printerName)
+	if err != nil {
+		return nil, err
+	}
+	if opts.CodePage == nil {
+		return []byte(content), nil
+	}
+
+	// The config may have changed since the options were read. A printer
+	// without a language may not speak ESC/POS, ESC t would print as text.
+	data := opts.CodePage.Encode(content, opts.Substitute)
+	if pc := config.GetConfig().FindPrinter(printerName); pc != nil && pc.Language == "escpos" {
+		data = append(opts.CodePage.Select(), data...)
+	}
+	return data, nil
+}
+
+// renderText prints plain text through the ESC/POS builder when the
+// printer renders text as images, line by line
+func renderText(job *Job) ([]byte, error) {
+	pc := config.GetConfig().FindPrinter(job.Printer)
+	if pc == nil || pc.RasterText == "" || (pc.Language != "" && pc.Language != "escpos") {
+		return encodeText(job.Printer, job.content)
+	}
+
+	opts, err := escposOptions(job.Printer)
+	if err != nil {
+		return nil, err
+	}
+	var cmds []escpos.Command
+	for _, line := range strings.Split(strings.TrimSuffix(job.content, "\n"), "\n") {
+		cmds = append(cmds, escpos.Command{Type: "text", Text: line})
+	}
+	return escpos.Compile(cmds, opts)
+}
+
+// renderReceipt lays out a receipt description. ESC/POS printers get bold
+// and double size text; other printers get plain lines in their encoding.
+func renderReceipt(job *Job) ([]byte, error) {
+	receipt, err := layout.ParseReceipt(job.content)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := escposOptions(job.Printer)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := receipt.Layout(opts.LineColumns())
+	if err != nil {
+		return nil, err
+	}
+
+	pc := config.GetConfig().FindPrinter(job.Printer)
+	if pc != nil && pc.Language != "" && pc.Language != "escpos" {
+		return encodeText(job.Printer, layout.Text(lines))
+	}
+
+	cmds := make([]escpos.Command, 0, len(lines)+1)
+	for _, line := range lines {
+		cmds = append(cmds, escpos.Command{
+			Type:   "text",
+			Text:   line.Text,
+			Bold:   line.Bold,
+			Width:  line.Size,
+			Height: line.Size,
+		})
+	}
+	if receipt.Cut {
+		cmds = append(cmds, escpos.Command{Type: "cut", Partial: true})
+	}
+	return escpos.Compile(cmds, opts)
+}
+
+// imagePDF places base64 images on PDF pages. The request's media picks
+// the page size and its orientation, when set, the page orientation.
+func imagePDF(images []string, opts printer.PrintOptions) ([]byte, error) {
+	files := make([][]byte, 0, len(images))
+	for i, content := range images {
+		data, err := base64.StdEncoding.DecodeString(content)
+		if err != nil {
+			return nil, fmt.Errorf("image %d: failed to decode base64: %w", i+1, err)
+		}
+		files = append(files, data)
+	}
+
+	orientation := ""
+	switch opts.Orientation {
+	case "portrait", "reverse-portrait":
+		orientation = "portrait"
+	case "landscape", "reverse-landscape":
+		orientation = "landscape"
+	}
+	margin := float64(pdf.DefaultImageMargin)
+	if opts.Margin != nil {
+		margin = *opts.Margin
+	}
+	return pdf.Images(files, pdf.ImageOptions{
+		PageSize:    opts.Media,
+		Orientation: orientation,
+		Margin:      margin,
+		Scale:       opts.Scale,
+		PerPage:     opts.NumberUp,
+		Title:       opts.Title,
+	})
+}
+
+// textPDFOptions returns the page layout for text jobs. The request's
+// media, when set, picks the page size.
+func textPDFOptions(tc *config.TextPDFConfig, opts printer.PrintOptions) pdf.TextOptions {
+	pageSize := tc.PageSize
+	if opts.Media != "" {
+		pageSize = opts.Media
+	}
+	m := tc.Margin
+	return pdf.TextOptions{
+		PageSize:    pageSize,
+		Landscape:   tc.Landscape,
+		Margins:     pdf.Margins{Top: m, Right: m, Bottom: m, Left: m},
+		Font:        tc.Font,
+		FontSize:    tc.FontSize,
+		LineNumbers: tc.LineNumbers,
+		Header:      tc.Header,
+		Footer:      tc.Footer,
+		Title:       opts.Title,
+	}
+}
+
+// escposOptions returns the paper geometry, text encoding and text
+// rendering configured for the printer
+func escposOptions(printerName string) (escpos.Options, error) {
+	var opts escpos.Options
+	pc := config.GetConfig().FindPrinter(printerName)
+	if pc == nil {
+		return opts, nil
+	}
+
+	opts.Columns = pc.Columns
+	opts.DotWidth = pc.DotWidth
+	opts.BufferSize = pc.BufferSize
+	opts.RasterText = pc.RasterText
+	opts.Fonts = pc.Fonts
+	opts.FontSize = pc.FontSize
+	if pc.Encoding != "" {
+		cp, err := escpos.LookupCodePage(pc.Encoding)
+		if err != nil {
+			return opts, fmt.Errorf("printer %q: %w", printerName, err)
+		}
+		opts.CodePage = cp
+		opts.Substitute = escpos.DefaultSubstitute
+		if pc.Substitute != nil {
+			opts.Substitute = *pc.Substitute
+		}
+	}
+	return opts, nil
+}
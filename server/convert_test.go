@@ -0,0 +1,66 @@
+package server
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	"goprint-bridge/config"
+)
+
+// loadConfig loads config.yaml with the given content from a temp dir,
+// which is also the working directory for the rest of the test
+func loadConfig(t *testing.T, yaml string) *config.Config {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(yaml), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	return cfg
+}
+
+func TestEncodeText(t *testing.T) {
+	loadConfig(t, `
+printers:
+  - name: Counter
+    language: escpos
+    encoding: cp858
+  - name: Office
+    encoding: cp858
+  - name: Plain
+`)
+
+	tests := []struct {
+		printer string
+		content string
+		want    []byte
+	}{
+		// ESC/POS printers get the code page selected first
+		{printer: "Counter", content: "5 €", want: []byte{0x1B, 0x74, 19, '5', ' ', 0xD5}},
+		// Other printers only get the encoding
+		{printer: "Office", content: "5 €", want: []byte{'5', ' ', 0xD5}},
+		// Without an encoding raw data is sent byte for byte
+		{printer: "Plain", content: "5 €\x1B@\x00", want: []byte("5 €\x1B@\x00")},
+		{printer: "Unknown", content: "5 €\x1B@", want: []byte("5 €\x1B@")},
+	}
+	for _, tt := range tests {
+		got, err := encodeText(tt.printer, tt.content)
+		if err != nil {
+			t.Errorf("encodeText(%q) failed: %v", tt.printer, err)
+			continue
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("encodeText(%q) = % X, want % X", tt.printer, got, tt.want)
+		}
+	}
+}
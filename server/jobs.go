@@ -0,0 +1,381 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"goprint-bridge/jobstore"
+	"goprint-bridge/logger"
+	"goprint-bridge/printer"
+)
+
+// JobState represents the lifecycle state of a print job
+type JobState string
+
+const (
+	JobQueued    JobState = "queued"
+	JobSpooling  JobState = "spooling"
+	JobPrinting  JobState = "printing"
+	JobCompleted JobState = "completed"
+	JobFailed    JobState = "failed"
+	JobCancelled JobState = "cancelled"
+)
+
+const (
+	// maxQueuedJobs is the number of jobs that may wait for the worker
+	maxQueuedJobs = 256
+	// maxFinishedJobs is the number of finished jobs kept for status lookups
+	maxFinishedJobs = 500
+)
+
+// IsFinal returns whether the state is terminal
+func (s JobState) IsFinal() bool {
+	return s == JobCompleted || s == JobFailed || s == JobCancelled
+}
+
+// Job represents a print job tracked by the queue
+type Job struct {
+	ID        string               `json:"id"`
+	Type      string               `json:"type"`
+	Printer   string               `json:"printer"`
+	Options   printer.PrintOptions `json:"options"`
+	State     JobState             `json:"state"`
+	BackendID string               `json:"backend_job_id,omitempty"`
+	Pages     int                  `json:"pages_printed,omitempty"`
+	Error     string               `json:"error,omitempty"`
+	CreatedAt time.Time            `json:"created_at"`
+	UpdatedAt time.Time            `json:"updated_at"`
+
+	content string
+	tracked bool
+}
+
+// JobQueue runs print jobs one at a time in submission order
+type JobQueue struct {
+	mu       sync.Mutex
+	jobs     map[string]*Job
+	finished []string
+	pending  chan *Job
+	store    *jobstore.Store
+	process  func(q *JobQueue, job *Job) error
+	track    func(q *JobQueue, job *Job)
+	onUpdate func(job Job)
+}
+
+// NewJobQueue creates a queue, resumes unfinished jobs from the store and
+// starts the worker. store may be nil to keep jobs in memory only.
+// track is called for restored jobs that a backend is still printing.
+func NewJobQueue(store *jobstore.Store, process func(q *JobQueue, job *Job) error, track func(q *JobQueue, job *Job), onUpdate func(job Job)) *JobQueue {
+	q := &JobQueue{
+		jobs:     make(map[string]*Job),
+		pending:  make(chan *Job, maxQueuedJobs),
+		store:    store,
+		process:  process,
+		track:    track,
+		onUpdate: onUpdate,
+	}
+	if store != nil {
+		q.restore()
+	}
+	go q.run()
+	return q
+}
+
+// restore loads persisted jobs and requeues the ones that did not finish
+func (q *JobQueue) restore() {
+	resumed := 0
+	for _, r := range q.store.Records() {
+		job := &Job{
+			ID:        r.ID,
+			Type:      r.Type,
+			Printer:   r.Printer,
+			State:     JobState(r.State),
+			BackendID: r.BackendID,
+			Error:     r.Error,
+			CreatedAt: r.CreatedAt,
+			UpdatedAt: r.UpdatedAt,
+		}
+		if len(r.Options) > 0 {
+			json.Unmarshal(r.Options, &job.Options)
+		}
+		q.jobs[job.ID] = job
+
+		if job.State.IsFinal() {
+			q.finished = append(q.finished, job.ID)
+			continue
+		}
+
+		// Jobs the backend already accepted are followed, not printed again
+		if job.State == JobPrinting && job.BackendID != "" && q.track != nil {
+			job.tracked = true
+			q.track(q, job)
+			continue
+		}
+
+		// Jobs interrupted while spooling or printing start over
+		content, err := q.store.LoadPayload(job.ID)
+		if err != nil {
+			q.finishLocked(job, JobFailed, "payload lost after restart")
+			q.persist(*job)
+			continue
+		}
+
+		job.content = content
+		job.State = JobQueued
+		job.UpdatedAt = time.Now()
+		select {
+		case q.pending <- job:
+			q.persist(*job)
+			resumed++
+		default:
+			q.finishLocked(job, JobFailed, "print queue is full")
+			q.persist(*job)
+		}
+	}
+
+	if resumed > 0 {
+		logger.Info(fmt.Sprintf("Resumed %d unfinished print jobs", resumed))
+	}
+}
+
+// Enqueue adds a new job to the queue and returns a snapshot of it
+func (q *JobQueue) Enqueue(jobType, printerName string, opts printer.PrintOptions, content string) (Job, error) {
+	now := time.Now()
+	job := &Job{
+		ID:        newJobID(),
+		Type:      jobType,
+		Printer:   printerName,
+		Options:   opts,
+		State:     JobQueued,
+		CreatedAt: now,
+		UpdatedAt: now,
+		content:   content,
+	}
+
+	// Persist before queueing so an accepted job survives a crash
+	if q.store != nil {
+		if err := q.store.SavePayload(job.ID, content); err != nil {
+			return Job{}, err
+		}
+		if err := q.persist(*job); err != nil {
+			return Job{}, err
+		}
+	}
+
+	q.mu.Lock()
+	select {
+	case q.pending <- job:
+		q.jobs[job.ID] = job
+	default:
+		q.finishLocked(job, JobFailed, "print queue is full")
+		snapshot := *job
+		q.mu.Unlock()
+		q.persist(snapshot)
+		return Job{}, fmt.Errorf("print queue is full")
+	}
+	snapshot := *job
+	q.mu.Unlock()
+
+	q.notify(snapshot)
+	return snapshot, nil
+}
+
+// Get returns a snapshot of the job with the given ID
+func (q *JobQueue) Get(id string) (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// Cancel cancels a job that has not started yet
+func (q *JobQueue) Cancel(id string) (Job, error) {
+	q.mu.Lock()
+	job, ok := q.jobs[id]
+	if !ok {
+		q.mu.Unlock()
+		return Job{}, fmt.Errorf("job not found")
+	}
+	if job.State != JobQueued {
+		snapshot := *job
+		q.mu.Unlock()
+		return snapshot, fmt.Errorf("job is already %s", job.State)
+	}
+	q.finishLocked(job, JobCancelled, "")
+	snapshot := *job
+	q.mu.Unlock()
+
+	q.update(snapshot)
+	return snapshot, nil
+}
+
+// SetState moves a running job to the given state
+func (q *JobQueue) SetState(job *Job, state JobState) {
+	q.mu.Lock()
+	job.State = state
+	job.UpdatedAt = time.Now()
+	snapshot := *job
+	q.mu.Unlock()
+
+	q.update(snapshot)
+}
+
+// SetBackendJobID records the ID the printer backend gave the job
+func (q *JobQueue) SetBackendJobID(job *Job, backendID string) {
+	if backendID == "" {
+		return
+	}
+
+	q.mu.Lock()
+	job.BackendID = backendID
+	job.UpdatedAt = time.Now()
+	snapshot := *job
+	q.mu.Unlock()
+
+	q.update(snapshot)
+}
+
+// Track marks a job as still printing after process returns. The job is
+// left in the printing state until Finish is called.
+func (q *JobQueue) Track(job *Job) {
+	q.mu.Lock()
+	job.tracked = true
+	q.mu.Unlock()
+}
+
+// SetPages records the number of pages the backend reports as printed
+func (q *JobQueue) SetPages(job *Job, pages int) {
+	q.mu.Lock()
+	if job.Pages == pages {
+		q.mu.Unlock()
+		return
+	}
+	job.Pages = pages
+	job.UpdatedAt = time.Now()
+	snapshot := *job
+	q.mu.Unlock()
+
+	q.update(snapshot)
+}
+
+// Finish moves a tracked job to a final state
+func (q *JobQueue) Finish(job *Job, state JobState, errMsg string) {
+	q.mu.Lock()
+	if job.State.IsFinal() {
+		q.mu.Unlock()
+		return
+	}
+	q.finishLocked(job, state, errMsg)
+	snapshot := *job
+	q.mu.Unlock()
+
+	q.update(snapshot)
+}
+
+// run processes queued jobs until the process exits
+func (q *JobQueue) run() {
+	for job := range q.pending {
+		q.mu.Lock()
+		if job.State != JobQueued {
+			// Cancelled while waiting
+			q.mu.Unlock()
+			continue
+		}
+		q.mu.Unlock()
+
+		q.SetState(job, JobSpooling)
+		err := q.process(q, job)
+
+		q.mu.Lock()
+		if err == nil && job.tracked {
+			// The tracker finishes the job when the backend does
+			q.mu.Unlock()
+			continue
+		}
+		if err != nil {
+			q.finishLocked(job, JobFailed, err.Error())
+		} else {
+			q.finishLocked(job, JobCompleted, "")
+		}
+		snapshot := *job
+		q.mu.Unlock()
+
+		q.update(snapshot)
+	}
+}
+
+// finishLocked marks a job as final and prunes old finished jobs.
+// The caller must hold q.mu.
+func (q *JobQueue) finishLocked(job *Job, state JobState, errMsg string) {
+	job.State = state
+	job.Error = errMsg
+	job.UpdatedAt = time.Now()
+	job.content = ""
+
+	q.finished = append(q.finished, job.ID)
+	if len(q.finished) > maxFinishedJobs {
+		for _, id := range q.finished[:len(q.finished)-maxFinishedJobs] {
+			delete(q.jobs, id)
+		}
+		q.finished = append([]string(nil), q.finished[len(q.finished)-maxFinishedJobs:]...)
+	}
+}
+
+// update persists a job change and reports it
+func (q *JobQueue) update(job Job) {
+	q.persist(job)
+	q.notify(job)
+}
+
+// persist writes the job state to the store
+func (q *JobQueue) persist(job Job) error {
+	if q.store == nil {
+		return nil
+	}
+
+	options, err := json.Marshal(job.Options)
+	if err != nil {
+		return err
+	}
+
+	err = q.store.Save(jobstore.Record{
+		ID:        job.ID,
+		Type:      job.Type,
+		Printer:   job.Printer,
+		Options:   options,
+		State:     string(job.State),
+		BackendID: job.BackendID,
+		Final:     job.State.IsFinal(),
+		Error:     job.Error,
+		CreatedAt: job.CreatedAt,
+		UpdatedAt: job.UpdatedAt,
+	})
+	if err != nil {
+		logger.Error("Failed to persist print job", err)
+	}
+	return err
+}
+
+// notify reports a job change to the update callback
+func (q *JobQueue) notify(job Job) {
+	if q.onUpdate != nil {
+		q.onUpdate(job)
+	}
+}
+
+// newJobID generates a random job identifier
+func newJobID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%016x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,237 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/wailsapp/wails/v3/pkg/application"
+
+	"goprint-bridge/config"
+	"goprint-bridge/logger"
+)
+
+// Operator decisions on a new browser origin
+const (
+	OriginAllowOnce   = "once"   // Allowed until the bridge restarts
+	OriginAllowAlways = "always" // Saved as allowed
+	OriginDeny        = "never"  // Saved as denied
+)
+
+// originApprovalTimeout is how long a request from a new origin is held
+// while the operator decides
+var originApprovalTimeout = 60 * time.Second
+
+// Error codes returned for browser origins
+const (
+	errOriginDenied      = "origin_denied"
+	errOriginNotApproved = "origin_not_approved"
+)
+
+// originApprovals holds requests from browser origins the operator hasn't
+// decided on yet, and asks the operator about them
+type originApprovals struct {
+	wailsApp *application.App
+	mu       sync.Mutex
+	session  map[string]bool          // Origins allowed once
+	pending  map[string]chan struct{} // Closed when the operator decides
+}
+
+func newOriginApprovals(wailsApp *application.App) *originApprovals {
+	return &originApprovals{
+		wailsApp: wailsApp,
+		session:  make(map[string]bool),
+		pending:  make(map[string]chan struct{}),
+	}
+}
+
+// nullOrigin is sent by pages without an origin (file://, sandboxed
+// frames). Any such page sends it, so it can only be allowed once.
+const nullOrigin = "null"
+
+// normalizeOrigin lower-cases an Origin header and checks that it is a
+// scheme and host, or the null origin
+func normalizeOrigin(origin string) (string, error) {
+	if origin == nullOrigin {
+		return origin, nil
+	}
+	u, err := url.Parse(strings.ToLower(strings.TrimSpace(origin)))
+	if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+		return "", fmt.Errorf("invalid origin %q", origin)
+	}
+	return u.Scheme + "://" + u.Host, nil
+}
+
+// decision returns whether an origin is allowed, and whether the operator
+// has decided on it at all
+func (o *originApprovals) decision(origin string) (allowed bool, known bool) {
+	// A saved allow for the null origin would let every local file print
+	if saved := config.GetConfig().FindOrigin(origin); saved != nil && !(origin == nullOrigin && saved.Allow) {
+		return saved.Allow, true
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.session[origin] {
+		return true, true
+	}
+	return false, false
+}
+
+// allowed reports whether CORS may answer the origin
+func (o *originApprovals) allowed(origin string) bool {
+	origin, err := normalizeOrigin(origin)
+	if err != nil {
+		return false
+	}
+	allowed, _ := o.decision(origin)
+	return allowed
+}
+
+// check lets requests from allowed origins through, rejects denied ones
+// and holds requests from new origins until the operator decides.
+// Requests without an Origin header don't come from a browser page.
+func (o *originApprovals) check(c *fiber.Ctx) error {
+	header := c.Get(fiber.HeaderOrigin)
+	if header == "" {
+		return c.Next()
+	}
+	origin, err := normalizeOrigin(header)
+	if err != nil {
+		return deny(c, 403, errOriginDenied, "Invalid origin")
+	}
+
+	allowed, known := o.decision(origin)
+	if !known {
+		allowed, known = o.wait(origin)
+	}
+	switch {
+	case allowed:
+		return c.Next()
+	case known:
+		return deny(c, 403, errOriginDenied, fmt.Sprintf("Origin not allowed: %s", origin))
+	default:
+		return deny(c, 403, errOriginNotApproved, fmt.Sprintf("Origin is waiting for approval: %s", origin))
+	}
+}
+
+// wait asks the operator about a new origin, once for all the requests
+// that arrive while the question is open, and returns the decision
+func (o *originApprovals) wait(origin string) (allowed bool, known bool) {
+	o.mu.Lock()
+	done, asked := o.pending[origin]
+	if !asked {
+		done = make(chan struct{})
+		o.pending[origin] = done
+	}
+	o.mu.Unlock()
+
+	if !asked {
+		logger.Info(fmt.Sprintf("Origin %s is waiting for approval", origin))
+		if o.wailsApp != nil {
+			o.wailsApp.Event.Emit("origin-approval", map[string]interface{}{
+				"origin": origin,
+				"time":   time.Now().Format(time.RFC3339),
+			})
+		}
+	}
+
+	select {
+	case <-done:
+	case <-time.After(originApprovalTimeout):
+		// Ask again on the next request
+		o.mu.Lock()
+		if o.pending[origin] == done {
+			delete(o.pending, origin)
+		}
+		o.mu.Unlock()
+	}
+	return o.decision(origin)
+}
+
+// respond records the operator's decision and releases the held requests.
+// A decision can also be given after the requests timed out.
+func (o *originApprovals) respond(origin string, decision string) error {
+	origin, err := normalizeOrigin(origin)
+	if err != nil {
+		return err
+	}
+
+	if origin == nullOrigin && decision == OriginAllowAlways {
+		return fmt.Errorf("pages without an origin can only be allowed once")
+	}
+
+	switch decision {
+	case OriginAllowOnce:
+		o.mu.Lock()
+		o.session[origin] = true
+		o.mu.Unlock()
+	case OriginAllowAlways, OriginDeny:
+		if err := config.SetOrigin(origin, decision == OriginAllowAlways); err != nil {
+			return fmt.Errorf("failed to save origin: %w", err)
+		}
+	default:
+		return fmt.Errorf("invalid decision %q, must be once, always or never", decision)
+	}
+	logger.Info(fmt.Sprintf("Origin %s: %s", origin, decision))
+
+	o.mu.Lock()
+	if done, ok := o.pending[origin]; ok {
+		close(done)
+		delete(o.pending, origin)
+	}
+	o.mu.Unlock()
+	return nil
+}
+
+// forget drops the decisions on an origin, so it is asked about again
+func (o *originApprovals) forget(origin string) error {
+	origin, err := normalizeOrigin(origin)
+	if err != nil {
+		return err
+	}
+	o.mu.Lock()
+	once := o.session[origin]
+	delete(o.session, origin)
+	o.mu.Unlock()
+
+	if config.GetConfig().FindOrigin(origin) == nil {
+		if once {
+			return nil
+		}
+		return fmt.Errorf("origin %q not found", origin)
+	}
+	return config.RemoveOrigin(origin)
+}
+
+// waiting returns the origins held for a decision
+func (o *originApprovals) waiting() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	origins := make([]string, 0, len(o.pending))
+	for origin := range o.pending {
+		origins = append(origins, origin)
+	}
+	sort.Strings(origins)
+	return origins
+}
+
+// RespondOrigin records the operator's decision on a browser origin:
+// once, always or never
+func (s *Server) RespondOrigin(origin string, decision string) error {
+	return s.origins.respond(origin, decision)
+}
+
+// ForgetOrigin removes a browser origin from the allowlist, so it is
+// asked about again
+func (s *Server) ForgetOrigin(origin string) error {
+	return s.origins.forget(origin)
+}
+
+// PendingOrigins returns the browser origins waiting for a decision
+func (s *Server) PendingOrigins() []string {
+	return s.origins.waiting()
+}
@@ -0,0 +1,241 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"goprint-bridge/config"
+)
+
+// originApp answers every request that the origin check lets through
+func originApp(o *originApprovals) *fiber.App {
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use(o.check)
+	app.Get("/printers", func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+	return app
+}
+
+// originRequest sends a request from a browser origin and returns its status
+func originRequest(t *testing.T, app *fiber.App, origin string) int {
+	req := httptest.NewRequest("GET", "/printers", nil)
+	req.Header.Set(fiber.HeaderOrigin, origin)
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Errorf("request from %s failed: %v", origin, err)
+		return 0
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// heldRequests sends requests from an origin in the background and waits
+// until the operator is asked about it
+func heldRequests(t *testing.T, o *originApprovals, app *fiber.App, origin string, n int) chan int {
+	t.Helper()
+	statuses := make(chan int, n)
+	for i := 0; i < n; i++ {
+		go func() { statuses <- originRequest(t, app, origin) }()
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if pending := o.waiting(); len(pending) == 1 && pending[0] == origin {
+			return statuses
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s never waited for approval, pending = %v", origin, o.waiting())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestOriginDecisions(t *testing.T) {
+	tests := []struct {
+		decision string
+		status   int
+		saved    *config.OriginConfig
+	}{
+		{decision: OriginAllowOnce, status: 200},
+		{decision: OriginAllowAlways, status: 200, saved: &config.OriginConfig{Origin: "https://shop.example", Allow: true}},
+		{decision: OriginDeny, status: 403, saved: &config.OriginConfig{Origin: "https://shop.example"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.decision, func(t *testing.T) {
+			loadConfig(t, "port: 9999\n")
+			o := newOriginApprovals(nil)
+			app := originApp(o)
+
+			// Requests arriving while the question is open share it
+			statuses := heldRequests(t, o, app, "https://shop.example", 2)
+			time.Sleep(10 * time.Millisecond)
+			if err := o.respond("HTTPS://Shop.Example", tt.decision); err != nil {
+				t.Fatalf("respond failed: %v", err)
+			}
+			for i := 0; i < 2; i++ {
+				if status := <-statuses; status != tt.status {
+					t.Errorf("held request = %d, want %d", status, tt.status)
+				}
+			}
+			if pending := o.waiting(); len(pending) != 0 {
+				t.Errorf("pending = %v after the decision", pending)
+			}
+
+			// Later requests get the decision without waiting
+			if status := originRequest(t, app, "https://shop.example"); status != tt.status {
+				t.Errorf("next request = %d, want %d", status, tt.status)
+			}
+
+			saved := config.GetConfig().FindOrigin("https://shop.example")
+			switch {
+			case tt.saved == nil && saved != nil:
+				t.Errorf("saved %+v, want nothing saved", *saved)
+			case tt.saved != nil && (saved == nil || *saved != *tt.saved):
+				t.Errorf("saved %v, want %+v", saved, *tt.saved)
+			}
+
+			// Once lasts until a restart, the others are remembered
+			allowed, known := newOriginApprovals(nil).decision("https://shop.example")
+			if known != (tt.saved != nil) || allowed != (tt.saved != nil && tt.saved.Allow) {
+				t.Errorf("after a restart allowed = %v, known = %v", allowed, known)
+			}
+		})
+	}
+}
+
+func TestOriginTimeout(t *testing.T) {
+	loadConfig(t, "port: 9999\n")
+	defer func(d time.Duration) { originApprovalTimeout = d }(originApprovalTimeout)
+	originApprovalTimeout = 50 * time.Millisecond
+
+	o := newOriginApprovals(nil)
+	app := originApp(o)
+	start := time.Now()
+	if status := originRequest(t, app, "https://shop.example"); status != 403 {
+		t.Errorf("status = %d, want 403", status)
+	}
+	if elapsed := time.Since(start); elapsed < originApprovalTimeout {
+		t.Errorf("request answered after %v, want it held for %v", elapsed, originApprovalTimeout)
+	}
+	if pending := o.waiting(); len(pending) != 0 {
+		t.Errorf("pending = %v after the timeout, want the next request to ask again", pending)
+	}
+
+	// A decision after the timeout still counts
+	if err := o.respond("https://shop.example", OriginAllowOnce); err != nil {
+		t.Fatalf("respond failed: %v", err)
+	}
+	if status := originRequest(t, app, "https://shop.example"); status != 200 {
+		t.Errorf("status = %d after allowing, want 200", status)
+	}
+}
+
+func TestOriginNull(t *testing.T) {
+	// A saved allow for the null origin is ignored, a saved deny holds
+	loadConfig(t, `
+origins:
+  - origin: "null"
+    allow: true
+`)
+	o := newOriginApprovals(nil)
+	if _, known := o.decision(nullOrigin); known {
+		t.Error("a saved allow for the null origin was used")
+	}
+	if err := o.respond(nullOrigin, OriginAllowAlways); err == nil {
+		t.Error("the null origin was allowed always")
+	}
+
+	app := originApp(o)
+	statuses := heldRequests(t, o, app, nullOrigin, 1)
+	if err := o.respond(nullOrigin, OriginAllowOnce); err != nil {
+		t.Fatalf("respond failed: %v", err)
+	}
+	if status := <-statuses; status != 200 {
+		t.Errorf("status = %d, want 200", status)
+	}
+
+	if err := o.respond(nullOrigin, OriginDeny); err != nil {
+		t.Fatalf("respond failed: %v", err)
+	}
+	if allowed, known := newOriginApprovals(nil).decision(nullOrigin); allowed || !known {
+		t.Errorf("after denying allowed = %v, known = %v, want a saved deny", allowed, known)
+	}
+}
+
+func TestOriginWait(t *testing.T) {
+	loadConfig(t, "port: 9999\n")
+	o := newOriginApprovals(nil)
+
+	type result struct{ allowed, known bool }
+	results := make(chan result, 1)
+	go func() {
+		allowed, known := o.wait("https://shop.example")
+		results <- result{allowed, known}
+	}()
+
+	// The operator's decision closes the channel the request waits on
+	var done chan struct{}
+	for done == nil {
+		o.mu.Lock()
+		done = o.pending["https://shop.example"]
+		o.mu.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+	if err := o.respond("https://shop.example", "maybe"); err == nil {
+		t.Error("respond accepted an invalid decision")
+	}
+	select {
+	case <-done:
+		t.Fatal("an invalid decision released the request")
+	default:
+	}
+
+	if err := o.respond("https://shop.example", OriginAllowOnce); err != nil {
+		t.Fatalf("respond failed: %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the decision didn't close the channel")
+	}
+	if r := <-results; !r.allowed || !r.known {
+		t.Errorf("wait = %+v, want allowed", r)
+	}
+
+	// Forgetting asks again
+	if err := o.forget("https://shop.example"); err != nil {
+		t.Fatalf("forget failed: %v", err)
+	}
+	if _, known := o.decision("https://shop.example"); known {
+		t.Error("origin still decided after forget")
+	}
+}
+
+func TestNormalizeOrigin(t *testing.T) {
+	tests := []struct {
+		origin string
+		want   string
+	}{
+		{origin: "https://Shop.Example", want: "https://shop.example"},
+		{origin: "http://localhost:3000/", want: "http://localhost:3000"},
+		{origin: "null", want: "null"},
+		{origin: "shop.example"},
+		{origin: "https://shop.example/path"},
+	}
+	for _, tt := range tests {
+		got, err := normalizeOrigin(tt.origin)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("normalizeOrigin(%q) = %q, want an error", tt.origin, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("normalizeOrigin(%q) = %q, %v, want %q", tt.origin, got, err, tt.want)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"fmt"
+	"path/filepath"
 	"sync"
 	"time"
 
@@ -9,34 +10,83 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/wailsapp/wails/v3/pkg/application"
 
+	"goprint-bridge/auth"
 	"goprint-bridge/config"
+	"goprint-bridge/jobstore"
+	"goprint-bridge/label"
 	"goprint-bridge/logger"
 	"goprint-bridge/printer"
+	"goprint-bridge/templates"
 )
 
 // PrintRequest represents incoming print data
 type PrintRequest struct {
-	Type    string `json:"type"`    // text, pdf, image, etc.
-	Content string `json:"content"` // Base64 encoded content or raw text
+	Type    string               `json:"type"`    // text, pdf, image, etc.
+	Content string               `json:"content"` // Base64 encoded content or raw text
+	Printer string               `json:"printer"` // Optional, defaults to the selected printer
+	Copies  int                  `json:"copies"`  // Optional, defaults to 1
+	Title   string               `json:"title"`   // Optional job title shown in the spooler
+	Options printer.PrintOptions `json:"options"` // Optional copies, duplex, media, etc.
+
+	// Label templates (zpl)
+	Data     map[string]interface{}   `json:"data"`     // Values for {{variable}} placeholders
+	Serial   *label.Serial            `json:"serial"`   // Optional, one label per copy with an incrementing number
+	Graphics map[string]label.Graphic `json:"graphics"` // Images for {{name}} placeholders, as ^GF fields
+
+	// Several images in one job (image)
+	Images []string `json:"images"` // Base64 images, printed after content
+}
+
+// TemplateRequest represents an incoming request to print a named template
+type TemplateRequest struct {
+	Data    map[string]interface{} `json:"data"`    // Template data
+	Printer string                 `json:"printer"` // Optional, defaults to the selected printer
+	Copies  int                    `json:"copies"`  // Optional, defaults to 1
+	Title   string                 `json:"title"`   // Optional job title shown in the spooler
+	Options printer.PrintOptions   `json:"options"` // Optional copies, duplex, media, etc.
 }
 
 // PrintResponse represents the API response
 type PrintResponse struct {
 	Success bool   `json:"success"`
 	Message string `json:"message"`
+	Error   string `json:"error,omitempty"` // Machine readable code for access errors
+	JobID   string `json:"job_id,omitempty"`
 }
 
 // Server holds the Fiber server instance
 type Server struct {
-	app      *fiber.App
-	wailsApp *application.App
-	mu       sync.Mutex
-	running  bool
-	port     int
+	app       *fiber.App
+	wailsApp  *application.App
+	queue     *JobQueue
+	templates *templates.Store
+	origins   *originApprovals
+	nonces    *auth.NonceCache
+	mu        sync.Mutex
+	running   bool
+	port      int
 }
 
 var serverInstance *Server
 
+// jobStoreDir is where queued jobs are persisted, next to config.yaml
+const jobStoreDir = "storage/jobs"
+
+// nonceFile keeps the nonces of used job tickets, next to the job store
+const nonceFile = "storage/nonces.log"
+
+// templatesDir holds the print templates, next to config.yaml
+const templatesDir = "templates"
+
+const (
+	// trackInterval is how often a printing job's backend status is polled
+	trackInterval = 2 * time.Second
+	// maxTrackDuration is how long a job may stay on the printer
+	maxTrackDuration = time.Hour
+	// maxTrackFailures is the number of failed status polls tolerated in a row
+	maxTrackFailures = 5
+)
+
 // NewServer creates a new server instance
 func NewServer(wailsApp *application.App) *Server {
 	if serverInstance != nil {
@@ -49,18 +99,31 @@ func NewServer(wailsApp *application.App) *Server {
 		WriteTimeout:          10 * time.Second,
 	})
 
-	// CORS middleware - allow all origins for kiosk compatibility
+	// Browser origins need the operator's approval, asked for on first use
+	origins := newOriginApprovals(wailsApp)
+	app.Use(origins.check)
+	app.Use(allowPrivateNetwork)
+
+	// CORS middleware - answers only the approved origins
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
-		AllowMethods: "GET,POST,OPTIONS",
-		AllowHeaders: "Origin, Content-Type, Accept",
+		AllowOriginsFunc: origins.allowed,
+		AllowMethods:     "GET,POST,DELETE,OPTIONS",
+		AllowHeaders:     "Origin, Content-Type, Accept, " + apiKeyHeader + ", " + ticketHeader,
 	}))
 
+	// Client certificates, then API keys once configured
+	app.Use(identifyClient)
+	app.Use(authenticate)
+
 	serverInstance = &Server{
 		app:      app,
 		wailsApp: wailsApp,
+		origins:  origins,
+		nonces:   openNonceCache(),
 		running:  false,
 	}
+	serverInstance.queue = NewJobQueue(openJobStore(), serverInstance.processJob, serverInstance.trackJob, serverInstance.jobUpdated)
+	serverInstance.templates = openTemplates()
 
 	// Setup routes
 	serverInstance.setupRoutes()
@@ -78,7 +141,7 @@ func (s *Server) setupRoutes() {
 		})
 	})
 
-	// Print endpoint
+	// Print endpoint - queues the job and returns immediately
 	s.app.Post("/print", func(c *fiber.Ctx) error {
 		var req PrintRequest
 
@@ -91,76 +154,424 @@ func (s *Server) setupRoutes() {
 			})
 		}
 
-		// Validate request
-		if req.Type == "" || req.Content == "" {
+		return s.enqueue(c, req)
+	})
+
+	// Template endpoint - renders a named template with the request data
+	// and queues the result like /print
+	s.app.Post("/print/template/:name", func(c *fiber.Ctx) error {
+		var req TemplateRequest
+		if err := c.BodyParser(&req); err != nil {
+			logger.PrintError("Failed to parse template request", err)
 			return c.Status(400).JSON(PrintResponse{
 				Success: false,
-				Message: "Missing required fields: type and content",
+				Message: "Invalid JSON payload",
 			})
 		}
 
-		// Get selected printer from config
-		cfg := config.GetConfig()
-		printerName := cfg.SelectedPrinter
+		name := c.Params("name")
+		t, ok := s.templates.Get(name)
+		if !ok {
+			return c.Status(404).JSON(PrintResponse{
+				Success: false,
+				Message: fmt.Sprintf("Template not found: %s", name),
+			})
+		}
+
+		printReq, err := renderTemplate(t, req)
+		if err != nil {
+			return c.Status(400).JSON(PrintResponse{
+				Success: false,
+				Message: fmt.Sprintf("Template error: %s", err.Error()),
+			})
+		}
+		return s.enqueue(c, printReq)
+	})
 
-		// Log the request
-		logger.PrintRequest(req.Type, len(req.Content), c.IP())
+	// Template list endpoint
+	s.app.Get("/templates", func(c *fiber.Ctx) error {
+		return c.JSON(s.templates.List())
+	})
 
-		// Emit event to frontend (before printing)
-		if s.wailsApp != nil {
-			s.wailsApp.Event.Emit("print-received", map[string]interface{}{
-				"type":    req.Type,
-				"content": req.Content,
-				"time":    time.Now().Format(time.RFC3339),
-				"printer": printerName,
+	// Printer list endpoint
+	s.app.Get("/printers", func(c *fiber.Ctx) error {
+		printers, err := printer.List()
+		if err != nil {
+			logger.PrintError("Failed to list printers", err)
+			return c.Status(503).JSON(PrintResponse{
+				Success: false,
+				Message: "Failed to list printers",
 			})
 		}
 
-		// Process print job based on type
-		var printErr error
-		switch req.Type {
-		case "pdf":
-			// PDF: decode base64 and print silently
-			printErr = printer.PrintPDF(printerName, req.Content)
-		case "text", "raw":
-			// Raw text: send directly to printer
-			printErr = printer.PrintRaw(printerName, req.Content)
-		default:
-			// Default: treat as raw text
-			printErr = printer.PrintRaw(printerName, req.Content)
-		}
-
-		if printErr != nil {
-			logger.PrintError("Print job failed", printErr)
-
-			// Emit error event
-			if s.wailsApp != nil {
-				s.wailsApp.Event.Emit("print-error", map[string]interface{}{
-					"error": printErr.Error(),
-					"time":  time.Now().Format(time.RFC3339),
-				})
+		// Clients limited to some printers only see those
+		allowed := make([]printer.Printer, 0, len(printers))
+		for _, p := range printers {
+			if allowsPrinter(c, p.Name) {
+				allowed = append(allowed, p)
 			}
+		}
+		return c.JSON(allowed)
+	})
 
+	// Printer details endpoint
+	s.app.Get("/printers/:name", func(c *fiber.Ctx) error {
+		if !allowsPrinter(c, c.Params("name")) {
+			return deny(c, 403, errPrinterNotAllowed, fmt.Sprintf("Client may not use %s", c.Params("name")))
+		}
+		p, found, err := printer.Find(c.Params("name"))
+		if err != nil {
+			logger.PrintError("Failed to list printers", err)
+			return c.Status(503).JSON(PrintResponse{
+				Success: false,
+				Message: "Failed to list printers",
+			})
+		}
+		if !found {
+			return c.Status(404).JSON(PrintResponse{
+				Success: false,
+				Message: fmt.Sprintf("Printer not found: %s", c.Params("name")),
+			})
+		}
+
+		backend, err := printer.Resolve(p.Name)
+		if err != nil {
 			return c.Status(500).JSON(PrintResponse{
 				Success: false,
-				Message: fmt.Sprintf("Print failed: %s", printErr.Error()),
+				Message: err.Error(),
 			})
 		}
+		caps, err := backend.Capabilities(p.Name)
+		if err != nil {
+			return c.Status(500).JSON(PrintResponse{
+				Success: false,
+				Message: fmt.Sprintf("Failed to get capabilities: %s", err.Error()),
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"printer":      p,
+			"capabilities": caps,
+		})
+	})
+
+	// Job status endpoint
+	s.app.Get("/jobs/:id", func(c *fiber.Ctx) error {
+		job, ok := s.queue.Get(c.Params("id"))
+		if !ok {
+			return c.Status(404).JSON(PrintResponse{
+				Success: false,
+				Message: "Job not found",
+			})
+		}
+		if !allowsPrinter(c, job.Printer) {
+			return deny(c, 403, errPrinterNotAllowed, fmt.Sprintf("Client may not use %s", job.Printer))
+		}
+		return c.JSON(job)
+	})
+
+	// Job cancel endpoint - only jobs that have not started can be cancelled
+	s.app.Delete("/jobs/:id", func(c *fiber.Ctx) error {
+		if job, ok := s.queue.Get(c.Params("id")); ok && !allowsPrinter(c, job.Printer) {
+			return deny(c, 403, errPrinterNotAllowed, fmt.Sprintf("Client may not use %s", job.Printer))
+		}
+
+		job, err := s.queue.Cancel(c.Params("id"))
+		if err != nil && job.State == JobPrinting && job.BackendID != "" {
+			// Already with the printer, ask the backend to cancel it
+			backend, resolveErr := printer.Resolve(job.Printer)
+			if resolveErr == nil {
+				err = backend.Cancel(job.Printer, job.BackendID)
+			}
+			if resolveErr == nil && err == nil {
+				return c.Status(202).JSON(PrintResponse{
+					Success: true,
+					Message: "Cancel requested",
+					JobID:   job.ID,
+				})
+			}
+			if resolveErr != nil {
+				err = resolveErr
+			}
+		}
+		if err != nil {
+			status := 409
+			if job.ID == "" {
+				status = 404
+			}
+			return c.Status(status).JSON(PrintResponse{
+				Success: false,
+				Message: fmt.Sprintf("Cancel failed: %s", err.Error()),
+				JobID:   job.ID,
+			})
+		}
+		return c.JSON(job)
+	})
+}
+
+// enqueue validates a print request and queues it
+func (s *Server) enqueue(c *fiber.Ctx, req PrintRequest) error {
+	// Validate request
+	if req.Type == "" || (req.Content == "" && len(req.Images) == 0) {
+		return c.Status(400).JSON(PrintResponse{
+			Success: false,
+			Message: "Missing required fields: type and content",
+		})
+	}
+
+	// Use the requested printer, falling back to the selected printer
+	printerName := req.Printer
+	if printerName == "" {
+		printerName = config.GetConfig().SelectedPrinter
+	} else {
+		found, err := printer.Exists(printerName)
+		if err != nil {
+			logger.PrintError("Failed to list printers", err)
+			return c.Status(503).JSON(PrintResponse{
+				Success: false,
+				Message: "Failed to list printers",
+			})
+		}
+		if !found {
+			return c.Status(404).JSON(PrintResponse{
+				Success: false,
+				Message: fmt.Sprintf("Printer not found: %s", printerName),
+			})
+		}
+	}
 
-		// Emit success event
+	// API keys and client certificates may be limited to some printers and types
+	if !allowsPrinter(c, printerName) {
+		return deny(c, 403, errPrinterNotAllowed, fmt.Sprintf("Client may not print to %s", printerName))
+	}
+	for _, sc := range scopes(c) {
+		if !sc.AllowsType(req.Type) {
+			return deny(c, 403, errTypeNotAllowed, fmt.Sprintf("Client may not print %s jobs", req.Type))
+		}
+	}
+
+	// Top-level title and copies take precedence over the options object
+	opts := req.Options
+	if req.Title != "" {
+		opts.Title = req.Title
+	}
+	if req.Copies != 0 {
+		opts.Copies = req.Copies
+	}
+	if err := opts.Validate(); err != nil {
+		return c.Status(400).JSON(PrintResponse{
+			Success: false,
+			Message: fmt.Sprintf("Invalid print options: %s", err.Error()),
+		})
+	}
+
+	// Serial labels move the copies into the content, a ticket has to
+	// allow every label
+	copies := opts.Copies
+	if req.Type == "zpl" && req.Serial != nil {
+		copies = serialLabels(req.Serial, opts)
+	}
+	content, err := prepareContent(&req, &opts)
+	if err != nil {
+		return c.Status(400).JSON(PrintResponse{
+			Success: false,
+			Message: fmt.Sprintf("Invalid content: %s", err.Error()),
+		})
+	}
+
+	// A job ticket from the client's backend, once ticket keys are configured
+	if te := s.checkTicket(c, printerName, copies); te != nil {
+		return deny(c, te.status, te.code, te.message)
+	}
+
+	// Log the request
+	logger.PrintRequest(req.Type, len(content), c.IP(), clientName(c), identityName(c))
+
+	job, err := s.queue.Enqueue(req.Type, printerName, opts, content)
+	if err != nil {
+		logger.PrintError("Failed to queue print job", err)
+		return c.Status(503).JSON(PrintResponse{
+			Success: false,
+			Message: fmt.Sprintf("Print failed: %s", err.Error()),
+		})
+	}
+
+	// Emit event to frontend (before printing)
+	if s.wailsApp != nil {
+		s.wailsApp.Event.Emit("print-received", map[string]interface{}{
+			"job_id":   job.ID,
+			"type":     req.Type,
+			"content":  req.Content,
+			"time":     time.Now().Format(time.RFC3339),
+			"printer":  printerName,
+			"client":   clientName(c),
+			"identity": identityName(c),
+		})
+	}
+
+	return c.Status(202).JSON(PrintResponse{
+		Success: true,
+		Message: "Print job queued",
+		JobID:   job.ID,
+	})
+}
+
+// openJobStore opens the persistent job store and removes temp files left
+// by a previous run. Jobs are kept in memory only if the store can't be opened.
+func openJobStore() *jobstore.Store {
+	printer.CleanupTempFiles()
+
+	store, err := jobstore.Open(filepath.Join(config.Dir(), jobStoreDir), maxFinishedJobs)
+	if err != nil {
+		logger.Error("Failed to open job store, jobs will not survive restarts", err)
+		return nil
+	}
+	return store
+}
+
+// openNonceCache opens the used ticket nonces, so tickets can't be
+// replayed after a restart. They are kept in memory only if the file
+// can't be opened.
+func openNonceCache() *auth.NonceCache {
+	nonces, err := auth.OpenNonceCache(filepath.Join(config.Dir(), nonceFile), time.Now())
+	if err != nil {
+		logger.Error("Failed to open ticket nonces, used tickets will be forgotten on restart", err)
+		return auth.NewNonceCache()
+	}
+	return nonces
+}
+
+// openTemplates loads the templates next to config.yaml and watches them
+// for changes
+func openTemplates() *templates.Store {
+	store, err := templates.Open(filepath.Join(config.Dir(), templatesDir))
+	if err != nil {
+		logger.Error("Failed to open templates", err)
+	}
+	return store
+}
+
+// processJob sends a queued job to the printer and reports the outcome
+func (s *Server) processJob(q *JobQueue, job *Job) error {
+	if printErr := s.printJob(q, job); printErr != nil {
+		logger.PrintError("Print job failed", printErr)
+
+		// Emit error event
 		if s.wailsApp != nil {
-			s.wailsApp.Event.Emit("print-success", map[string]interface{}{
-				"type":    req.Type,
-				"printer": printerName,
-				"time":    time.Now().Format(time.RFC3339),
+			s.wailsApp.Event.Emit("print-error", map[string]interface{}{
+				"job_id": job.ID,
+				"error":  printErr.Error(),
+				"time":   time.Now().Format(time.RFC3339),
 			})
 		}
+		return printErr
+	}
 
-		return c.JSON(PrintResponse{
-			Success: true,
-			Message: "Print job completed",
+	// Emit success event
+	if s.wailsApp != nil {
+		s.wailsApp.Event.Emit("print-success", map[string]interface{}{
+			"job_id":  job.ID,
+			"type":    job.Type,
+			"printer": job.Printer,
+			"time":    time.Now().Format(time.RFC3339),
 		})
+	}
+	return nil
+}
+
+// printJob converts the job content and submits it to the printer's backend
+func (s *Server) printJob(q *JobQueue, job *Job) error {
+	backend, err := printer.Resolve(job.Printer)
+	if err != nil {
+		return err
+	}
+
+	format, data, err := convertContent(job)
+	if err != nil {
+		return err
+	}
+
+	opts := job.Options
+	if job.Type == "image" && format == printer.FormatPDF {
+		// The page orientation is already part of the PDF
+		opts.Orientation = ""
+	}
+
+	q.SetState(job, JobPrinting)
+	backendJobID, err := backend.Submit(printer.Job{
+		ID:      job.ID,
+		Printer: job.Printer,
+		Format:  format,
+		Data:    data,
+		Options: opts,
 	})
+	if err != nil {
+		return err
+	}
+
+	q.SetBackendJobID(job, backendJobID)
+	if backendJobID != "" {
+		q.Track(job)
+		s.trackJob(q, job)
+	}
+	return nil
+}
+
+// trackJob polls the backend in the background until the job leaves the
+// printer, then finishes it with the backend's final state
+func (s *Server) trackJob(q *JobQueue, job *Job) {
+	go func() {
+		backend, err := printer.Resolve(job.Printer)
+		if err != nil {
+			q.Finish(job, JobFailed, err.Error())
+			return
+		}
+
+		deadline := time.Now().Add(maxTrackDuration)
+		failures := 0
+		for time.Now().Before(deadline) {
+			status, err := backend.Status(job.Printer, job.BackendID)
+			if err != nil {
+				// Without status the spooler's acceptance is all we know
+				failures++
+				if failures >= maxTrackFailures {
+					logger.Error("Lost track of print job "+job.ID, err)
+					q.Finish(job, JobCompleted, "")
+					return
+				}
+				time.Sleep(trackInterval)
+				continue
+			}
+			failures = 0
+
+			if status.Impressions > 0 {
+				q.SetPages(job, status.Impressions)
+			}
+
+			switch status.State {
+			case "completed", "unknown":
+				q.Finish(job, JobCompleted, "")
+				return
+			case "cancelled":
+				q.Finish(job, JobCancelled, status.Message)
+				return
+			case "aborted":
+				q.Finish(job, JobFailed, fmt.Sprintf("printer aborted the job: %s", status.Message))
+				return
+			}
+			time.Sleep(trackInterval)
+		}
+
+		q.Finish(job, JobFailed, "timed out waiting for the printer")
+	}()
+}
+
+// jobUpdated logs job state changes and forwards them to the frontend
+func (s *Server) jobUpdated(job Job) {
+	logger.JobUpdated(job.ID, string(job.State), job.Error)
+	if s.wailsApp != nil {
+		s.wailsApp.Event.Emit("job-updated", job)
+	}
 }
 
 // Start starts the HTTP server on the specified port
@@ -172,12 +583,34 @@ func (s *Server) Start(port int) error {
 		return fmt.Errorf("server is already running")
 	}
 
+	// HTTPS next to HTTP, for pages served over HTTPS
+	tc := config.GetConfig().TLS
+	if tc != nil && tc.Enabled {
+		ln, err := listenTLS(tc)
+		if err != nil {
+			return fmt.Errorf("failed to start HTTPS: %w", err)
+		}
+		go func() {
+			logger.Info("HTTPS listening on " + ln.Addr().String())
+			if err := s.app.Listener(ln); err != nil {
+				logger.PrintError("HTTPS server error", err)
+			}
+		}()
+	}
+
 	s.port = port
 	s.running = true
 
 	// Start server in goroutine
+	// When every HTTPS client needs a certificate, the LAN can't go
+	// around it over plain HTTP
+	host := ""
+	if tc.RequiresClientCert() {
+		host = "127.0.0.1"
+	}
+
 	go func() {
-		addr := fmt.Sprintf(":%d", port)
+		addr := fmt.Sprintf("%s:%d", host, port)
 		logger.ServerStarted(port)
 		if err := s.app.Listen(addr); err != nil {
 			logger.PrintError("Server error", err)
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"goprint-bridge/config"
+	"goprint-bridge/pdf"
+	"goprint-bridge/templates"
+)
+
+// renderTemplate renders a template into a print request of its output
+// type. pdf templates are laid out with the printer's text_pdf settings,
+// or the defaults.
+func renderTemplate(t *templates.Template, req TemplateRequest) (PrintRequest, error) {
+	content, err := t.Execute(req.Data)
+	if err != nil {
+		return PrintRequest{}, err
+	}
+	if strings.TrimSpace(content) == "" {
+		return PrintRequest{}, fmt.Errorf("template %s rendered nothing", t.Name)
+	}
+
+	printReq := PrintRequest{
+		Type:    t.Type,
+		Content: content,
+		Printer: req.Printer,
+		Copies:  req.Copies,
+		Title:   req.Title,
+		Options: req.Options,
+	}
+	if t.Type != "pdf" {
+		return printReq, nil
+	}
+
+	printerName := req.Printer
+	if printerName == "" {
+		printerName = config.GetConfig().SelectedPrinter
+	}
+	tc := &config.TextPDFConfig{}
+	if pc := config.GetConfig().FindPrinter(printerName); pc != nil && pc.TextPDF != nil {
+		tc = pc.TextPDF
+	}
+	opts := req.Options
+	if req.Title != "" {
+		opts.Title = req.Title
+	}
+
+	data, err := pdf.Text(content, textPDFOptions(tc, opts))
+	if err != nil {
+		return PrintRequest{}, err
+	}
+	printReq.Content = base64.StdEncoding.EncodeToString(data)
+	return printReq, nil
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"goprint-bridge/auth"
+	"goprint-bridge/config"
+	"goprint-bridge/logger"
+)
+
+// ticketHeader carries a job ticket signed by the client's backend. It
+// can't be part of the body, which the ticket's hash covers.
+const ticketHeader = "X-Print-Ticket"
+
+// Error codes returned for job tickets
+const (
+	errMissingTicket  = "missing_ticket"
+	errInvalidTicket  = "invalid_ticket"
+	errTicketExpired  = "ticket_expired"
+	errTicketMismatch = "ticket_mismatch"
+	errTicketReplayed = "ticket_replayed"
+)
+
+// ticketError is a rejected job ticket
+type ticketError struct {
+	status  int
+	code    string
+	message string
+}
+
+// checkTicket verifies the request's job ticket, when tickets are
+// configured, and that it allows this body, printer and number of copies.
+// A ticket's nonce is used up once it passes.
+func (s *Server) checkTicket(c *fiber.Ctx, printerName string, copies int) *ticketError {
+	tc := config.GetConfig().Tickets
+	if tc == nil || len(tc.Keys) == 0 {
+		return nil
+	}
+	token := c.Get(ticketHeader)
+	if token == "" {
+		if tc.Required {
+			return &ticketError{401, errMissingTicket, "Missing job ticket"}
+		}
+		return nil
+	}
+
+	now := time.Now()
+	ticket, err := auth.ParseTicket(token, tc.Verifiers(), now)
+	if errors.Is(err, auth.ErrTicketExpired) {
+		return &ticketError{401, errTicketExpired, "Job ticket expired"}
+	}
+	if err != nil {
+		return &ticketError{401, errInvalidTicket, fmt.Sprintf("Invalid job ticket: %s", err.Error())}
+	}
+
+	if ticket.Hash != auth.HashContent(c.Body()) {
+		return &ticketError{403, errTicketMismatch, "Job ticket doesn't match the payload"}
+	}
+	if ticket.Printer != printerName {
+		return &ticketError{403, errTicketMismatch, fmt.Sprintf("Job ticket doesn't allow printing to %s", printerName)}
+	}
+	if copies == 0 {
+		copies = 1
+	}
+	if copies > ticket.MaxCopies {
+		return &ticketError{403, errTicketMismatch, fmt.Sprintf("Job ticket allows at most %d copies", ticket.MaxCopies)}
+	}
+
+	fresh, err := s.nonces.Use(ticket, now)
+	if err != nil {
+		logger.Error("Failed to record ticket nonce", err)
+		return &ticketError{503, errInvalidTicket, "Job ticket can't be checked right now"}
+	}
+	if !fresh {
+		return &ticketError{409, errTicketReplayed, "Job ticket already used"}
+	}
+	return nil
+}
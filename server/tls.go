@@ -0,0 +1,164 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+
+	"goprint-bridge/certs"
+	"goprint-bridge/config"
+)
+
+// listenTLS opens the HTTPS listener, generating the CA and certificate
+// on first run
+func listenTLS(tc *config.TLSConfig) (net.Listener, error) {
+	dir, err := certs.Dir()
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := certs.DefaultHosts()
+	for _, host := range tc.Hosts {
+		if !slices.Contains(hosts, host) {
+			hosts = append(hosts, host)
+		}
+	}
+	cert, err := certs.Ensure(dir, hosts)
+	if err != nil {
+		return nil, err
+	}
+
+	port := tc.Port
+	if port == 0 {
+		port = config.DefaultTLSPort
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if tc.MutualTLS() {
+		if err := requireClientCerts(tlsConfig, tc); err != nil {
+			return nil, err
+		}
+	}
+
+	ln, err := tls.Listen("tcp", fmt.Sprintf(":%d", port), tlsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on port %d: %w", port, err)
+	}
+	return ln, nil
+}
+
+// allowPrivateNetwork answers Chrome's Private Network Access preflight,
+// which public pages send before calling the bridge on localhost or the
+// LAN. Only origins the operator allowed get this far.
+func allowPrivateNetwork(c *fiber.Ctx) error {
+	if c.Method() == fiber.MethodOptions && c.Get("Access-Control-Request-Private-Network") == "true" {
+		c.Set("Access-Control-Allow-Private-Network", "true")
+		c.Vary("Access-Control-Request-Private-Network")
+	}
+	return c.Next()
+}
+
+// requireClientCerts checks client certificates against the configured
+// CA bundle
+func requireClientCerts(tlsConfig *tls.Config, tc *config.TLSConfig) error {
+	switch tc.ClientAuth {
+	case "", config.ClientAuthRequire:
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	case config.ClientAuthOptional:
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	default:
+		return fmt.Errorf("invalid client_auth %q, must be require or optional", tc.ClientAuth)
+	}
+
+	data, err := os.ReadFile(tc.ClientCA)
+	if err != nil {
+		return fmt.Errorf("failed to read client CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return fmt.Errorf("no certificates in client CA bundle %s", tc.ClientCA)
+	}
+	tlsConfig.ClientCAs = pool
+	return nil
+}
+
+// clientCertLocal is the request local holding the client certificate's
+// identity
+const clientCertLocal = "client_cert"
+
+// Error code for verified certificates that map to no client
+const errUnknownClient = "unknown_client_certificate"
+
+// identifyClient maps the verified client certificate of an HTTPS request
+// to a configured client. Without configured clients, any certificate the
+// CA bundle accepts is a client named after its common name.
+func identifyClient(c *fiber.Ctx) error {
+	state := c.Context().TLSConnectionState()
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return c.Next()
+	}
+	cert := state.PeerCertificates[0]
+
+	tc := config.GetConfig().TLS
+	if !tc.MutualTLS() {
+		return c.Next()
+	}
+	client := matchClient(cert, tc.Clients)
+	if client == nil {
+		if len(tc.Clients) > 0 {
+			return deny(c, 403, errUnknownClient, "Client certificate not allowed")
+		}
+		client = &config.TLSClientConfig{Name: cert.Subject.CommonName}
+	}
+
+	c.Locals(clientCertLocal, client)
+	return c.Next()
+}
+
+// matchClient returns the client whose subject or alternative name the
+// certificate carries, or nil
+func matchClient(cert *x509.Certificate, clients []config.TLSClientConfig) *config.TLSClientConfig {
+	var sans []string
+	sans = append(sans, cert.DNSNames...)
+	sans = append(sans, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+
+	for i := range clients {
+		client := &clients[i]
+		if client.Subject != "" && (client.Subject == cert.Subject.CommonName || client.Subject == cert.Subject.String()) {
+			return client
+		}
+		if client.SAN != "" && slices.Contains(sans, client.SAN) {
+			return client
+		}
+	}
+	return nil
+}
+
+// clientCert returns the identity of the request's client certificate, or
+// nil when the client sent none
+func clientCert(c *fiber.Ctx) *config.TLSClientConfig {
+	client, _ := c.Locals(clientCertLocal).(*config.TLSClientConfig)
+	return client
+}
+
+// identityName returns the name of the request's client certificate for
+// logs
+func identityName(c *fiber.Ctx) string {
+	if client := clientCert(c); client != nil {
+		return client.Name
+	}
+	return ""
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"goprint-bridge/certs"
+	"goprint-bridge/config"
+)
+
+// testCA creates a local CA and server certificate for 127.0.0.1 with the
+// certs package, in a temp dir
+func testCA(t *testing.T) (string, tls.Certificate) {
+	dir := t.TempDir()
+	cert, err := certs.Ensure(dir, []string{"127.0.0.1"})
+	if err != nil {
+		t.Fatalf("Ensure failed: %v", err)
+	}
+	return dir, cert
+}
+
+// issueClient signs a client certificate with the CA in dir
+func issueClient(t *testing.T, dir string, subject pkix.Name, dnsNames ...string) tls.Certificate {
+	ca, err := certs.LoadCertificate(filepath.Join(dir, certs.CAFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "ca-key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(data)
+	caKey, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      subject,
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestMatchClient(t *testing.T) {
+	dir, _ := testCA(t)
+	clients := []config.TLSClientConfig{
+		{Name: "erp", Subject: "erp"},
+		{Name: "store", Subject: "CN=pos,O=Store"},
+		{Name: "till", SAN: "till.local"},
+	}
+
+	tests := []struct {
+		name    string
+		subject pkix.Name
+		sans    []string
+		want    string
+	}{
+		{name: "common name", subject: pkix.Name{CommonName: "erp"}, want: "erp"},
+		{name: "full subject", subject: pkix.Name{CommonName: "pos", Organization: []string{"Store"}}, want: "store"},
+		{name: "alternative name", subject: pkix.Name{CommonName: "register"}, sans: []string{"till.local"}, want: "till"},
+		{name: "no match", subject: pkix.Name{CommonName: "pos"}, sans: []string{"other.local"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert := issueClient(t, dir, tt.subject, tt.sans...)
+			got := matchClient(cert.Leaf, clients)
+			switch {
+			case tt.want == "" && got != nil:
+				t.Errorf("matchClient = %s, want no client", got.Name)
+			case tt.want != "" && (got == nil || got.Name != tt.want):
+				t.Errorf("matchClient = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// serveTLS runs the client certificate middleware behind an HTTPS
+// listener that checks certificates against the configured CA bundle,
+// answering with the client's name
+func serveTLS(t *testing.T, cert tls.Certificate) string {
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if err := requireClientCerts(tlsConfig, config.GetConfig().TLS); err != nil {
+		t.Fatalf("requireClientCerts failed: %v", err)
+	}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use(identifyClient)
+	app.Get("/whoami", func(c *fiber.Ctx) error {
+		return c.SendString(identityName(c))
+	})
+	go app.Listener(ln)
+	t.Cleanup(func() { app.Shutdown() })
+	return "https://" + ln.Addr().String() + "/whoami"
+}
+
+func TestIdentifyClient(t *testing.T) {
+	dir, serverCert := testCA(t)
+	otherDir, _ := testCA(t)
+	loadConfig(t, `
+tls:
+  enabled: true
+  client_auth: optional
+  client_ca: `+filepath.Join(dir, certs.CAFile)+`
+  clients:
+    - name: erp
+      subject: erp
+`)
+	url := serveTLS(t, serverCert)
+
+	ca, err := certs.LoadCertificate(filepath.Join(dir, certs.CAFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	tests := []struct {
+		name   string
+		cert   *tls.Certificate
+		status int // 0 when the handshake fails
+		body   string
+	}{
+		{name: "matching client", cert: ptr(issueClient(t, dir, pkix.Name{CommonName: "erp"})), status: 200, body: "erp"},
+		{name: "unknown client", cert: ptr(issueClient(t, dir, pkix.Name{CommonName: "pos"})), status: 403},
+		{name: "other CA", cert: ptr(issueClient(t, otherDir, pkix.Name{CommonName: "erp"}))},
+		{name: "no certificate", status: 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientConfig := &tls.Config{RootCAs: roots}
+			if tt.cert != nil {
+				clientConfig.Certificates = []tls.Certificate{*tt.cert}
+			}
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConfig}, Timeout: 5 * time.Second}
+			defer client.CloseIdleConnections()
+
+			resp, err := client.Get(url)
+			if tt.status == 0 {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatalf("status = %d, want the handshake to fail", resp.StatusCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, tt.status, body)
+			}
+			if tt.status == 200 && string(body) != tt.body {
+				t.Errorf("client = %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
+
+// ptr returns a pointer to a copy of v
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,116 @@
+package templates
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// escapeFunc is the function appended to every action of a template. Its
+// name can't clash with the helpers.
+const escapeFunc = "_escape"
+
+// command is template output already written in the output's command
+// language, such as a barcode field. It isn't escaped.
+type command string
+
+// escapers make a value safe to write into each output type, so request
+// data can't close a field or string and start commands of its own
+var escapers = map[string]func(s string) (string, error){
+	"zpl":     escapeZPL,
+	"tspl":    escapeTSPL,
+	"epl":     escapeEPL,
+	"escpos":  escapeJSON,
+	"receipt": escapeJSON,
+}
+
+// escaper returns the function that writes an action's value for the
+// output type
+func escaper(outputType string) func(v interface{}) (string, error) {
+	escape := escapers[outputType]
+	return func(v interface{}) (string, error) {
+		if c, ok := v.(command); ok {
+			return string(c), nil
+		}
+		s := text(v)
+		if escape == nil {
+			return s, nil
+		}
+		return escape(s)
+	}
+}
+
+// escapeZPL rejects the ZPL command prefixes
+func escapeZPL(s string) (string, error) {
+	if strings.ContainsAny(s, "^~") {
+		return "", fmt.Errorf("data must not contain ^ or ~")
+	}
+	return s, nil
+}
+
+// escapeTSPL rejects what ends a TSPL string or command
+func escapeTSPL(s string) (string, error) {
+	if strings.ContainsAny(s, "\"\r\n") {
+		return "", fmt.Errorf("data must not contain quotes or line breaks")
+	}
+	return s, nil
+}
+
+// escapeEPL rejects what ends an EPL string or command
+func escapeEPL(s string) (string, error) {
+	if strings.ContainsAny(s, "\"\\\r\n") {
+		return "", fmt.Errorf("data must not contain quotes, backslashes or line breaks")
+	}
+	return s, nil
+}
+
+// escapeJSON escapes a value for use inside a JSON string
+func escapeJSON(s string) (string, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+	return string(data[1 : len(data)-1]), nil
+}
+
+// escapeActions appends the escape function to every action that writes
+// output, in the template and the templates it defines
+func escapeActions(t *template.Template) {
+	for _, tt := range t.Templates() {
+		if tt.Tree != nil {
+			escapeList(tt.Tree.Root)
+		}
+	}
+}
+
+// escapeList escapes the actions of a list and the lists nested in it
+func escapeList(list *parse.ListNode) {
+	if list == nil {
+		return
+	}
+	for _, node := range list.Nodes {
+		switch n := node.(type) {
+		case *parse.ActionNode:
+			// {{$x := ...}} writes nothing
+			if len(n.Pipe.Decl) > 0 {
+				continue
+			}
+			n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+				NodeType: parse.NodeCommand,
+				Pos:      n.Pos,
+				Args:     []parse.Node{parse.NewIdentifier(escapeFunc).SetPos(n.Pos)},
+			})
+		case *parse.IfNode:
+			escapeList(n.List)
+			escapeList(n.ElseList)
+		case *parse.RangeNode:
+			escapeList(n.List)
+			escapeList(n.ElseList)
+		case *parse.WithNode:
+			escapeList(n.List)
+			escapeList(n.ElseList)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package templates
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"goprint-bridge/layout"
+)
+
+// funcs returns the helper functions for a template of the given output
+// type. Barcodes are written in the output's command language.
+func funcs(outputType string) template.FuncMap {
+	return template.FuncMap{
+		"currency": currency,
+		"date":     date,
+		"now":      time.Now,
+		"padLeft":  padLeft,
+		"padRight": padRight,
+		"center":   center,
+		"truncate": truncate,
+		"repeat":   repeat,
+		"upper":    func(v interface{}) string { return strings.ToUpper(text(v)) },
+		"lower":    func(v interface{}) string { return strings.ToLower(text(v)) },
+		"json": func(v interface{}) (command, error) {
+			data, err := toJSON(v)
+			return command(data), err
+		},
+		"barcode": func(data interface{}, symbology ...string) (command, error) {
+			c, err := barcode(outputType, text(data), symbology...)
+			return command(c), err
+		},
+		"qr": func(data interface{}) (command, error) {
+			c, err := qr(outputType, text(data))
+			return command(c), err
+		},
+		escapeFunc: escaper(outputType),
+	}
+}
+
+// text formats a template value. JSON numbers decode as floats, which
+// are written out in full rather than as 1.23456789e+08.
+func text(v interface{}) string {
+	switch n := v.(type) {
+	case float64:
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(n), 'f', -1, 32)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// number converts a template value to a float
+func number(v interface{}) (float64, error) {
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case float32:
+		return float64(n), nil
+	case int:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	case json.Number:
+		return n.Float64()
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(n), 64)
+	default:
+		return 0, fmt.Errorf("not a number: %v", v)
+	}
+}
+
+// currency formats an amount with two decimals and thousands separators,
+// e.g. 1,234.50
+func currency(v interface{}) (string, error) {
+	amount, err := number(v)
+	if err != nil {
+		return "", err
+	}
+
+	cents := int64(math.Round(math.Abs(amount) * 100))
+	whole := strconv.FormatInt(cents/100, 10)
+	for i := len(whole) - 3; i > 0; i -= 3 {
+		whole = whole[:i] + "," + whole[i:]
+	}
+
+	sign := ""
+	if amount < 0 && cents > 0 {
+		sign = "-"
+	}
+	return fmt.Sprintf("%s%s.%02d", sign, whole, cents%100), nil
+}
+
+// date formats a time with a Go layout. Values can be times, RFC 3339 or
+// YYYY-MM-DD strings, or Unix seconds.
+func date(layout string, v interface{}) (string, error) {
+	var t time.Time
+	switch d := v.(type) {
+	case time.Time:
+		t = d
+	case string:
+		var err error
+		t, err = time.Parse(time.RFC3339, d)
+		if err != nil {
+			t, err = time.Parse("2006-01-02", d)
+		}
+		if err != nil {
+			return "", fmt.Errorf("not a date: %q", d)
+		}
+	default:
+		seconds, err := number(v)
+		if err != nil {
+			return "", fmt.Errorf("not a date: %v", v)
+		}
+		t = time.Unix(int64(seconds), 0)
+	}
+	return t.Format(layout), nil
+}
+
+// padLeft right-aligns a value in width columns
+func padLeft(width int, v interface{}) string {
+	return layout.Pad(text(v), width, "right")
+}
+
+// padRight left-aligns a value in width columns
+func padRight(width int, v interface{}) string {
+	return layout.Pad(text(v), width, "left")
+}
+
+// center centers a value in width columns
+func center(width int, v interface{}) string {
+	return layout.Pad(text(v), width, "center")
+}
+
+// truncate cuts a value to width columns
+func truncate(width int, v interface{}) string {
+	return layout.Truncate(text(v), width)
+}
+
+// repeat repeats a string, for rules and spacing
+func repeat(n int, s string) (string, error) {
+	if n < 0 || n > 1000 {
+		return "", fmt.Errorf("repeat count must be between 0 and 1000")
+	}
+	return strings.Repeat(s, n), nil
+}
+
+// toJSON quotes a value for use inside JSON output (escpos, receipt)
+func toJSON(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+// zplBarcodes maps symbologies to ZPL barcode fields
+var zplBarcodes = map[string]string{
+	"code128": "^BCN,80,Y,N,N",
+	"code39":  "^B3N,N,80,Y,N",
+	"ean13":   "^BEN,80,Y,N",
+	"ean8":    "^B8N,80,Y,N",
+	"upca":    "^BUN,80,Y,N,Y",
+}
+
+// barcode returns a barcode command: a ZPL field or an ESC/POS command
+func barcode(outputType, data string, symbology ...string) (string, error) {
+	sym := "code128"
+	if len(symbology) > 0 {
+		sym = symbology[0]
+	}
+
+	switch outputType {
+	case "zpl":
+		field, ok := zplBarcodes[sym]
+		if !ok {
+			return "", fmt.Errorf("unsupported symbology %q", sym)
+		}
+		if strings.ContainsAny(data, "^~") {
+			return "", fmt.Errorf("barcode data must not contain ^ or ~")
+		}
+		return "^BY2" + field + "^FD" + data + "^FS", nil
+	case "escpos":
+		return toJSON(map[string]string{"type": "barcode", "data": data, "symbology": sym, "align": "center"})
+	default:
+		return "", fmt.Errorf("barcodes need escpos or zpl output")
+	}
+}
+
+// qr returns a QR code command: a ZPL field or an ESC/POS command
+func qr(outputType, data string) (string, error) {
+	switch outputType {
+	case "zpl":
+		if strings.ContainsAny(data, "^~") {
+			return "", fmt.Errorf("QR data must not contain ^ or ~")
+		}
+		return "^BQN,2,6^FDMA," + data + "^FS", nil
+	case "escpos":
+		return toJSON(map[string]string{"type": "qr", "data": data, "align": "center"})
+	default:
+		return "", fmt.Errorf("QR codes need escpos or zpl output")
+	}
+}
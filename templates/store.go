@@ -0,0 +1,196 @@
+package templates
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"goprint-bridge/logger"
+)
+
+// Store holds the templates of a directory and reloads them when the
+// files change. A template that fails to parse keeps its last good
+// version until it is fixed.
+type Store struct {
+	dir       string
+	mu        sync.RWMutex
+	templates map[string]*Template
+	watcher   *fsnotify.Watcher
+	pending   map[string]*time.Timer // Reloads waiting for writes to settle
+}
+
+// reloadDelay lets an editor finish writing a file before it is parsed,
+// so a truncated file isn't loaded half way through a save
+const reloadDelay = 200 * time.Millisecond
+
+// Open loads the templates in dir, creating it if needed, and watches it
+// for changes. The store is usable even when an error is returned, with
+// the templates that could be loaded.
+func Open(dir string) (*Store, error) {
+	s := &Store{
+		dir:       dir,
+		templates: make(map[string]*Template),
+		pending:   make(map[string]*time.Timer),
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return s, fmt.Errorf("failed to create templates directory: %w", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return s, fmt.Errorf("failed to read templates directory: %w", err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			s.load(filepath.Join(dir, entry.Name()))
+		}
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return s, fmt.Errorf("failed to watch templates: %w", err)
+	}
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return s, fmt.Errorf("failed to watch templates: %w", err)
+	}
+	s.watcher = watcher
+	go s.watch()
+	return s, nil
+}
+
+// Get returns the template with the given name
+func (s *Store) Get(name string) (*Template, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t, ok := s.templates[name]
+	return t, ok
+}
+
+// List returns the templates sorted by name
+func (s *Store) List() []*Template {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := make([]*Template, 0, len(s.templates))
+	for _, t := range s.templates {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Close stops watching the directory
+func (s *Store) Close() error {
+	if s.watcher == nil {
+		return nil
+	}
+	return s.watcher.Close()
+}
+
+// watch reloads templates as their files are written, created, renamed
+// or removed
+func (s *Store) watch() {
+	for {
+		select {
+		case event, ok := <-s.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+				s.remove(event.Name)
+			}
+			if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) {
+				s.scheduleLoad(event.Name)
+			}
+		case err, ok := <-s.watcher.Errors:
+			if !ok {
+				return
+			}
+			logger.Error("Template watcher failed", err)
+		}
+	}
+}
+
+// scheduleLoad loads a file once it hasn't changed for reloadDelay
+func (s *Store) scheduleLoad(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if timer, ok := s.pending[path]; ok {
+		timer.Reset(reloadDelay)
+		return
+	}
+	s.pending[path] = time.AfterFunc(reloadDelay, func() {
+		s.mu.Lock()
+		delete(s.pending, path)
+		s.mu.Unlock()
+		s.load(path)
+	})
+}
+
+// templateName returns the template name for a file, or "" for files
+// that aren't templates
+func templateName(path string) string {
+	base := filepath.Base(path)
+	name := strings.TrimSuffix(base, Extension)
+	if name == base || !ValidName(name) {
+		return ""
+	}
+	return name
+}
+
+// load parses a template file and adds or replaces the template
+func (s *Store) load(path string) {
+	name := templateName(path)
+	if name == "" {
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		// Removed again before it could be read
+		if os.IsNotExist(err) {
+			return
+		}
+		logger.Error("Failed to read template "+name, err)
+		return
+	}
+	t, err := Parse(name, string(data))
+	if err != nil {
+		logger.Error("Failed to parse template "+name, err)
+		return
+	}
+
+	s.mu.Lock()
+	_, replaced := s.templates[name]
+	s.templates[name] = t
+	s.mu.Unlock()
+
+	if replaced {
+		logger.Info(fmt.Sprintf("Reloaded template %s (%s)", name, t.Type))
+	} else {
+		logger.Info(fmt.Sprintf("Loaded template %s (%s)", name, t.Type))
+	}
+}
+
+// remove drops the template of a removed or renamed file
+func (s *Store) remove(path string) {
+	name := templateName(path)
+	if name == "" {
+		return
+	}
+
+	s.mu.Lock()
+	_, ok := s.templates[name]
+	delete(s.templates, name)
+	s.mu.Unlock()
+
+	if ok {
+		logger.Info("Removed template " + name)
+	}
+}
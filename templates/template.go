@@ -0,0 +1,81 @@
+package templates
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"text/template"
+)
+
+// Extension is the file extension of templates
+const Extension = ".tmpl"
+
+// DefaultType is the output type of templates that don't declare one
+const DefaultType = "text"
+
+// Types lists the output types a template can declare. pdf output is
+// text laid out as PDF pages.
+var Types = map[string]bool{
+	"text":    true,
+	"raw":     true,
+	"escpos":  true,
+	"receipt": true,
+	"zpl":     true,
+	"tspl":    true,
+	"epl":     true,
+	"pdf":     true,
+}
+
+// declarationPattern matches the comment a template starts with to
+// declare its output type, e.g. {{/* type: zpl */}}
+var declarationPattern = regexp.MustCompile(`^\{\{-?\s*/\*\s*type:\s*([a-z]+)\s*\*/\s*-?\}\}\n?`)
+
+// namePattern matches template names, which are also file names
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Template is a named text/template with its output type
+type Template struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+
+	tmpl *template.Template
+}
+
+// ValidName reports whether name can be a template name
+func ValidName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+// Parse parses a template. The output type is declared in a leading
+// comment and defaults to text. Values written into label and ESC/POS
+// output are escaped, or rejected where they can't be.
+func Parse(name, text string) (*Template, error) {
+	t := &Template{Name: name, Type: DefaultType}
+	if m := declarationPattern.FindStringSubmatch(text); m != nil {
+		t.Type = m[1]
+		text = text[len(m[0]):]
+	}
+	if !Types[t.Type] {
+		return nil, fmt.Errorf("template %s: unknown output type %q", name, t.Type)
+	}
+
+	tmpl, err := template.New(name).
+		Option("missingkey=error").
+		Funcs(funcs(t.Type)).
+		Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("template %s: %w", name, err)
+	}
+	escapeActions(tmpl)
+	t.tmpl = tmpl
+	return t, nil
+}
+
+// Execute renders the template with the request data
+func (t *Template) Execute(data interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,280 @@
+package templates
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// requestData decodes JSON like the print endpoint does, so numbers are
+// float64
+func requestData(t *testing.T, s string) map[string]interface{} {
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &data); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// execute parses and runs a template
+func execute(t *testing.T, text string, data interface{}) (string, error) {
+	tmpl, err := Parse("test", text)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	return tmpl.Execute(data)
+}
+
+func TestParseType(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "Hello", want: "text"},
+		{text: "{{/* type: zpl */}}\n^XA^XZ", want: "zpl"},
+		{text: "{{- /* type: escpos */ -}}\n[]", want: "escpos"},
+	}
+	for _, tt := range tests {
+		tmpl, err := Parse("test", tt.text)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", tt.text, err)
+			continue
+		}
+		if tmpl.Type != tt.want {
+			t.Errorf("Parse(%q) type = %q, want %q", tt.text, tmpl.Type, tt.want)
+		}
+	}
+
+	if _, err := Parse("test", "{{/* type: html */}}"); err == nil || !strings.Contains(err.Error(), "unknown output type") {
+		t.Errorf("Parse error = %v, want an unknown output type", err)
+	}
+	if _, err := Parse("test", "{{.name"); err == nil {
+		t.Error("Parse accepted an unclosed action")
+	}
+}
+
+func TestExecuteNumbers(t *testing.T) {
+	data := requestData(t, `{"sku": 123456789, "qty": 3, "price": 1234.5, "tiny": 0.000025, "big": 1e21}`)
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "{{.sku}}", want: "123456789"},
+		{text: "{{.qty}} x {{.price}}", want: "3 x 1234.5"},
+		{text: "{{.tiny}}", want: "0.000025"},
+		{text: "{{.big}}", want: "1000000000000000000000"},
+		{text: "{{currency .price}}", want: "1,234.50"},
+		{text: "[{{padLeft 10 .sku}}]", want: "[ 123456789]"},
+		{text: "{{upper .sku}}", want: "123456789"},
+		// Numbers are escaped like any other value
+		{text: "{{/* type: zpl */}}^FD{{.sku}}^FS", want: "^FD123456789^FS"},
+		{text: `{{/* type: escpos */}}{"text":"{{.sku}}"}`, want: `{"text":"123456789"}`},
+	}
+	for _, tt := range tests {
+		got, err := execute(t, tt.text, data)
+		if err != nil {
+			t.Errorf("Execute(%q) failed: %v", tt.text, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Execute(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestExecuteMissingKey(t *testing.T) {
+	if _, err := execute(t, "{{.missing}}", map[string]interface{}{}); err == nil {
+		t.Error("Execute succeeded with a missing key")
+	}
+}
+
+func TestEscaper(t *testing.T) {
+	tests := []struct {
+		outputType string
+		value      string
+		want       string // Empty when the value is rejected
+	}{
+		{outputType: "zpl", value: "Tea & Co", want: "Tea & Co"},
+		{outputType: "zpl", value: "^XZ^XA", want: ""},
+		{outputType: "zpl", value: "~JR", want: ""},
+		{outputType: "tspl", value: "50% off", want: "50% off"},
+		{outputType: "tspl", value: `a"b`, want: ""},
+		{outputType: "tspl", value: "a\r\nPRINT 99", want: ""},
+		{outputType: "epl", value: "a\\b", want: ""},
+		{outputType: "epl", value: `a"b`, want: ""},
+		{outputType: "epl", value: "a\nP99", want: ""},
+		{outputType: "escpos", value: "a\"b\\c\nd", want: `a\"b\\c\nd`},
+		{outputType: "receipt", value: `"},{"type":"drawer`, want: `\"},{\"type\":\"drawer`},
+		{outputType: "text", value: "a\"^\n", want: "a\"^\n"},
+		{outputType: "raw", value: "\x1B@", want: "\x1B@"},
+		{outputType: "pdf", value: "(x)", want: "(x)"},
+	}
+	for _, tt := range tests {
+		got, err := escaper(tt.outputType)(tt.value)
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("%s escaper accepted %q as %q", tt.outputType, tt.value, got)
+		case tt.want != "" && err != nil:
+			t.Errorf("%s escaper rejected %q: %v", tt.outputType, tt.value, err)
+		case got != tt.want:
+			t.Errorf("%s escaper(%q) = %q, want %q", tt.outputType, tt.value, got, tt.want)
+		}
+	}
+
+	// Commands built by the helpers are written as they are
+	if got, err := escaper("zpl")(command("^BCN^FD1^FS")); err != nil || got != "^BCN^FD1^FS" {
+		t.Errorf("zpl escaper(command) = %q, %v, want it unchanged", got, err)
+	}
+}
+
+func TestExecuteEscapes(t *testing.T) {
+	data := requestData(t, `{"name": "a\"b", "items": ["x\"y"], "note": "^XZ"}`)
+
+	tests := []struct {
+		name string
+		text string
+		want string
+		err  string
+	}{
+		{
+			name: "escpos field",
+			text: `{{/* type: escpos */}}[{"type":"text","text":"{{.name}}"}]`,
+			want: `[{"type":"text","text":"a\"b"}]`,
+		},
+		{
+			name: "inside range",
+			text: `{{/* type: receipt */}}{{range .items}}"{{.}}"{{end}}`,
+			want: `"x\"y"`,
+		},
+		{
+			name: "inside if and with",
+			text: `{{/* type: escpos */}}{{if .name}}{{with .name}}{{.}}{{end}}{{end}}`,
+			want: `a\"b`,
+		},
+		{
+			name: "inside a defined template",
+			text: `{{/* type: escpos */}}{{define "line"}}{{.}}{{end}}{{template "line" .name}}`,
+			want: `a\"b`,
+		},
+		{
+			name: "through a helper",
+			text: `{{/* type: escpos */}}{{.name | upper}}`,
+			want: `A\"B`,
+		},
+		{
+			name: "variables write nothing",
+			text: `{{/* type: escpos */}}{{$n := .name}}[{{$n}}]`,
+			want: `[a\"b]`,
+		},
+		{
+			name: "json helper",
+			text: `{{/* type: escpos */}}{"text":{{json .name}}}`,
+			want: `{"text":"a\"b"}`,
+		},
+		{
+			name: "zpl barcode",
+			text: `{{/* type: zpl */}}^XA{{barcode "123" "ean13"}}^XZ`,
+			want: `^XA^BY2^BEN,80,Y,N^FD123^FS^XZ`,
+		},
+		{
+			name: "zpl command in data",
+			text: `{{/* type: zpl */}}^XA^FD{{.note}}^FS^XZ`,
+			err:  "must not contain ^ or ~",
+		},
+		{
+			name: "tspl quote in data",
+			text: `{{/* type: tspl */}}TEXT 10,10,"3",0,1,1,"{{.name}}"`,
+			err:  "must not contain quotes",
+		},
+		{
+			name: "zpl command in barcode data",
+			text: `{{/* type: zpl */}}{{barcode .note}}`,
+			err:  "must not contain ^ or ~",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := execute(t, tt.text, data)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("Execute = %q, %v, want error %q", got, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Execute failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Execute = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHelpers(t *testing.T) {
+	data := requestData(t, `{"amount": -1234567.456, "when": "2026-03-01T10:30:00Z"}`)
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "{{currency .amount}}", want: "-1,234,567.46"},
+		{text: `{{currency "0.001"}}`, want: "0.00"},
+		{text: `{{date "02.01.2006 15:04" .when}}`, want: "01.03.2026 10:30"},
+		{text: `{{date "2006-01-02" "2026-03-01"}}`, want: "2026-03-01"},
+		{text: `[{{padRight 5 "ab"}}|{{center 6 "ab"}}|{{truncate 3 "abcdef"}}]`, want: "[ab   |  ab  |abc]"},
+		{text: `{{repeat 4 "="}}`, want: "===="},
+	}
+	for _, tt := range tests {
+		got, err := execute(t, tt.text, data)
+		if err != nil {
+			t.Errorf("Execute(%q) failed: %v", tt.text, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Execute(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+
+	for _, text := range []string{`{{currency "abc"}}`, `{{date "2006" "yesterday"}}`, `{{repeat 1001 "x"}}`, `{{barcode "1"}}`} {
+		if _, err := execute(t, text, data); err == nil {
+			t.Errorf("Execute(%q) succeeded, want an error", text)
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"receipt.tmpl":   "{{/* type: receipt */}}{}",
+		"label.tmpl":     "{{/* type: zpl */}}^XA^XZ",
+		"broken.tmpl":    "{{.name",
+		"notes.txt":      "not a template",
+		"bad name!.tmpl": "x",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer s.Close()
+
+	var names []string
+	for _, tmpl := range s.List() {
+		names = append(names, tmpl.Name+":"+tmpl.Type)
+	}
+	if got := strings.Join(names, ","); got != "label:zpl,receipt:receipt" {
+		t.Errorf("List = %s, want label:zpl,receipt:receipt", got)
+	}
+	if _, ok := s.Get("broken"); ok {
+		t.Error("a template that doesn't parse was loaded")
+	}
+}